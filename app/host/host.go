@@ -0,0 +1,278 @@
+// Package host implements the tracking of the hosts attached to openflow 1.0
+// datapaths.
+package host
+
+import (
+	"bytes"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/kuun/ofgo/app/topology"
+	"github.com/kuun/ofgo/controller"
+	"github.com/kuun/ofgo/ofp"
+	"github.com/kuun/ofgo/ofp10"
+)
+
+// Kinds of host events.
+const (
+	HostAdded   = iota // A host was seen for the first time.
+	HostMoved          // A host was seen on another attachment point.
+	HostRemoved        // The attachment point of a host went away.
+)
+
+// Host is a host attached to an edge port of a datapath. A host is identified
+// by its MAC address and VLAN.
+type Host struct {
+	Mac      [6]byte
+	VlanId   uint16   // VLAN of the host, OFP_VLAN_NONE if it's untagged.
+	Ips      []uint32 // IPv4 addresses of the host.
+	Dpid     uint64   // Datapath the host is attached to.
+	Port     uint16   // Port the host is attached to.
+	LastSeen time.Time
+}
+
+func (h *Host) copy() Host {
+	h2 := *h
+	h2.Ips = append([]uint32(nil), h.Ips...)
+	return h2
+}
+
+// HostEvent tells a host was added, moved or removed.
+type HostEvent struct {
+	Kind int  // One of Host*.
+	Host Host // Host, at its new attachment point for a move.
+	// Former attachment point of a moved host.
+	FromDpid uint64
+	FromPort uint16
+}
+
+type hostKey struct {
+	mac    [6]byte
+	vlanId uint16
+}
+
+// Tracker is the host tracking app. It learns the MAC address, VLAN, IPv4
+// addresses and attachment point of the hosts from the packet ins received
+// on edge ports. Ports at the end of a link discovered by the topology are
+// not edge ports, hosts learned on a port are removed when it becomes a link
+// end or goes down.
+type Tracker struct {
+	// OnHost is called when a host is added, moved or removed, it's
+	// optional. It's called from the controller's handlers, it must not
+	// block.
+	OnHost func(event HostEvent)
+
+	topo *topology.Discovery
+	ac   *controller.AppContext
+
+	mu    sync.Mutex
+	hosts map[hostKey]*Host
+	ips   map[uint32]hostKey // IPv4 address -> host having it.
+}
+
+// New creates a host tracker, 'topo' tells the ports which are link ends. All
+// the ports are edge ports if it's nil.
+func New(topo *topology.Discovery) *Tracker {
+	return &Tracker{
+		topo:  topo,
+		hosts: make(map[hostKey]*Host),
+		ips:   make(map[uint32]hostKey),
+	}
+}
+
+func (t *Tracker) Name() string {
+	return "host"
+}
+
+// Init hooks the tracker to the link events of the topology, a callback
+// already set on the topology is still called.
+func (t *Tracker) Init(ac *controller.AppContext) {
+	t.ac = ac
+	if t.topo == nil {
+		return
+	}
+	onLink := t.topo.OnLink
+	t.topo.OnLink = func(event topology.LinkEvent) {
+		if onLink != nil {
+			onLink(event)
+		}
+		if event.Up {
+			link := event.Link
+			t.remove(func(h *Host) bool {
+				return (h.Dpid == link.SrcDpid && h.Port == link.SrcPort) ||
+					(h.Dpid == link.DstDpid && h.Port == link.DstPort)
+			})
+		}
+	}
+}
+
+// IsEdge reports whether a port of a datapath faces hosts.
+func (t *Tracker) IsEdge(dpid uint64, portNo uint16) bool {
+	if portNo >= ofp10.OFPP_MAX {
+		return false
+	}
+	return t.topo == nil || !t.topo.IsInternal(dpid, portNo)
+}
+
+// PacketIn learns the host sending a packet.
+func (t *Tracker) PacketIn(dp *controller.Datapath, msg *ofp10.PacketIn) controller.Result {
+	if dp.Version != ofp.OFP10_VERSION || !t.IsEdge(dp.Dpid, msg.InPort) {
+		return controller.Continue
+	}
+	match, err := ofp10.MatchFromPacket(msg.Data, msg.InPort)
+	if err != nil || match.EthType == ofp10.ETH_TYPE_LLDP || match.EthSrc[0]&1 != 0 {
+		return controller.Continue
+	}
+	var ip uint32
+	if (match.EthType == ofp10.ETH_TYPE_IPV4 || match.EthType == ofp10.ETH_TYPE_ARP) &&
+		match.Wildcards&ofp10.OFPFW_NW_SRC_MASK == 0 {
+		// The source of an IPv4 packet, or the sender of an ARP packet.
+		ip = match.NwSrc
+	}
+	t.learn(hostKey{match.EthSrc, match.VlanId}, ip, dp.Dpid, msg.InPort)
+	return controller.Continue
+}
+
+func (t *Tracker) learn(key hostKey, ip uint32, dpid uint64, port uint16) {
+	var event *HostEvent
+	t.mu.Lock()
+	h := t.hosts[key]
+	switch {
+	case h == nil:
+		h = &Host{Mac: key.mac, VlanId: key.vlanId, Dpid: dpid, Port: port}
+		t.hosts[key] = h
+		event = &HostEvent{Kind: HostAdded}
+	case h.Dpid != dpid || h.Port != port:
+		event = &HostEvent{Kind: HostMoved, FromDpid: h.Dpid, FromPort: h.Port}
+		h.Dpid, h.Port = dpid, port
+	}
+	h.LastSeen = time.Now()
+	if owner, ok := t.ips[ip]; ip != 0 && (!ok || owner != key) {
+		if other := t.hosts[owner]; ok && other != nil {
+			// The address moved from another host.
+			other.Ips = removeIp(other.Ips, ip)
+		}
+		t.ips[ip] = key
+		h.Ips = append(h.Ips, ip)
+	}
+	if event != nil {
+		event.Host = h.copy()
+	}
+	t.mu.Unlock()
+	if event != nil {
+		t.notify([]HostEvent{*event})
+	}
+}
+
+func removeIp(ips []uint32, ip uint32) []uint32 {
+	for i, addr := range ips {
+		if addr == ip {
+			return append(ips[:i], ips[i+1:]...)
+		}
+	}
+	return ips
+}
+
+// PortStatus removes the hosts attached to a port which went down or was
+// removed.
+func (t *Tracker) PortStatus(dp *controller.Datapath, msg *ofp10.PortStatus) {
+	desc := &msg.Desc
+	if msg.Reason != ofp10.OFPPR_DELETE &&
+		desc.State&ofp10.OFPPS_LINK_DOWN == 0 && desc.Config&ofp10.OFPPC_PORT_DOWN == 0 {
+		return
+	}
+	t.remove(func(h *Host) bool {
+		return h.Dpid == dp.Dpid && h.Port == desc.PortNo
+	})
+}
+
+// Disconnect removes the hosts attached to a datapath, unless its session was
+// replaced by a new one.
+func (t *Tracker) Disconnect(dp *controller.Datapath, err error) {
+	if current := t.ac.Controller().Datapath(dp.Dpid); current != nil && current != dp {
+		return
+	}
+	t.remove(func(h *Host) bool {
+		return h.Dpid == dp.Dpid
+	})
+}
+
+// remove removes the hosts selected by the function, which is called with
+// the lock held.
+func (t *Tracker) remove(selected func(h *Host) bool) {
+	var events []HostEvent
+	t.mu.Lock()
+	for key, h := range t.hosts {
+		if !selected(h) {
+			continue
+		}
+		delete(t.hosts, key)
+		for _, ip := range h.Ips {
+			delete(t.ips, ip)
+		}
+		events = append(events, HostEvent{Kind: HostRemoved, Host: h.copy()})
+	}
+	t.mu.Unlock()
+	t.notify(events)
+}
+
+func (t *Tracker) notify(events []HostEvent) {
+	if t.OnHost == nil {
+		return
+	}
+	for _, event := range events {
+		t.OnHost(event)
+	}
+}
+
+// Lookup gets the host having a MAC address in a VLAN, OFP_VLAN_NONE is the
+// untagged traffic.
+func (t *Tracker) Lookup(mac [6]byte, vlanId uint16) (Host, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	h, ok := t.hosts[hostKey{mac, vlanId}]
+	if !ok {
+		return Host{}, false
+	}
+	return h.copy(), true
+}
+
+// LookupIp gets the host having an IPv4 address.
+func (t *Tracker) LookupIp(ip uint32) (Host, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	key, ok := t.ips[ip]
+	if !ok {
+		return Host{}, false
+	}
+	return t.hosts[key].copy(), true
+}
+
+// Hosts gets the known hosts, sorted by MAC address and VLAN.
+func (t *Tracker) Hosts() []Host {
+	t.mu.Lock()
+	hosts := make([]Host, 0, len(t.hosts))
+	for _, h := range t.hosts {
+		hosts = append(hosts, h.copy())
+	}
+	t.mu.Unlock()
+	sort.Slice(hosts, func(i, j int) bool {
+		if c := bytes.Compare(hosts[i].Mac[:], hosts[j].Mac[:]); c != 0 {
+			return c < 0
+		}
+		return hosts[i].VlanId < hosts[j].VlanId
+	})
+	return hosts
+}
+
+// HostsAt gets the hosts attached to a port of a datapath.
+func (t *Tracker) HostsAt(dpid uint64, portNo uint16) []Host {
+	var hosts []Host
+	for _, h := range t.Hosts() {
+		if h.Dpid == dpid && h.Port == portNo {
+			hosts = append(hosts, h)
+		}
+	}
+	return hosts
+}
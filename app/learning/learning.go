@@ -0,0 +1,243 @@
+// Package learning implements an L2 learning switch application for openflow
+// 1.0 datapaths.
+package learning
+
+import (
+	"sync"
+
+	"github.com/kuun/ofgo/controller"
+	"github.com/kuun/ofgo/ofp"
+	"github.com/kuun/ofgo/ofp10"
+)
+
+// Defaults of a Switch.
+const (
+	DefaultIdleTimeout = 10
+	DefaultHardTimeout = 30
+)
+
+// Switch is a learning switch app. It learns the port of the ethernet source
+// addresses from packet ins, installs exact match flows towards the learned
+// destinations, and floods the packets of unknown destinations. The addresses
+// learned on a port are forgotten when the port goes down, and the app's
+// flows forwarding to it are deleted.
+//
+// The packet ins go on to the following apps, e.g. a host tracker, once
+// they are switched. These apps must only observe them, an app forwarding
+// packets too must be registered before the switch and stop the packet ins
+// it forwards.
+type Switch struct {
+	IdleTimeout uint16 // Idle timeout of the installed flows (seconds).
+	HardTimeout uint16 // Hard timeout of the installed flows (seconds).
+	Priority    uint16 // Priority of the flows, relative to the app's band.
+
+	ac *controller.AppContext
+
+	mu     sync.Mutex
+	tables map[uint64]map[[6]byte]uint16 // Dpid -> address -> port.
+	flows  map[uint64]map[flowKey]flow   // Dpid -> installed flow.
+}
+
+// flowKey identifies a flow installed by the app, by its normalized match.
+type flowKey struct {
+	match    ofp10.Match
+	priority uint16
+}
+
+// flow is a flow installed by the app.
+type flow struct {
+	match    ofp10.Match // Match as installed, the strict deletions compare it as is.
+	priority uint16
+	port     uint16 // Output port.
+}
+
+func New() *Switch {
+	return &Switch{
+		IdleTimeout: DefaultIdleTimeout,
+		HardTimeout: DefaultHardTimeout,
+		tables:      make(map[uint64]map[[6]byte]uint16),
+		flows:       make(map[uint64]map[flowKey]flow),
+	}
+}
+
+func (s *Switch) Name() string {
+	return "learning"
+}
+
+func (s *Switch) Init(ac *controller.AppContext) {
+	s.ac = ac
+}
+
+// Lookup gets the port an address was learned on.
+func (s *Switch) Lookup(dpid uint64, addr [6]byte) (port uint16, ok bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	port, ok = s.tables[dpid][addr]
+	return port, ok
+}
+
+func (s *Switch) learn(dpid uint64, addr [6]byte, port uint16) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	table := s.tables[dpid]
+	if table == nil {
+		table = make(map[[6]byte]uint16)
+		s.tables[dpid] = table
+	}
+	table[addr] = port
+}
+
+// isMulticast reports whether the address is a multicast or broadcast one.
+func isMulticast(addr [6]byte) bool {
+	return addr[0]&1 != 0
+}
+
+func (s *Switch) PacketIn(dp *controller.Datapath, msg *ofp10.PacketIn) controller.Result {
+	if dp.Version != ofp.OFP10_VERSION {
+		return controller.Continue
+	}
+	match, err := ofp10.MatchFromPacket(msg.Data, msg.InPort)
+	if err != nil {
+		return controller.Continue
+	}
+	if match.EthType == ofp10.ETH_TYPE_LLDP {
+		// Link discovery traffic is never switched.
+		return controller.Continue
+	}
+	if !isMulticast(match.EthSrc) {
+		s.learn(dp.Dpid, match.EthSrc, msg.InPort)
+	}
+
+	port, ok := uint16(0), false
+	if !isMulticast(match.EthDst) {
+		port, ok = s.Lookup(dp.Dpid, match.EthDst)
+	}
+	if !ok {
+		s.packetOut(dp, msg, ofp10.OFPP_FLOOD)
+		return controller.Continue
+	}
+	if port == msg.InPort {
+		// The destination is behind the input port, the switch already
+		// delivered the packet.
+		return controller.Continue
+	}
+
+	priority, err := s.ac.Priority(s.Priority)
+	if err != nil {
+		// Without a priority in the app's band, the packets are forwarded
+		// one by one.
+		s.packetOut(dp, msg, port)
+		return controller.Continue
+	}
+	fm := ofp10.NewFlowMod()
+	fm.Match = match
+	fm.Cookie = s.ac.Cookie(0)
+	fm.Priority = priority
+	fm.IdleTimeout = s.IdleTimeout
+	fm.HardTimeout = s.HardTimeout
+	// The flow removed messages keep the installed flows known.
+	fm.Flags = ofp10.OFPFF_SEND_FLOW_REM
+	fm.BufferId = msg.BufferId
+	fm.Xid = dp.Conn().NextXid()
+	output := ofp10.NewActionOutput()
+	output.Port = port
+	fm.AddAction(output)
+	if dp.Write(fm) != nil {
+		return controller.Continue
+	}
+	s.installed(dp.Dpid, flowKey{fm.Match.Normalized(), fm.Priority}, flow{fm.Match, fm.Priority, port})
+	if msg.BufferId == ofp10.OFP_NO_BUFFER {
+		// The flow mod only applies to a buffered packet.
+		s.packetOut(dp, msg, port)
+	}
+	return controller.Continue
+}
+
+// installed records a flow installed on a datapath, it replaces a flow with
+// the same match and priority.
+func (s *Switch) installed(dpid uint64, key flowKey, f flow) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	flows := s.flows[dpid]
+	if flows == nil {
+		flows = make(map[flowKey]flow)
+		s.flows[dpid] = flows
+	}
+	flows[key] = f
+}
+
+// FlowRemoved forgets a flow of the app which expired or was deleted.
+func (s *Switch) FlowRemoved(dp *controller.Datapath, msg *ofp10.FlowRemoved) {
+	s.mu.Lock()
+	delete(s.flows[dp.Dpid], flowKey{msg.Match.Normalized(), msg.Priority})
+	s.mu.Unlock()
+}
+
+// packetOut sends the packet of a packet in out of a port.
+func (s *Switch) packetOut(dp *controller.Datapath, msg *ofp10.PacketIn, port uint16) error {
+	po := ofp10.NewPacketOut()
+	po.Xid = dp.Conn().NextXid()
+	po.BufferId = msg.BufferId
+	po.InPort = msg.InPort
+	output := ofp10.NewActionOutput()
+	output.Port = port
+	po.AddAction(output)
+	if msg.BufferId == ofp10.OFP_NO_BUFFER {
+		po.SetData(msg.Data)
+	}
+	return dp.Write(po)
+}
+
+// PortStatus forgets the addresses learned on a port which went down or was
+// removed, and deletes the flows forwarding to it.
+func (s *Switch) PortStatus(dp *controller.Datapath, msg *ofp10.PortStatus) {
+	if dp.Version != ofp.OFP10_VERSION {
+		return
+	}
+	desc := &msg.Desc
+	down := msg.Reason == ofp10.OFPPR_DELETE ||
+		desc.State&ofp10.OFPPS_LINK_DOWN != 0 || desc.Config&ofp10.OFPPC_PORT_DOWN != 0
+	if !down {
+		return
+	}
+	var dead []flow
+	s.mu.Lock()
+	for addr, port := range s.tables[dp.Dpid] {
+		if port == desc.PortNo {
+			delete(s.tables[dp.Dpid], addr)
+		}
+	}
+	for key, f := range s.flows[dp.Dpid] {
+		if f.port == desc.PortNo {
+			delete(s.flows[dp.Dpid], key)
+			dead = append(dead, f)
+		}
+	}
+	s.mu.Unlock()
+
+	// Openflow 1.0 can't restrict a non-strict deletion to the app's
+	// cookies, the app's flows are deleted one by one.
+	for _, f := range dead {
+		fm := ofp10.NewFlowMod()
+		fm.Xid = dp.Conn().NextXid()
+		fm.Command = ofp10.OFPFC_DELETE_STRICT
+		fm.Match = f.match
+		fm.Priority = f.priority
+		fm.OutPort = desc.PortNo
+		if dp.Write(fm) != nil {
+			return
+		}
+	}
+}
+
+// Disconnect forgets the addresses learned on a datapath, unless the session
+// was replaced by a new one.
+func (s *Switch) Disconnect(dp *controller.Datapath, err error) {
+	if current := s.ac.Controller().Datapath(dp.Dpid); current != nil && current != dp {
+		return
+	}
+	s.mu.Lock()
+	delete(s.tables, dp.Dpid)
+	delete(s.flows, dp.Dpid)
+	s.mu.Unlock()
+}
@@ -0,0 +1,285 @@
+// Package path implements the provisioning of end-to-end paths across the
+// openflow 1.0 datapaths of a discovered topology.
+package path
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/kuun/ofgo/app/topology"
+	"github.com/kuun/ofgo/controller"
+	"github.com/kuun/ofgo/ofp10"
+)
+
+var (
+	ErrNoPath          = errors.New("no path between the endpoints")
+	ErrUnknownPath     = errors.New("unknown path")
+	ErrOverlappingPath = errors.New("match overlapping the match of a provisioned path")
+)
+
+// Endpoint is a port of a datapath, typically the one a host is attached to.
+type Endpoint struct {
+	Dpid uint64
+	Port uint16
+}
+
+// Hop is the crossing of a datapath by a path.
+type Hop struct {
+	Dpid    uint64
+	InPort  uint16
+	OutPort uint16
+}
+
+// Path is a provisioned path. Its flows forward the packets matching Match,
+// entering the network at Src, to Dst.
+type Path struct {
+	Id    uint64
+	Match ofp10.Match
+	Src   Endpoint
+	Dst   Endpoint
+	Hops  []Hop // Hops of the path, empty while there's no route.
+	links []topology.Link
+}
+
+// Service is the path provisioning app. A path is routed along the lightest
+// path of the topology, each datapath on the way gets a flow forwarding the
+// path's packets from the input port to the output port of its hop. Paths
+// crossing a link which goes down are rerouted, a path without route is
+// provisioned again when a link comes up.
+//
+// The flows of the paths only differ by their match and input port, and
+// openflow 1.0 strict deletions ignore the cookie. The matches of the paths
+// must not overlap, whatever their input port, so that paths crossing the
+// same datapath don't replace or delete each other's flows.
+type Service struct {
+	// Weight gets the weight of a link, every link weighs one if it's nil.
+	Weight      func(link topology.Link) uint32
+	IdleTimeout uint16 // Idle timeout of the installed flows (seconds).
+	HardTimeout uint16 // Hard timeout of the installed flows (seconds).
+	Priority    uint16 // Priority of the flows, relative to the app's band.
+
+	topo *topology.Discovery
+	ac   *controller.AppContext
+
+	mu     sync.Mutex
+	paths  map[uint64]*Path
+	nextId uint64
+}
+
+// New creates a path service routing over the topology discovered by 'topo'.
+func New(topo *topology.Discovery) *Service {
+	return &Service{topo: topo, paths: make(map[uint64]*Path)}
+}
+
+func (s *Service) Name() string {
+	return "path"
+}
+
+// Init hooks the service to the link events of the topology, a callback
+// already set on the topology is still called.
+func (s *Service) Init(ac *controller.AppContext) {
+	s.ac = ac
+	onLink := s.topo.OnLink
+	s.topo.OnLink = func(event topology.LinkEvent) {
+		if onLink != nil {
+			onLink(event)
+		}
+		s.LinkEvent(event)
+	}
+}
+
+// Provision routes and installs a path for the packets matching 'match' from
+// an endpoint to another one. It fails with ErrOverlappingPath if the match
+// overlaps the match of a provisioned path.
+func (s *Service) Provision(match ofp10.Match, src, dst Endpoint) (*Path, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, other := range s.paths {
+		if overlaps(match, other.Match) {
+			return nil, ErrOverlappingPath
+		}
+	}
+	s.nextId++
+	p := &Path{Id: s.nextId, Match: match, Src: src, Dst: dst}
+	if err := s.install(p, s.topo.Graph()); err != nil {
+		return nil, err
+	}
+	s.paths[p.Id] = p
+	return p.copy(), nil
+}
+
+// Remove deletes the flows of a path.
+func (s *Service) Remove(id uint64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p, ok := s.paths[id]
+	if !ok {
+		return ErrUnknownPath
+	}
+	delete(s.paths, id)
+	return s.uninstall(p, p.Hops, nil)
+}
+
+// Path gets a provisioned path.
+func (s *Service) Path(id uint64) (*Path, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p, ok := s.paths[id]
+	if !ok {
+		return nil, false
+	}
+	return p.copy(), true
+}
+
+// Paths gets the provisioned paths, sorted by id.
+func (s *Service) Paths() []*Path {
+	s.mu.Lock()
+	paths := make([]*Path, 0, len(s.paths))
+	for _, p := range s.paths {
+		paths = append(paths, p.copy())
+	}
+	s.mu.Unlock()
+	sort.Slice(paths, func(i, j int) bool { return paths[i].Id < paths[j].Id })
+	return paths
+}
+
+// LinkEvent reroutes the paths crossing a link which went down, and installs
+// the paths which had no route when a link comes up.
+func (s *Service) LinkEvent(event topology.LinkEvent) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var graph *topology.Graph
+	for _, p := range s.paths {
+		if event.Up == (p.Hops != nil) || (!event.Up && !p.crosses(event.Link)) {
+			continue
+		}
+		if graph == nil {
+			graph = s.topo.Graph()
+		}
+		s.install(p, graph)
+	}
+}
+
+// overlaps reports whether some packets are matched by both path matches,
+// the input port is the one of each hop.
+func overlaps(a, b ofp10.Match) bool {
+	a.Wildcards |= ofp10.OFPFW_IN_PORT
+	b.Wildcards |= ofp10.OFPFW_IN_PORT
+	return a.Overlaps(&b)
+}
+
+// crosses reports whether the path goes through a link.
+func (p *Path) crosses(link topology.Link) bool {
+	for _, l := range p.links {
+		if l == link {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *Path) copy() *Path {
+	p2 := *p
+	p2.Hops = append([]Hop(nil), p.Hops...)
+	p2.links = nil
+	return &p2
+}
+
+// route gets the hops of the lightest path between the endpoints.
+func (s *Service) route(p *Path, graph *topology.Graph) ([]Hop, []topology.Link, error) {
+	links, ok := graph.ShortestPath(p.Src.Dpid, p.Dst.Dpid, s.Weight)
+	if !ok {
+		return nil, nil, ErrNoPath
+	}
+	hops := make([]Hop, 0, len(links)+1)
+	inPort := p.Src.Port
+	for _, link := range links {
+		hops = append(hops, Hop{Dpid: link.SrcDpid, InPort: inPort, OutPort: link.SrcPort})
+		inPort = link.DstPort
+	}
+	hops = append(hops, Hop{Dpid: p.Dst.Dpid, InPort: inPort, OutPort: p.Dst.Port})
+	return hops, links, nil
+}
+
+// install routes a path and installs its flows, then deletes the flows of the
+// former route which weren't replaced. The path is left without route if
+// routing or installing fails.
+func (s *Service) install(p *Path, graph *topology.Graph) error {
+	oldHops := p.Hops
+	hops, links, err := s.route(p, graph)
+	if err == nil {
+		// The flows are installed from the destination, so the packets don't
+		// reach a datapath before its flow.
+		for i := len(hops) - 1; i >= 0 && err == nil; i-- {
+			err = s.flowMod(p, hops[i], ofp10.OFPFC_ADD)
+		}
+	}
+	if err != nil {
+		// oldHops is copied, an append could write to the array of p.Hops.
+		s.uninstall(p, append(append([]Hop(nil), oldHops...), hops...), nil)
+		p.Hops, p.links = nil, nil
+		return err
+	}
+	s.uninstall(p, oldHops, hops)
+	p.Hops, p.links = hops, links
+	return nil
+}
+
+// uninstall deletes the flows of the hops, except the ones overwritten by the
+// hops kept.
+func (s *Service) uninstall(p *Path, hops []Hop, kept []Hop) error {
+	var firstErr error
+	for _, hop := range hops {
+		if overwritten(hop, kept) {
+			continue
+		}
+		if err := s.flowMod(p, hop, ofp10.OFPFC_DELETE_STRICT); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// overwritten reports whether the flow of a hop is the same entry as the flow
+// of one of the hops, the entries of a datapath differ by their input port.
+func overwritten(hop Hop, hops []Hop) bool {
+	for _, h := range hops {
+		if h.Dpid == hop.Dpid && h.InPort == hop.InPort {
+			return true
+		}
+	}
+	return false
+}
+
+// flowMod writes the flow mod of a hop to its datapath.
+func (s *Service) flowMod(p *Path, hop Hop, command uint16) error {
+	dp := s.ac.Controller().Datapath(hop.Dpid)
+	if dp == nil {
+		return fmt.Errorf("datapath %016x is not connected", hop.Dpid)
+	}
+	priority, err := s.ac.Priority(s.Priority)
+	if err != nil {
+		return err
+	}
+	fm := ofp10.NewFlowMod()
+	fm.Xid = dp.Conn().NextXid()
+	fm.Command = command
+	fm.Match = p.Match
+	fm.Match.Wildcards &^= ofp10.OFPFW_IN_PORT
+	fm.Match.InPort = hop.InPort
+	fm.Cookie = s.ac.Cookie(p.Id)
+	fm.Priority = priority
+	if command == ofp10.OFPFC_ADD {
+		fm.IdleTimeout = s.IdleTimeout
+		fm.HardTimeout = s.HardTimeout
+		output := ofp10.NewActionOutput()
+		output.Port = hop.OutPort
+		if hop.OutPort == hop.InPort {
+			output.Port = ofp10.OFPP_IN_PORT
+		}
+		fm.AddAction(output)
+	}
+	return dp.Write(fm)
+}
@@ -0,0 +1,272 @@
+// Package ratelimit implements the protection of the controller against
+// floods of packet ins from the datapaths.
+package ratelimit
+
+import (
+	"sync"
+	"time"
+
+	"github.com/kuun/ofgo/controller"
+	"github.com/kuun/ofgo/ofp"
+	"github.com/kuun/ofgo/ofp10"
+	"github.com/kuun/ofgo/ofp13"
+)
+
+// DefaultDropTimeout is the default hard timeout of the drop flows (seconds).
+const DefaultDropTimeout = 10
+
+// Stats are the counters of the packet ins of a datapath.
+type Stats struct {
+	Allowed         uint64            // Packet ins let through, link discovery frames aside.
+	Dropped         uint64            // Packet ins dropped.
+	DatapathDropped uint64            // Packet ins dropped by the limit of the datapath.
+	PortDropped     map[uint32]uint64 // Packet ins dropped by the limit of a port, by input port.
+	DropFlows       uint64            // Drop flows installed.
+}
+
+// bucket is a token bucket, a packet in takes a token.
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// refill adds the tokens earned since the last refill.
+func (b *bucket) refill(now time.Time, rate float64, burst int) {
+	if b.last.IsZero() {
+		b.tokens = float64(burst)
+	} else if elapsed := now.Sub(b.last).Seconds(); elapsed > 0 {
+		b.tokens += elapsed * rate
+		if b.tokens > float64(burst) {
+			b.tokens = float64(burst)
+		}
+	}
+	b.last = now
+}
+
+type source struct {
+	port uint16
+	mac  [6]byte
+}
+
+type datapathState struct {
+	bucket bucket
+	ports  map[uint32]*bucket
+	drops  map[source]time.Time // Sources having a drop flow -> its expiry.
+	stats  Stats
+}
+
+// Limiter is the packet in rate limiting app, it must be registered before
+// the apps it protects. It limits the openflow 1.0 and 1.3 packet ins of
+// each datapath, and of each input port of a datapath, with token buckets.
+// The packet ins over the limits are dropped, the following apps don't see
+// them. Link discovery frames are neither limited nor counted, so a flood
+// doesn't drop links.
+//
+// With DropFlows set, a packet in over the limit of its port gets a drop
+// flow installed on the datapath for its input port and ethernet source, so
+// the datapath stops sending the packets of the source for DropTimeout
+// seconds. Drop flows are only installed on openflow 1.0 datapaths.
+type Limiter struct {
+	DatapathRate  float64 // Packet ins per second of a datapath, unlimited if it's zero.
+	DatapathBurst int     // Burst of a datapath, the rate if it's zero.
+	PortRate      float64 // Packet ins per second of an input port, unlimited if it's zero.
+	PortBurst     int     // Burst of an input port, the rate if it's zero.
+	DropFlows     bool    // Install drop flows for the sources over the port limit.
+	DropTimeout   uint16  // Hard timeout of the drop flows (seconds), DefaultDropTimeout if it's zero.
+	DropPriority  uint16  // Priority of the drop flows, relative to the app's band.
+
+	ac *controller.AppContext
+
+	mu        sync.Mutex
+	datapaths map[uint64]*datapathState
+}
+
+func New() *Limiter {
+	return &Limiter{datapaths: make(map[uint64]*datapathState)}
+}
+
+func (l *Limiter) Name() string {
+	return "ratelimit"
+}
+
+// Init registers the openflow 1.3 packet in handler, the controller only
+// registers PacketIn.
+func (l *Limiter) Init(ac *controller.AppContext) {
+	l.ac = ac
+	ac.Controller().HandlePacketIn13(l.PacketIn13)
+}
+
+// burst gets the burst of a bucket, at least one token.
+func burst(rate float64, burst int) int {
+	if burst > 0 {
+		return burst
+	}
+	if rate < 1 {
+		return 1
+	}
+	return int(rate)
+}
+
+func (l *Limiter) state(dpid uint64) *datapathState {
+	s := l.datapaths[dpid]
+	if s == nil {
+		s = &datapathState{
+			ports: make(map[uint32]*bucket),
+			drops: make(map[source]time.Time),
+		}
+		s.stats.PortDropped = make(map[uint32]uint64)
+		l.datapaths[dpid] = s
+	}
+	return s
+}
+
+// PacketIn drops the openflow 1.0 packet ins over the limits.
+func (l *Limiter) PacketIn(dp *controller.Datapath, msg *ofp10.PacketIn) controller.Result {
+	return l.limit(dp, uint32(msg.InPort), msg.Data)
+}
+
+// PacketIn13 drops the openflow 1.3 packet ins over the limits.
+func (l *Limiter) PacketIn13(dp *controller.Datapath, msg *ofp13.PacketIn) controller.Result {
+	return l.limit(dp, msg.InPort(), msg.Data)
+}
+
+// limit takes a token for a packet in, it drops the packet in if the
+// datapath or its input port has none left.
+func (l *Limiter) limit(dp *controller.Datapath, inPort uint32, data []byte) controller.Result {
+	match, err := ofp10.MatchFromPacket(data, uint16(inPort))
+	if err == nil && match.EthType == ofp10.ETH_TYPE_LLDP {
+		return controller.Continue
+	}
+	now := time.Now()
+	l.mu.Lock()
+	s := l.state(dp.Dpid)
+	var port *bucket
+	if l.PortRate > 0 {
+		if port = s.ports[inPort]; port == nil {
+			port = &bucket{}
+			s.ports[inPort] = port
+		}
+		port.refill(now, l.PortRate, burst(l.PortRate, l.PortBurst))
+	}
+	if l.DatapathRate > 0 {
+		s.bucket.refill(now, l.DatapathRate, burst(l.DatapathRate, l.DatapathBurst))
+	}
+
+	// A token is only taken when both buckets have one.
+	switch {
+	case port != nil && port.tokens < 1:
+		s.stats.Dropped++
+		s.stats.PortDropped[inPort]++
+		src, install := source{}, false
+		if l.DropFlows && err == nil && inPort < uint32(ofp10.OFPP_MAX) {
+			src, install = l.dropSource(s, dp, uint16(inPort), &match, now)
+		}
+		l.mu.Unlock()
+		if install {
+			l.dropInstalled(dp, s, src, l.installDrop(dp, src))
+		}
+		return controller.Stop
+	case l.DatapathRate > 0 && s.bucket.tokens < 1:
+		s.stats.Dropped++
+		s.stats.DatapathDropped++
+		l.mu.Unlock()
+		return controller.Stop
+	}
+	if port != nil {
+		port.tokens--
+	}
+	if l.DatapathRate > 0 {
+		s.bucket.tokens--
+	}
+	s.stats.Allowed++
+	l.mu.Unlock()
+	return controller.Continue
+}
+
+func (l *Limiter) dropTimeout() uint16 {
+	if l.DropTimeout == 0 {
+		return DefaultDropTimeout
+	}
+	return l.DropTimeout
+}
+
+// dropSource gets the source of a packet in, and whether a drop flow must be
+// installed for it. The drop flow is recorded, the lock must be held.
+func (l *Limiter) dropSource(s *datapathState, dp *controller.Datapath, inPort uint16, match *ofp10.Match, now time.Time) (source, bool) {
+	if dp.Version != ofp.OFP10_VERSION || inPort >= ofp10.OFPP_MAX {
+		return source{}, false
+	}
+	src := source{inPort, match.EthSrc}
+	if expiry, ok := s.drops[src]; ok && now.Before(expiry) {
+		return src, false
+	}
+	for other, expiry := range s.drops {
+		if !now.Before(expiry) {
+			delete(s.drops, other)
+		}
+	}
+	s.drops[src] = now.Add(time.Duration(l.dropTimeout()) * time.Second)
+	return src, true
+}
+
+// dropInstalled counts the drop flow of a source once it's written, a drop
+// flow which couldn't be written is forgotten so that the source gets
+// another one.
+func (l *Limiter) dropInstalled(dp *controller.Datapath, s *datapathState, src source, err error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.datapaths[dp.Dpid] != s {
+		// The state of the datapath was dropped meanwhile.
+		return
+	}
+	if err != nil {
+		delete(s.drops, src)
+		return
+	}
+	s.stats.DropFlows++
+}
+
+// installDrop installs a flow dropping the packets of a source, a flow
+// without actions drops.
+func (l *Limiter) installDrop(dp *controller.Datapath, src source) error {
+	priority, err := l.ac.Priority(l.DropPriority)
+	if err != nil {
+		return err
+	}
+	fm := ofp10.NewFlowMod()
+	fm.Xid = dp.Conn().NextXid()
+	fm.Match.Wildcards = ofp10.OFPFW_ALL &^ (ofp10.OFPFW_IN_PORT | ofp10.OFPFW_DL_SRC)
+	fm.Match.InPort = src.port
+	fm.Match.EthSrc = src.mac
+	fm.Cookie = l.ac.Cookie(0)
+	fm.Priority = priority
+	fm.HardTimeout = l.dropTimeout()
+	return dp.Write(fm)
+}
+
+// Stats gets the counters of a datapath.
+func (l *Limiter) Stats(dpid uint64) Stats {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	s, ok := l.datapaths[dpid]
+	if !ok {
+		return Stats{}
+	}
+	stats := s.stats
+	stats.PortDropped = make(map[uint32]uint64, len(s.stats.PortDropped))
+	for port, dropped := range s.stats.PortDropped {
+		stats.PortDropped[port] = dropped
+	}
+	return stats
+}
+
+// Disconnect drops the state of a datapath, unless its session was replaced
+// by a new one.
+func (l *Limiter) Disconnect(dp *controller.Datapath, err error) {
+	if current := l.ac.Controller().Datapath(dp.Dpid); current != nil && current != dp {
+		return
+	}
+	l.mu.Lock()
+	delete(l.datapaths, dp.Dpid)
+	l.mu.Unlock()
+}
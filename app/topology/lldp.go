@@ -0,0 +1,95 @@
+package topology
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/kuun/ofgo/ofp10"
+)
+
+// LLDP TLV types.
+const (
+	lldpTlvEnd       = 0
+	lldpTlvChassisId = 1
+	lldpTlvPortId    = 2
+	lldpTlvTTL       = 3
+)
+
+// LLDP TLV subtypes used in discovery frames.
+const (
+	lldpChassisIdLocal  = 7 // Locally assigned chassis id.
+	lldpPortIdComponent = 2 // Port component port id.
+)
+
+// lldpChassisPrefix starts the chassis id of the frames we emit.
+const lldpChassisPrefix = "dpid:"
+
+// LldpMulticast is the nearest bridge group address, bridges don't forward
+// frames sent to it.
+var LldpMulticast = [6]byte{0x01, 0x80, 0xc2, 0x00, 0x00, 0x0e}
+
+// lldpFrame builds the discovery frame sent out of a port.
+func lldpFrame(dpid uint64, portNo uint16, hwAddr [6]byte, ttl uint16) []byte {
+	chassis := lldpChassisPrefix + fmt.Sprintf("%016x", dpid)
+	frame := make([]byte, 0, 64)
+	frame = append(frame, LldpMulticast[:]...)
+	frame = append(frame, hwAddr[:]...)
+	frame = append(frame, byte(ofp10.ETH_TYPE_LLDP>>8), byte(ofp10.ETH_TYPE_LLDP&0xff))
+
+	frame = appendTlv(frame, lldpTlvChassisId, append([]byte{lldpChassisIdLocal}, chassis...))
+	frame = appendTlv(frame, lldpTlvPortId, []byte{lldpPortIdComponent, byte(portNo >> 8), byte(portNo)})
+	frame = appendTlv(frame, lldpTlvTTL, []byte{byte(ttl >> 8), byte(ttl)})
+	frame = appendTlv(frame, lldpTlvEnd, nil)
+	return frame
+}
+
+// appendTlv appends a TLV, its header is a 7 bits type and a 9 bits length.
+func appendTlv(frame []byte, tlvType uint8, value []byte) []byte {
+	header := uint16(tlvType)<<9 | uint16(len(value))
+	frame = append(frame, byte(header>>8), byte(header))
+	return append(frame, value...)
+}
+
+// parseLldp gets the datapath and port a discovery frame was sent from. It
+// fails for frames which weren't emitted by a discovery service.
+func parseLldp(frame []byte) (dpid uint64, portNo uint16, err error) {
+	if len(frame) < 14 || binary.BigEndian.Uint16(frame[12:]) != ofp10.ETH_TYPE_LLDP {
+		return 0, 0, errors.New("not an LLDP frame")
+	}
+	var haveChassis, havePort bool
+	for tlvs := frame[14:]; len(tlvs) >= 2; {
+		header := binary.BigEndian.Uint16(tlvs)
+		tlvType, length := uint8(header>>9), int(header&0x1ff)
+		if len(tlvs) < 2+length {
+			return 0, 0, errors.New("truncated LLDP TLV")
+		}
+		value := tlvs[2 : 2+length]
+		tlvs = tlvs[2+length:]
+		switch tlvType {
+		case lldpTlvEnd:
+			tlvs = nil
+		case lldpTlvChassisId:
+			if length < 1 || value[0] != lldpChassisIdLocal ||
+				!strings.HasPrefix(string(value[1:]), lldpChassisPrefix) {
+				return 0, 0, errors.New("foreign LLDP chassis id")
+			}
+			if dpid, err = strconv.ParseUint(string(value[1+len(lldpChassisPrefix):]), 16, 64); err != nil {
+				return 0, 0, errors.New("foreign LLDP chassis id")
+			}
+			haveChassis = true
+		case lldpTlvPortId:
+			if length != 3 || value[0] != lldpPortIdComponent {
+				return 0, 0, errors.New("foreign LLDP port id")
+			}
+			portNo = binary.BigEndian.Uint16(value[1:])
+			havePort = true
+		}
+	}
+	if !haveChassis || !havePort {
+		return 0, 0, errors.New("incomplete LLDP frame")
+	}
+	return dpid, portNo, nil
+}
@@ -0,0 +1,351 @@
+// Package topology implements an LLDP based discovery of the links between
+// openflow 1.0 datapaths.
+package topology
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/kuun/ofgo/controller"
+	"github.com/kuun/ofgo/ofp"
+	"github.com/kuun/ofgo/ofp10"
+)
+
+// DefaultInterval is the default period of the discovery frames.
+const DefaultInterval = 5 * time.Second
+
+// Link is a directed link, frames sent out of the source port are received
+// on the destination port.
+type Link struct {
+	SrcDpid uint64
+	SrcPort uint16
+	DstDpid uint64
+	DstPort uint16
+}
+
+// LinkEvent tells a link was discovered or went away.
+type LinkEvent struct {
+	Link Link
+	Up   bool
+}
+
+// Discovery is the topology discovery app. It periodically sends an LLDP
+// frame out of every port of the datapaths, and learns a link when a frame
+// comes back in a packet in. A link which isn't seen again within the
+// timeout is aged out, links of a port going down are removed right away.
+//
+// Run must be running for frames to be sent and links to age out.
+type Discovery struct {
+	Interval time.Duration // Period of the discovery frames, DefaultInterval if it's zero.
+	Timeout  time.Duration // Age of the links removed, three intervals if it's zero.
+	// OnLink is called when a link goes up or down, it's optional. It's
+	// called from the controller's handlers and from Run, it must not block.
+	OnLink func(event LinkEvent)
+
+	ac *controller.AppContext
+
+	mu    sync.Mutex
+	links map[Link]time.Time // Link -> last time it was seen.
+}
+
+func New() *Discovery {
+	return &Discovery{links: make(map[Link]time.Time)}
+}
+
+func (d *Discovery) Name() string {
+	return "topology"
+}
+
+func (d *Discovery) Init(ac *controller.AppContext) {
+	d.ac = ac
+}
+
+func (d *Discovery) interval() time.Duration {
+	if d.Interval <= 0 {
+		return DefaultInterval
+	}
+	return d.Interval
+}
+
+func (d *Discovery) timeout() time.Duration {
+	if d.Timeout <= 0 {
+		return 3 * d.interval()
+	}
+	return d.Timeout
+}
+
+// Connect installs the flow sending the discovery frames to the controller.
+func (d *Discovery) Connect(dp *controller.Datapath) {
+	if dp.Version != ofp.OFP10_VERSION {
+		return
+	}
+	fm := ofp10.NewFlowMod()
+	fm.Xid = dp.Conn().NextXid()
+	fm.Match.Wildcards = ofp10.OFPFW_ALL &^ (ofp10.OFPFW_DL_TYPE | ofp10.OFPFW_DL_DST)
+	fm.Match.EthType = ofp10.ETH_TYPE_LLDP
+	fm.Match.EthDst = LldpMulticast
+	fm.Cookie = d.ac.Cookie(0)
+	fm.Priority = d.ac.PriorityMax
+	output := ofp10.NewActionOutput()
+	output.Port = ofp10.OFPP_CONTROLLER
+	output.MaxLen = 0xffff
+	fm.AddAction(output)
+	dp.Write(fm)
+	d.emit(dp)
+}
+
+// PacketIn learns the link a discovery frame went through.
+func (d *Discovery) PacketIn(dp *controller.Datapath, msg *ofp10.PacketIn) controller.Result {
+	srcDpid, srcPort, err := parseLldp(msg.Data)
+	if err != nil {
+		return controller.Continue
+	}
+	link := Link{SrcDpid: srcDpid, SrcPort: srcPort, DstDpid: dp.Dpid, DstPort: msg.InPort}
+	d.mu.Lock()
+	_, known := d.links[link]
+	d.links[link] = time.Now()
+	d.mu.Unlock()
+	if !known {
+		d.notify([]LinkEvent{{Link: link, Up: true}})
+	}
+	return controller.Stop
+}
+
+// PortStatus removes the links of a port going down.
+func (d *Discovery) PortStatus(dp *controller.Datapath, msg *ofp10.PortStatus) {
+	desc := &msg.Desc
+	if msg.Reason != ofp10.OFPPR_DELETE &&
+		desc.State&ofp10.OFPPS_LINK_DOWN == 0 && desc.Config&ofp10.OFPPC_PORT_DOWN == 0 {
+		return
+	}
+	d.remove(func(link Link) bool {
+		return (link.SrcDpid == dp.Dpid && link.SrcPort == desc.PortNo) ||
+			(link.DstDpid == dp.Dpid && link.DstPort == desc.PortNo)
+	})
+}
+
+// Disconnect removes the links of a datapath, unless its session was
+// replaced by a new one.
+func (d *Discovery) Disconnect(dp *controller.Datapath, err error) {
+	if current := d.ac.Controller().Datapath(dp.Dpid); current != nil && current != dp {
+		return
+	}
+	d.remove(func(link Link) bool {
+		return link.SrcDpid == dp.Dpid || link.DstDpid == dp.Dpid
+	})
+}
+
+// remove removes the links selected by the function, which is called with the
+// lock held.
+func (d *Discovery) remove(selected func(link Link) bool) {
+	var events []LinkEvent
+	d.mu.Lock()
+	for link := range d.links {
+		if selected(link) {
+			delete(d.links, link)
+			events = append(events, LinkEvent{Link: link})
+		}
+	}
+	d.mu.Unlock()
+	d.notify(events)
+}
+
+func (d *Discovery) notify(events []LinkEvent) {
+	if d.OnLink == nil {
+		return
+	}
+	for _, event := range events {
+		d.OnLink(event)
+	}
+}
+
+// Run sends the discovery frames and ages the links out until the context is
+// done.
+func (d *Discovery) Run(ctx context.Context) error {
+	ticker := time.NewTicker(d.interval())
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case now := <-ticker.C:
+			deadline := now.Add(-d.timeout())
+			d.remove(func(link Link) bool {
+				return d.links[link].Before(deadline)
+			})
+			for _, dp := range d.ac.Controller().Datapaths() {
+				d.emit(dp)
+			}
+		}
+	}
+}
+
+// emit sends a discovery frame out of every port of the datapath which is up.
+func (d *Discovery) emit(dp *controller.Datapath) {
+	if dp.Version != ofp.OFP10_VERSION {
+		return
+	}
+	ttl := uint16(d.timeout() / time.Second)
+	for _, port := range dp.Ports() {
+		if port.PortNo >= ofp10.OFPP_MAX || port.State&ofp10.OFPPS_LINK_DOWN != 0 ||
+			port.Config&ofp10.OFPPC_PORT_DOWN != 0 {
+			continue
+		}
+		po := ofp10.NewPacketOut()
+		po.Xid = dp.Conn().NextXid()
+		output := ofp10.NewActionOutput()
+		output.Port = port.PortNo
+		po.AddAction(output)
+		po.SetData(lldpFrame(dp.Dpid, port.PortNo, port.HwAddr, ttl))
+		if dp.Write(po) != nil {
+			return
+		}
+	}
+}
+
+// Links gets the known links.
+func (d *Discovery) Links() []Link {
+	d.mu.Lock()
+	links := make([]Link, 0, len(d.links))
+	for link := range d.links {
+		links = append(links, link)
+	}
+	d.mu.Unlock()
+	sortLinks(links)
+	return links
+}
+
+// IsInternal reports whether a port of a datapath is the end of a known link,
+// as opposed to an edge port facing hosts.
+func (d *Discovery) IsInternal(dpid uint64, portNo uint16) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for link := range d.links {
+		if (link.SrcDpid == dpid && link.SrcPort == portNo) ||
+			(link.DstDpid == dpid && link.DstPort == portNo) {
+			return true
+		}
+	}
+	return false
+}
+
+// Graph gets a snapshot of the topology.
+func (d *Discovery) Graph() *Graph {
+	return NewGraph(d.Links())
+}
+
+func sortLinks(links []Link) {
+	sort.Slice(links, func(i, j int) bool {
+		a, b := links[i], links[j]
+		if a.SrcDpid != b.SrcDpid {
+			return a.SrcDpid < b.SrcDpid
+		}
+		if a.SrcPort != b.SrcPort {
+			return a.SrcPort < b.SrcPort
+		}
+		if a.DstDpid != b.DstDpid {
+			return a.DstDpid < b.DstDpid
+		}
+		return a.DstPort < b.DstPort
+	})
+}
+
+// Graph is a directed graph of datapaths, its edges are the links.
+type Graph struct {
+	out map[uint64][]Link // Dpid -> links leaving the datapath.
+	in  map[uint64][]Link // Dpid -> links entering the datapath.
+}
+
+// NewGraph creates the graph of the links.
+func NewGraph(links []Link) *Graph {
+	g := &Graph{out: make(map[uint64][]Link), in: make(map[uint64][]Link)}
+	for _, link := range links {
+		g.out[link.SrcDpid] = append(g.out[link.SrcDpid], link)
+		g.in[link.DstDpid] = append(g.in[link.DstDpid], link)
+		if _, ok := g.out[link.DstDpid]; !ok {
+			g.out[link.DstDpid] = nil
+		}
+	}
+	return g
+}
+
+// Datapaths gets the datapaths having links, sorted.
+func (g *Graph) Datapaths() []uint64 {
+	dpids := make([]uint64, 0, len(g.out))
+	for dpid := range g.out {
+		dpids = append(dpids, dpid)
+	}
+	sort.Slice(dpids, func(i, j int) bool { return dpids[i] < dpids[j] })
+	return dpids
+}
+
+// Links gets the links leaving a datapath.
+func (g *Graph) Links(dpid uint64) []Link {
+	return g.out[dpid]
+}
+
+// IsInternal reports whether a port of a datapath is the end of a link, as
+// opposed to an edge port facing hosts.
+func (g *Graph) IsInternal(dpid uint64, portNo uint16) bool {
+	for _, link := range g.out[dpid] {
+		if link.SrcPort == portNo {
+			return true
+		}
+	}
+	for _, link := range g.in[dpid] {
+		if link.DstPort == portNo {
+			return true
+		}
+	}
+	return false
+}
+
+// ShortestPath gets the links of the lightest path from a datapath to another
+// one, the weight of a link is one if 'weight' is nil. The path is empty when
+// both datapaths are the same, ok is false if there's no path.
+func (g *Graph) ShortestPath(src, dst uint64, weight func(link Link) uint32) (path []Link, ok bool) {
+	if src == dst {
+		return nil, true
+	}
+	dist := map[uint64]uint64{src: 0}
+	prev := make(map[uint64]Link) // Dpid -> link it's reached by.
+	done := make(map[uint64]bool)
+	for {
+		// Openflow networks are small, a linear scan for the closest datapath
+		// is good enough.
+		cur, found := uint64(0), false
+		for dpid, d := range dist {
+			if !done[dpid] && (!found || d < dist[cur] || (d == dist[cur] && dpid < cur)) {
+				cur, found = dpid, true
+			}
+		}
+		if !found {
+			return nil, false
+		}
+		if cur == dst {
+			break
+		}
+		done[cur] = true
+		for _, link := range g.out[cur] {
+			w := uint64(1)
+			if weight != nil {
+				w = uint64(weight(link))
+			}
+			if d, seen := dist[link.DstDpid]; !seen || dist[cur]+w < d {
+				dist[link.DstDpid] = dist[cur] + w
+				prev[link.DstDpid] = link
+			}
+		}
+	}
+	for dpid := dst; dpid != src; {
+		link := prev[dpid]
+		path = append(path, link)
+		dpid = link.SrcDpid
+	}
+	for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
+		path[i], path[j] = path[j], path[i]
+	}
+	return path, true
+}
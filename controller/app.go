@@ -0,0 +1,231 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/kuun/ofgo/flow"
+	"github.com/kuun/ofgo/ofp"
+	"github.com/kuun/ofgo/ofp10"
+)
+
+// The cookie of a flow emitted by an app carries the id of the app in its
+// upper bits, the app owns the lower bits.
+const (
+	AppCookieShift = 48
+	AppCookieMask  = 0xffff << AppCookieShift // Bits identifying the app.
+	AppMaxApps     = 0xffff                   // Apps a controller can register.
+)
+
+// App is an application running on the controller. An app reacts to the
+// events of the datapaths by implementing some of the PacketInApp,
+// FlowRemovedApp, PortStatusApp, ConnectApp and DisconnectApp interfaces.
+type App interface {
+	Name() string
+}
+
+// PacketInApp is an app handling packet ins. Apps see packet ins in the order
+// they were registered, an app returning Stop hides the packet in from the
+// following apps.
+type PacketInApp interface {
+	App
+	PacketIn(dp *Datapath, msg *ofp10.PacketIn) Result
+}
+
+// FlowRemovedApp is an app notified of the removal of its own flows, flows
+// are routed to the app by their cookie.
+type FlowRemovedApp interface {
+	App
+	FlowRemoved(dp *Datapath, msg *ofp10.FlowRemoved)
+}
+
+// PortStatusApp is an app notified of the port changes of the datapaths.
+type PortStatusApp interface {
+	App
+	PortStatus(dp *Datapath, msg *ofp10.PortStatus)
+}
+
+// ConnectApp is an app notified of the datapaths completing their handshake.
+type ConnectApp interface {
+	App
+	Connect(dp *Datapath)
+}
+
+// DisconnectApp is an app notified of the datapaths whose session ended.
+type DisconnectApp interface {
+	App
+	Disconnect(dp *Datapath, err error)
+}
+
+// AppInitializer is an app given its context when it's registered.
+type AppInitializer interface {
+	App
+	Init(ac *AppContext)
+}
+
+// AppContext is the namespace of a registered app, the cookies and
+// priorities of the flows the app emits are confined to it.
+type AppContext struct {
+	App         App
+	Id          uint16 // Id of the app, the upper bits of its cookies.
+	PriorityMin uint16 // Lowest priority of the app's flows.
+	PriorityMax uint16 // Highest priority of the app's flows.
+
+	controller *Controller
+}
+
+// Controller gets the controller the app is registered to.
+func (ac *AppContext) Controller() *Controller {
+	return ac.controller
+}
+
+// Cookie gets the cookie of the app's namespace whose lower bits are
+// 'local'.
+func (ac *AppContext) Cookie(local uint64) uint64 {
+	return uint64(ac.Id)<<AppCookieShift | local&^AppCookieMask
+}
+
+// Owns reports whether the cookie belongs to the app's namespace.
+func (ac *AppContext) Owns(cookie uint64) bool {
+	return cookie&AppCookieMask == uint64(ac.Id)<<AppCookieShift
+}
+
+// Priority maps a priority relative to the app's band into the band, it
+// returns an error if the priority is beyond the band.
+func (ac *AppContext) Priority(relative uint16) (uint16, error) {
+	if relative > ac.PriorityMax-ac.PriorityMin {
+		return 0, fmt.Errorf("app %s: relative priority %d is beyond the priority band [%d, %d]",
+			ac.App.Name(), relative, ac.PriorityMin, ac.PriorityMax)
+	}
+	return ac.PriorityMin + relative, nil
+}
+
+// FlowMod writes the flow mod of a flow to the datapath, the flow's cookie
+// and priority are taken as relative to the app's namespace. With openflow
+// 1.3, modify and delete commands only touch the app's flows. Openflow 1.0
+// non-strict modify and delete commands ignore the cookie and the priority,
+// they could touch the flows of other apps and are rejected.
+func (ac *AppContext) FlowMod(dp *Datapath, f *flow.Flow, command flow.Command) error {
+	if dp.Version == ofp.OFP10_VERSION && (command == flow.Modify || command == flow.Delete) {
+		return fmt.Errorf("app %s: openflow 1.0 non-strict modify and delete aren't confined to the app's flows",
+			ac.App.Name())
+	}
+	priority, err := ac.Priority(f.Priority)
+	if err != nil {
+		return err
+	}
+	f2 := *f
+	f2.Cookie = ac.Cookie(f.Cookie)
+	f2.Priority = priority
+	if dp.Version != ofp.OFP10_VERSION && command != flow.Add {
+		f2.CookieMask = f.CookieMask | AppCookieMask
+	}
+	msg, err := f2.FlowMod(dp.Version, command)
+	if err != nil {
+		return err
+	}
+	return dp.Write(msg)
+}
+
+// FlowStats gets the statistics of the app's flows matching 'match' from an
+// openflow 1.0 datapath.
+func (ac *AppContext) FlowStats(ctx context.Context, dp *Datapath, match ofp10.Match) ([]ofp10.FlowStats, error) {
+	parts, err := dp.RequestMultipart(ctx, ofp10.NewFlowStatsRequest(match))
+	if err != nil {
+		return nil, err
+	}
+	var stats []ofp10.FlowStats
+	for _, part := range parts {
+		reply, ok := part.(*ofp10.StatsReply)
+		if !ok {
+			return nil, fmt.Errorf("unexpected reply %T to a flow statistics request", part)
+		}
+		entries, err := reply.FlowStats()
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			if ac.Owns(entry.Cookie) {
+				stats = append(stats, entry)
+			}
+		}
+	}
+	return stats, nil
+}
+
+// RegisterApp registers an app whose flows use the priority band
+// [priorityMin, priorityMax]. Bands of the apps must not overlap, the apps
+// form a chain in their registration order.
+func (c *Controller) RegisterApp(app App, priorityMin, priorityMax uint16) (*AppContext, error) {
+	if priorityMin > priorityMax {
+		return nil, fmt.Errorf("app %s: priority band [%d, %d] is empty", app.Name(), priorityMin, priorityMax)
+	}
+	c.mu.Lock()
+	for _, other := range c.apps {
+		if priorityMin <= other.PriorityMax && other.PriorityMin <= priorityMax {
+			c.mu.Unlock()
+			return nil, fmt.Errorf("app %s: priority band [%d, %d] overlaps the band of app %s",
+				app.Name(), priorityMin, priorityMax, other.App.Name())
+		}
+	}
+	if len(c.apps) >= AppMaxApps {
+		c.mu.Unlock()
+		return nil, fmt.Errorf("app %s: too many apps", app.Name())
+	}
+	ac := &AppContext{
+		App:         app,
+		Id:          uint16(len(c.apps) + 1),
+		PriorityMin: priorityMin,
+		PriorityMax: priorityMax,
+		controller:  c,
+	}
+	c.apps = append(c.apps, ac)
+	first := len(c.apps) == 1
+	c.mu.Unlock()
+
+	if first {
+		c.HandleFlowRemoved(c.routeFlowRemoved)
+	}
+	if a, ok := app.(AppInitializer); ok {
+		a.Init(ac)
+	}
+	if a, ok := app.(PacketInApp); ok {
+		c.HandlePacketIn(a.PacketIn)
+	}
+	if a, ok := app.(PortStatusApp); ok {
+		c.HandlePortStatus(func(dp *Datapath, msg *ofp10.PortStatus) Result {
+			a.PortStatus(dp, msg)
+			return Continue
+		})
+	}
+	if a, ok := app.(ConnectApp); ok {
+		c.HandleConnect(a.Connect)
+	}
+	if a, ok := app.(DisconnectApp); ok {
+		c.HandleDisconnect(a.Disconnect)
+	}
+	return ac, nil
+}
+
+// AppByCookie gets the context of the app owning the cookie, nil if no app
+// owns it.
+func (c *Controller) AppByCookie(cookie uint64) *AppContext {
+	id := int(cookie >> AppCookieShift)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if id < 1 || id > len(c.apps) {
+		return nil
+	}
+	return c.apps[id-1]
+}
+
+// routeFlowRemoved hands the flow removed messages to the app owning the
+// flow.
+func (c *Controller) routeFlowRemoved(dp *Datapath, msg *ofp10.FlowRemoved) Result {
+	if ac := c.AppByCookie(msg.Cookie); ac != nil {
+		if a, ok := ac.App.(FlowRemovedApp); ok {
+			a.FlowRemoved(dp, msg)
+		}
+	}
+	return Continue
+}
@@ -0,0 +1,437 @@
+// Package controller implements an openflow controller serving switches over
+// the connections of an ofnet.Manager.
+package controller
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/kuun/ofgo/ofnet"
+	"github.com/kuun/ofgo/ofp"
+	"github.com/kuun/ofgo/ofp10"
+	"github.com/kuun/ofgo/ofp13"
+)
+
+// Defaults of a Controller.
+const (
+	DefaultHandshakeTimeout = 10 * time.Second
+	DefaultEchoInterval     = 5 * time.Second
+	DefaultEchoMisses       = 3
+	DefaultEventQueueSize   = 256
+	DefaultRequestTimeout   = 10 * time.Second
+	DefaultSendQueueSize    = 1024
+)
+
+// Controller accepts switch connections, negotiates the openflow version,
+// learns the switch features and keeps a registry of the connected datapaths
+// keyed by Dpid. A switch reconnecting with the Dpid of a connected datapath
+// replaces the stale session.
+type Controller struct {
+	// Versions supported by the controller, openflow 1.3 and 1.0 if empty.
+	Versions []uint8
+	// HandshakeTimeout bounds the hello, features and port description
+	// exchanges, DefaultHandshakeTimeout if it's zero.
+	HandshakeTimeout time.Duration
+	// EchoInterval is the idle time after which an echo request is sent,
+	// DefaultEchoInterval if it's zero and no keepalive if it's negative.
+	EchoInterval time.Duration
+	// TLSConfig is used by the ssl and pssl endpoints of ListenAndServe.
+	TLSConfig *tls.Config
+	// DpidMapper, if set, verifies that TLS switches report the Dpid their
+	// certificate was issued for, and rejects the switches not using TLS.
+	DpidMapper ofnet.DpidMapper
+	// Workers bounds the number of datapaths whose handlers run at the same
+	// time, GOMAXPROCS if it's zero. The events of a datapath are always
+	// handled one at a time, in order.
+	Workers int
+	// EventQueueSize is the number of events of a datapath waiting for their
+	// handlers, DefaultEventQueueSize if it's zero. Reading from the datapath
+	// pauses while its queue is full, unless requests of the datapath are
+	// pending.
+	EventQueueSize int
+	// RequestTimeout bounds the requests to the datapaths whose context has
+	// no deadline, DefaultRequestTimeout if it's zero and no timeout if it's
+	// negative.
+	RequestTimeout time.Duration
+	// SendQueueSize is the number of messages to a datapath waiting to be
+	// written, per lane of its ofnet.SendQueue, DefaultSendQueueSize if it's
+	// zero.
+	SendQueueSize int
+	// SendQueuePolicy tells what a write does when the send queue of its
+	// datapath is full, it waits by default.
+	SendQueuePolicy ofnet.OverflowPolicy
+	// PanicHandler is called when a handler panics, the panic is logged if
+	// it's nil. The event goes on to the following handlers.
+	PanicHandler func(dp *Datapath, recovered interface{})
+
+	mu        sync.Mutex
+	datapaths map[uint64]*Datapath
+	aux       *ofnet.AuxRegistry
+	apps      []*AppContext // Registered apps, indexed by id - 1.
+
+	handlers    handlers
+	workersOnce sync.Once
+	workers     chan struct{} // Semaphore of the running handlers.
+}
+
+func New() *Controller {
+	return &Controller{
+		datapaths: make(map[uint64]*Datapath),
+		aux:       ofnet.NewAuxRegistry(),
+	}
+}
+
+// ListenAndServe serves the connections of the endpoints until the context is
+// done, e.g. "ptcp:6653" to accept switches or "tcp:10.0.0.1:6640" to dial
+// one.
+func (c *Controller) ListenAndServe(ctx context.Context, endpoints ...string) error {
+	m := &ofnet.Manager{
+		Serve:     c.Serve,
+		TLSConfig: c.TLSConfig,
+	}
+	return m.Run(ctx, endpoints...)
+}
+
+// Datapath gets a connected datapath, nil if it isn't connected.
+func (c *Controller) Datapath(dpid uint64) *Datapath {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.datapaths[dpid]
+}
+
+// Datapaths gets the connected datapaths sorted by Dpid.
+func (c *Controller) Datapaths() []*Datapath {
+	c.mu.Lock()
+	dps := make([]*Datapath, 0, len(c.datapaths))
+	for _, dp := range c.datapaths {
+		dps = append(dps, dp)
+	}
+	c.mu.Unlock()
+	sort.Slice(dps, func(i, j int) bool { return dps[i].Dpid < dps[j].Dpid })
+	return dps
+}
+
+func (c *Controller) versions() []uint8 {
+	if len(c.Versions) == 0 {
+		return []uint8{ofp.OFP13_VERSION, ofp.OFP10_VERSION}
+	}
+	return c.Versions
+}
+
+// hello creates the hello sent to switches, it carries a version bitmap when
+// a version above openflow 1.0 is supported.
+func (c *Controller) hello() *ofp.Hello {
+	versions := c.versions()
+	highest := versions[0]
+	for _, v := range versions {
+		if v > highest {
+			highest = v
+		}
+	}
+	if highest == ofp.OFP10_VERSION {
+		return ofp10.NewHello()
+	}
+	hello := ofp13.NewHello(versions...)
+	hello.Version = highest
+	return hello
+}
+
+// Serve runs the session of a switch connection until it ends, it's meant to
+// be the Serve function of an ofnet.Manager.
+func (c *Controller) Serve(ep *ofnet.Endpoint, conn *ofnet.Conn) error {
+	dp, auxId, err := c.handshake(conn)
+	if err != nil {
+		return err
+	}
+	if auxId != 0 {
+		return c.serveAuxiliary(dp.Dpid, auxId, conn)
+	}
+	// The send queue and the keepalive are set before the handlers can see
+	// the datapath.
+	size := c.SendQueueSize
+	if size <= 0 {
+		size = DefaultSendQueueSize
+	}
+	dp.queue = ofnet.NewSendQueue(dp.conn, size, c.SendQueuePolicy)
+	interval := c.EchoInterval
+	if interval == 0 {
+		interval = DefaultEchoInterval
+	}
+	if interval > 0 {
+		dp.keepalive = ofnet.NewKeepalive(dp.conn, interval, DefaultEchoMisses)
+		dp.keepalive.Send = dp.queue.Send
+	}
+	dp.requester.Send = dp.queue.Send
+	dp.requester.Timeout = c.RequestTimeout
+	if dp.requester.Timeout == 0 {
+		dp.requester.Timeout = DefaultRequestTimeout
+	}
+	c.startEvents(dp)
+	c.register(dp)
+	dp.post(event{connect: true})
+	err = c.serve(dp)
+	c.unregister(dp)
+	return err
+}
+
+// handshake exchanges hellos and gets the switch features. For auxiliary
+// connections only the Dpid of the returned datapath is set.
+func (c *Controller) handshake(conn *ofnet.Conn) (dp *Datapath, auxId uint8, err error) {
+	timeout := c.HandshakeTimeout
+	if timeout == 0 {
+		timeout = DefaultHandshakeTimeout
+	}
+	netConn := conn.NetConn()
+	netConn.SetDeadline(time.Now().Add(timeout))
+	defer netConn.SetDeadline(time.Time{})
+
+	local := c.hello()
+	local.Xid = conn.NextXid()
+	if err = conn.Write(local); err != nil {
+		return nil, 0, err
+	}
+	msg, err := conn.Read()
+	if err != nil {
+		return nil, 0, err
+	}
+	remote, ok := msg.(*ofp.Hello)
+	if !ok {
+		return nil, 0, errors.New("openflow switch didn't start with a hello")
+	}
+	version, err := ofp.Negotiate(local, remote)
+	if err != nil {
+		conn.Write(err.(*ofp.Error))
+		return nil, 0, err
+	}
+	conn.SetVersion(version)
+
+	var req *ofp.RawMessage
+	switch version {
+	case ofp.OFP10_VERSION:
+		req = ofp10.NewFeaturesRequest()
+	case ofp.OFP13_VERSION:
+		req = ofp13.NewFeaturesRequest()
+	default:
+		return nil, 0, fmt.Errorf("openflow version %#x is not supported", version)
+	}
+	req.Xid = conn.NextXid()
+	if err = conn.Write(req); err != nil {
+		return nil, 0, err
+	}
+	dp = newDatapath(conn)
+	dp.handlers = &c.handlers
+	dp.Version = version
+	for {
+		if msg, err = conn.Read(); err != nil {
+			return nil, 0, err
+		}
+		switch msg := msg.(type) {
+		case *ofp10.FeaturesReply:
+			dp.Dpid, dp.NBuffers, dp.NTables = msg.Dpid, msg.NBuffers, msg.NTables
+			dp.Capabilities, dp.Actions = msg.Capabilities, msg.Actions
+			for _, port := range msg.Ports {
+				dp.ports[port.PortNo] = port
+			}
+			return dp, 0, c.verify(conn, dp.Dpid)
+		case *ofp13.FeaturesReply:
+			dp.Dpid, dp.NBuffers, dp.NTables = msg.Dpid, msg.NBuffers, msg.NTables
+			dp.Capabilities = msg.Capabilities
+			if err = c.verify(conn, dp.Dpid); err != nil || msg.AuxiliaryId != 0 {
+				return dp, msg.AuxiliaryId, err
+			}
+			return dp, 0, c.portDesc(conn, dp)
+		case *ofp.EchoRequest:
+			if err = conn.Write(ofp.NewEchoResponse(msg)); err != nil {
+				return nil, 0, err
+			}
+		case *ofp.Error:
+			if msg.Xid == req.Xid {
+				return nil, 0, msg
+			}
+		}
+		// Asynchronous messages sent before the handshake completes are
+		// dropped.
+	}
+}
+
+// portDesc gets the ports of an openflow 1.3 datapath, its features reply
+// doesn't carry them.
+func (c *Controller) portDesc(conn *ofnet.Conn, dp *Datapath) error {
+	req := ofp13.NewPortDescRequest()
+	req.Xid = conn.NextXid()
+	if err := conn.Write(req); err != nil {
+		return err
+	}
+	for {
+		msg, err := conn.Read()
+		if err != nil {
+			return err
+		}
+		switch msg := msg.(type) {
+		case *ofp13.MultipartReply:
+			if msg.Xid != req.Xid {
+				continue
+			}
+			ports, err := msg.PortDesc()
+			if err != nil {
+				return err
+			}
+			for i := range ports {
+				if port, ok := ports[i].ToOfp10(); ok {
+					dp.ports[port.PortNo] = port
+				}
+			}
+			if !msg.More() {
+				return nil
+			}
+		case *ofp.EchoRequest:
+			if err = conn.Write(ofp.NewEchoResponse(msg)); err != nil {
+				return err
+			}
+		case *ofp.Error:
+			if msg.Xid == req.Xid {
+				return msg
+			}
+		}
+	}
+}
+
+// verify checks the Dpid against the certificate of TLS switches.
+func (c *Controller) verify(conn *ofnet.Conn, dpid uint64) error {
+	if c.DpidMapper == nil {
+		return nil
+	}
+	return conn.VerifyDpid(dpid, c.DpidMapper)
+}
+
+// register adds the datapath to the registry, replacing the stale session of
+// a datapath with the same Dpid.
+func (c *Controller) register(dp *Datapath) {
+	if dp.Version == ofp.OFP13_VERSION {
+		dp.group, _ = c.aux.Register(dp.Dpid, 0, dp.conn)
+		dp.group.SetSend(dp.queue.Send)
+	}
+	c.mu.Lock()
+	old := c.datapaths[dp.Dpid]
+	c.datapaths[dp.Dpid] = dp
+	c.mu.Unlock()
+	if old != nil {
+		old.Close(ErrDatapathReplaced)
+	}
+}
+
+// unregister removes the datapath from the registry unless it was replaced.
+func (c *Controller) unregister(dp *Datapath) {
+	c.mu.Lock()
+	if c.datapaths[dp.Dpid] == dp {
+		delete(c.datapaths, dp.Dpid)
+	}
+	c.mu.Unlock()
+	if dp.group != nil {
+		c.aux.Unregister(dp.Dpid, dp.conn)
+	}
+	dp.queue.Close()
+}
+
+// serve reads the messages of the datapath's main connection until it fails.
+func (c *Controller) serve(dp *Datapath) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if dp.keepalive != nil {
+		go func() {
+			if err := dp.keepalive.Run(ctx); err != nil && ctx.Err() == nil {
+				dp.Close(err)
+			}
+		}()
+	}
+
+	var err error
+	for {
+		var msg ofp.DataBlock
+		if msg, err = dp.conn.Read(); err != nil {
+			break
+		}
+		if dp.keepalive != nil {
+			var handled bool
+			if handled, err = dp.keepalive.Received(msg); err != nil {
+				break
+			}
+			if handled {
+				continue
+			}
+		} else if echo, ok := msg.(*ofp.EchoRequest); ok {
+			if err = dp.queue.Send(ofp.NewEchoResponse(echo)); err != nil {
+				break
+			}
+			continue
+		}
+		if dp.requester.Dispatch(msg) {
+			continue
+		}
+		if e, ok := msg.(*ofp.Error); ok && dp.watched(e) {
+			continue
+		}
+		switch status := msg.(type) {
+		case *ofp10.PortStatus:
+			if status.Reason == ofp10.OFPPR_DELETE {
+				dp.DeletePort(status.Desc.PortNo)
+			} else {
+				dp.SetPort(status.Desc)
+			}
+		case *ofp13.PortStatus:
+			port, ok := status.Desc.ToOfp10()
+			if ok && status.Reason == ofp13.OFPPR_DELETE {
+				dp.DeletePort(port.PortNo)
+			} else if ok {
+				dp.SetPort(port)
+			}
+		}
+		dp.post(event{msg: msg})
+	}
+
+	dp.mu.Lock()
+	if dp.err == nil {
+		dp.err = err
+	}
+	err = dp.err
+	dp.mu.Unlock()
+	dp.requester.Close(err)
+	dp.conn.Close()
+	c.stopEvents(dp, err)
+	close(dp.done)
+	return err
+}
+
+// serveAuxiliary reads the messages of an auxiliary connection and hands
+// them to the datapath of its main connection.
+func (c *Controller) serveAuxiliary(dpid uint64, auxId uint8, conn *ofnet.Conn) error {
+	if _, err := c.aux.Register(dpid, auxId, conn); err != nil {
+		return err
+	}
+	defer c.aux.Unregister(dpid, conn)
+	for {
+		msg, err := conn.Read()
+		if err != nil {
+			return err
+		}
+		if echo, ok := msg.(*ofp.EchoRequest); ok {
+			if err = conn.Write(ofp.NewEchoResponse(echo)); err != nil {
+				return err
+			}
+			continue
+		}
+		dp := c.Datapath(dpid)
+		if dp == nil {
+			continue
+		}
+		if e, ok := msg.(*ofp.Error); ok && dp.watched(e) {
+			continue
+		}
+		dp.post(event{msg: msg})
+	}
+}
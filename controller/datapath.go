@@ -0,0 +1,208 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"sync/atomic"
+
+	"github.com/kuun/ofgo/ofnet"
+	"github.com/kuun/ofgo/ofp"
+	"github.com/kuun/ofgo/ofp10"
+)
+
+// ErrDatapathReplaced ends the session of a datapath when the datapath
+// connects again with the same Dpid.
+var ErrDatapathReplaced = errors.New("openflow datapath reconnected, session replaced")
+
+// Datapath is a switch connected to the controller, it's created once the
+// handshake with the switch completes.
+type Datapath struct {
+	droppedEvents uint64 // Accessed atomically, first for 64-bit alignment.
+
+	Dpid         uint64 // Datapath id reported in the features reply.
+	Version      uint8  // Negotiated openflow version.
+	NBuffers     uint32 // Max packets buffered at once.
+	NTables      uint8  // Number of tables supported by the datapath.
+	Capabilities uint32 // Bitmap of OFPC_* of the negotiated version.
+	Actions      uint32 // Bitmap of supported OFPAT_*, openflow 1.0 only.
+
+	conn      *ofnet.Conn
+	group     *ofnet.ConnGroup // Main and auxiliary connections, openflow 1.3 only.
+	queue     *ofnet.SendQueue // Queue of the main connection.
+	requester *ofnet.Requester
+	keepalive *ofnet.Keepalive
+	handlers  *handlers
+
+	events         chan event
+	eventsDone     chan struct{}
+	requests       int32         // Pending requests, accessed atomically.
+	requestStarted chan struct{} // Wakes a post waiting for room when a request starts.
+	backlogReady   chan struct{} // Wakes the event loop when the backlog gets an event.
+
+	mu      sync.Mutex
+	backlog []event // Events which found the queue full while requests were pending.
+	ports   map[uint16]ofp10.Port
+	watches []*ErrorWatch
+	err     error
+	done    chan struct{}
+}
+
+func newDatapath(conn *ofnet.Conn) *Datapath {
+	return &Datapath{
+		conn:       conn,
+		requester:  ofnet.NewRequester(conn),
+		ports:      make(map[uint16]ofp10.Port),
+		eventsDone: make(chan struct{}),
+		done:       make(chan struct{}),
+
+		requestStarted: make(chan struct{}, 1),
+		backlogReady:   make(chan struct{}, 1),
+	}
+}
+
+// Conn gets the main connection of the datapath.
+func (dp *Datapath) Conn() *ofnet.Conn {
+	return dp.conn
+}
+
+// Ports gets the ports of the datapath sorted by port number. Openflow 1.0
+// switches report their ports in the features reply, the ports of openflow
+// 1.3 switches are requested after it. Openflow 1.3 ports are converted to
+// their openflow 1.0 form, the ones whose number doesn't fit are left out.
+func (dp *Datapath) Ports() []ofp10.Port {
+	dp.mu.Lock()
+	defer dp.mu.Unlock()
+	ports := make([]ofp10.Port, 0, len(dp.ports))
+	for _, port := range dp.ports {
+		ports = append(ports, port)
+	}
+	sort.Slice(ports, func(i, j int) bool { return ports[i].PortNo < ports[j].PortNo })
+	return ports
+}
+
+// Port gets a port of the datapath.
+func (dp *Datapath) Port(portNo uint16) (port ofp10.Port, ok bool) {
+	dp.mu.Lock()
+	defer dp.mu.Unlock()
+	port, ok = dp.ports[portNo]
+	return port, ok
+}
+
+// SetPort adds or updates a port of the datapath.
+func (dp *Datapath) SetPort(port ofp10.Port) {
+	dp.mu.Lock()
+	dp.ports[port.PortNo] = port
+	dp.mu.Unlock()
+}
+
+// DeletePort removes a port of the datapath.
+func (dp *Datapath) DeletePort(portNo uint16) {
+	dp.mu.Lock()
+	delete(dp.ports, portNo)
+	dp.mu.Unlock()
+}
+
+// Write queues a message to the datapath, it doesn't wait for a slow
+// datapath unless its send queue is full. Openflow 1.3 packet outs are sent
+// over the auxiliary connections if there are any. The write handlers see the
+// message once it's queued.
+func (dp *Datapath) Write(msg ofp.DataBlock) error {
+	var err error
+	switch {
+	case dp.group != nil:
+		err = dp.group.Write(msg)
+	case dp.queue != nil:
+		err = dp.queue.Send(msg)
+	default:
+		err = dp.conn.Write(msg)
+	}
+	if err != nil || dp.handlers == nil {
+		return err
+	}
+	for _, h := range dp.handlers.snapshot().write {
+		h(dp, msg)
+	}
+	return nil
+}
+
+// Request sends a request to the datapath and waits for its reply. Handlers
+// may make requests, the packet ins finding the event queue full are then
+// dropped until the requests complete, the other events are kept aside.
+func (dp *Datapath) Request(ctx context.Context, req ofp.Message) (ofp.DataBlock, error) {
+	defer dp.startRequest()()
+	return dp.requester.Request(ctx, req)
+}
+
+// RequestMultipart sends a request to the datapath and waits for all parts of
+// its statistics or multipart reply.
+func (dp *Datapath) RequestMultipart(ctx context.Context, req ofp.Message) ([]ofp.DataBlock, error) {
+	defer dp.startRequest()()
+	return dp.requester.RequestMultipart(ctx, req)
+}
+
+// startRequest counts a pending request, it returns the function to call
+// once the request completes.
+func (dp *Datapath) startRequest() func() {
+	atomic.AddInt32(&dp.requests, 1)
+	select {
+	case dp.requestStarted <- struct{}{}:
+	default:
+	}
+	return func() { atomic.AddInt32(&dp.requests, -1) }
+}
+
+// DroppedEvents gets the number of packet ins dropped because the event queue
+// was full while requests were pending.
+func (dp *Datapath) DroppedEvents() uint64 {
+	return atomic.LoadUint64(&dp.droppedEvents)
+}
+
+// QueueStats gets the metrics of the send queue of the main connection.
+func (dp *Datapath) QueueStats() ofnet.QueueStats {
+	if dp.queue == nil {
+		return ofnet.QueueStats{}
+	}
+	return dp.queue.Stats()
+}
+
+// BatchStats gets the metrics of the coalesced writes of the main
+// connection.
+func (dp *Datapath) BatchStats() ofnet.BatchStats {
+	if dp.queue == nil {
+		return ofnet.BatchStats{}
+	}
+	return dp.queue.BatchStats()
+}
+
+// KeepaliveStats gets the liveness metrics of the main connection.
+func (dp *Datapath) KeepaliveStats() ofnet.KeepaliveStats {
+	if dp.keepalive == nil {
+		return ofnet.KeepaliveStats{}
+	}
+	return dp.keepalive.Stats()
+}
+
+// Close ends the session of the datapath with the error, its connections
+// are closed.
+func (dp *Datapath) Close(err error) {
+	dp.mu.Lock()
+	if dp.err == nil {
+		dp.err = err
+	}
+	dp.mu.Unlock()
+	dp.conn.Close()
+}
+
+// Done is closed when the session of the datapath ended.
+func (dp *Datapath) Done() <-chan struct{} {
+	return dp.done
+}
+
+// Err gets why the session ended, nil while it's running.
+func (dp *Datapath) Err() error {
+	dp.mu.Lock()
+	defer dp.mu.Unlock()
+	return dp.err
+}
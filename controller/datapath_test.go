@@ -0,0 +1,91 @@
+package controller
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/kuun/ofgo/ofnet"
+	"github.com/kuun/ofgo/ofp"
+	"github.com/kuun/ofgo/ofp10"
+)
+
+// connectSwitch serves a fake openflow 1.0 switch over a pipe, it returns the
+// switch end once the handshake completed and the datapath is registered.
+func connectSwitch(t *testing.T, c *Controller, dpid uint64) (*ofnet.Conn, *Datapath) {
+	t.Helper()
+	controllerEnd, switchEnd := net.Pipe()
+	t.Cleanup(func() {
+		controllerEnd.Close()
+		switchEnd.Close()
+	})
+	go c.Serve(nil, ofnet.NewConn(controllerEnd))
+
+	sw := ofnet.NewConn(switchEnd)
+	if _, err := sw.Read(); err != nil {
+		t.Fatal(err)
+	}
+	if err := sw.Write(ofp10.NewHello()); err != nil {
+		t.Fatal(err)
+	}
+	sw.SetVersion(ofp.OFP10_VERSION)
+	msg, err := sw.Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	reply := &ofp10.FeaturesReply{
+		Header: ofp.Header{
+			Version: ofp.OFP10_VERSION,
+			Type:    ofp10.OFPT_FEATURES_REPLY,
+			Length:  32,
+			Xid:     msg.(ofp.Message).MessageHeader().Xid,
+		},
+		Dpid: dpid,
+	}
+	if err = sw.Write(reply); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		if dp := c.Datapath(dpid); dp != nil {
+			return sw, dp
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatal("datapath didn't register")
+	return nil, nil
+}
+
+// TestWriteStalledPeer checks a switch which stops reading doesn't block the
+// writers while its send queue has room.
+func TestWriteStalledPeer(t *testing.T) {
+	c := New()
+	c.EchoInterval = -1
+	c.SendQueueSize = 64
+	_, dp := connectSwitch(t, c, 1)
+
+	// The pipe has no buffer, nothing is read by the switch from now on.
+	done := make(chan error, 1)
+	go func() {
+		for i := 0; i < c.SendQueueSize; i++ {
+			fm := ofp10.NewFlowMod()
+			fm.Match.Wildcards = ofp10.OFPFW_ALL
+			if err := dp.Write(fm); err != nil {
+				done <- err
+				return
+			}
+		}
+		done <- nil
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Write blocked on a stalled switch")
+	}
+	if stats := dp.QueueStats(); stats.Enqueued != uint64(c.SendQueueSize) {
+		t.Errorf("enqueued %d messages, want %d", stats.Enqueued, c.SendQueueSize)
+	}
+}
@@ -0,0 +1,79 @@
+package controller
+
+import (
+	"sync"
+
+	"github.com/kuun/ofgo/ofp"
+)
+
+// ErrorWatch collects the error messages a datapath sends about some of the
+// messages written to it, identified by their Xid. The watched errors are
+// consumed, the error handlers don't see them.
+//
+// Errors are collected by the reader of the datapath, so once the reply of
+// a barrier written after the messages is received, their errors are in the
+// watch.
+type ErrorWatch struct {
+	dp   *Datapath
+	xids map[uint32]bool
+
+	mu   sync.Mutex
+	errs map[uint32]*ofp.Error
+}
+
+// WatchErrors starts collecting the errors about the messages with the
+// Xids, the watch must be stopped once the messages are done with.
+func (dp *Datapath) WatchErrors(xids ...uint32) *ErrorWatch {
+	w := &ErrorWatch{
+		dp:   dp,
+		xids: make(map[uint32]bool, len(xids)),
+		errs: make(map[uint32]*ofp.Error),
+	}
+	for _, xid := range xids {
+		w.xids[xid] = true
+	}
+	dp.mu.Lock()
+	dp.watches = append(dp.watches, w)
+	dp.mu.Unlock()
+	return w
+}
+
+// Errors gets the errors collected so far, by Xid.
+func (w *ErrorWatch) Errors() map[uint32]*ofp.Error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	errs := make(map[uint32]*ofp.Error, len(w.errs))
+	for xid, err := range w.errs {
+		errs[xid] = err
+	}
+	return errs
+}
+
+// Stop stops collecting errors.
+func (w *ErrorWatch) Stop() {
+	dp := w.dp
+	dp.mu.Lock()
+	defer dp.mu.Unlock()
+	for i, other := range dp.watches {
+		if other == w {
+			dp.watches = append(dp.watches[:i], dp.watches[i+1:]...)
+			break
+		}
+	}
+}
+
+// watched hands an error to the watch waiting for it, it returns false if
+// no watch waits for it.
+func (dp *Datapath) watched(msg *ofp.Error) bool {
+	dp.mu.Lock()
+	defer dp.mu.Unlock()
+	for _, w := range dp.watches {
+		if w.xids[msg.Xid] {
+			w.mu.Lock()
+			w.errs[msg.Xid] = msg
+			w.mu.Unlock()
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,369 @@
+package controller
+
+import (
+	"log"
+	"runtime"
+	"runtime/debug"
+	"sync"
+	"sync/atomic"
+
+	"github.com/kuun/ofgo/ofp"
+	"github.com/kuun/ofgo/ofp10"
+	"github.com/kuun/ofgo/ofp13"
+)
+
+// Result tells whether an event goes on to the following handlers.
+type Result int
+
+const (
+	Continue Result = iota // Let the following handlers see the event.
+	Stop                   // Stop the propagation of the event.
+)
+
+// Handlers of the datapath events. Handlers of an event run in the order
+// they were registered, one event of a datapath at a time.
+type (
+	PacketInHandler    func(dp *Datapath, msg *ofp10.PacketIn) Result
+	FlowRemovedHandler func(dp *Datapath, msg *ofp10.FlowRemoved) Result
+	PortStatusHandler  func(dp *Datapath, msg *ofp10.PortStatus) Result
+	ErrorHandler       func(dp *Datapath, msg *ofp.Error) Result
+	// Handlers of the openflow 1.3 messages.
+	PacketIn13Handler   func(dp *Datapath, msg *ofp13.PacketIn) Result
+	PortStatus13Handler func(dp *Datapath, msg *ofp13.PortStatus) Result
+	// MessageHandler sees every message after the typed handlers of the
+	// message, including the messages without a typed handler such as the
+	// openflow 1.3 flow removed messages.
+	MessageHandler    func(dp *Datapath, msg ofp.DataBlock) Result
+	ConnectHandler    func(dp *Datapath)
+	DisconnectHandler func(dp *Datapath, err error)
+	// WriteHandler sees the messages written to the datapath, it runs in the
+	// goroutine of the writer.
+	WriteHandler func(dp *Datapath, msg ofp.DataBlock)
+)
+
+type handlerSet struct {
+	packetIn     []PacketInHandler
+	flowRemoved  []FlowRemovedHandler
+	portStatus   []PortStatusHandler
+	error        []ErrorHandler
+	packetIn13   []PacketIn13Handler
+	portStatus13 []PortStatus13Handler
+	message      []MessageHandler
+	connect      []ConnectHandler
+	disconnect   []DisconnectHandler
+	write        []WriteHandler
+}
+
+type handlers struct {
+	mu sync.Mutex
+	handlerSet
+}
+
+// snapshot gets the registered handlers, handlers registered later don't
+// see the events being handled.
+func (h *handlers) snapshot() handlerSet {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.handlerSet
+}
+
+// HandlePacketIn registers a handler of the openflow 1.0 packet ins.
+func (c *Controller) HandlePacketIn(h PacketInHandler) {
+	c.handlers.mu.Lock()
+	c.handlers.packetIn = append(c.handlers.packetIn, h)
+	c.handlers.mu.Unlock()
+}
+
+// HandleFlowRemoved registers a handler of the openflow 1.0 flow removed
+// messages.
+func (c *Controller) HandleFlowRemoved(h FlowRemovedHandler) {
+	c.handlers.mu.Lock()
+	c.handlers.flowRemoved = append(c.handlers.flowRemoved, h)
+	c.handlers.mu.Unlock()
+}
+
+// HandlePortStatus registers a handler of the openflow 1.0 port status
+// messages, the ports of the datapath are already updated when it runs.
+func (c *Controller) HandlePortStatus(h PortStatusHandler) {
+	c.handlers.mu.Lock()
+	c.handlers.portStatus = append(c.handlers.portStatus, h)
+	c.handlers.mu.Unlock()
+}
+
+// HandlePacketIn13 registers a handler of the openflow 1.3 packet ins.
+func (c *Controller) HandlePacketIn13(h PacketIn13Handler) {
+	c.handlers.mu.Lock()
+	c.handlers.packetIn13 = append(c.handlers.packetIn13, h)
+	c.handlers.mu.Unlock()
+}
+
+// HandlePortStatus13 registers a handler of the openflow 1.3 port status
+// messages, the ports of the datapath are already updated when it runs.
+func (c *Controller) HandlePortStatus13(h PortStatus13Handler) {
+	c.handlers.mu.Lock()
+	c.handlers.portStatus13 = append(c.handlers.portStatus13, h)
+	c.handlers.mu.Unlock()
+}
+
+// HandleError registers a handler of the error messages which aren't the
+// reply of a request.
+func (c *Controller) HandleError(h ErrorHandler) {
+	c.handlers.mu.Lock()
+	c.handlers.error = append(c.handlers.error, h)
+	c.handlers.mu.Unlock()
+}
+
+// HandleMessage registers a handler of all the messages.
+func (c *Controller) HandleMessage(h MessageHandler) {
+	c.handlers.mu.Lock()
+	c.handlers.message = append(c.handlers.message, h)
+	c.handlers.mu.Unlock()
+}
+
+// HandleConnect registers a handler of the datapaths completing their
+// handshake, it runs before any message handler of the datapath.
+func (c *Controller) HandleConnect(h ConnectHandler) {
+	c.handlers.mu.Lock()
+	c.handlers.connect = append(c.handlers.connect, h)
+	c.handlers.mu.Unlock()
+}
+
+// HandleDisconnect registers a handler of the datapaths whose session ended,
+// it runs after all message handlers of the datapath.
+func (c *Controller) HandleDisconnect(h DisconnectHandler) {
+	c.handlers.mu.Lock()
+	c.handlers.disconnect = append(c.handlers.disconnect, h)
+	c.handlers.mu.Unlock()
+}
+
+// HandleWrite registers a handler of the messages written to the datapaths,
+// e.g. to track the flow mods sent by the apps.
+func (c *Controller) HandleWrite(h WriteHandler) {
+	c.handlers.mu.Lock()
+	c.handlers.write = append(c.handlers.write, h)
+	c.handlers.mu.Unlock()
+}
+
+// event is an event of a datapath waiting for its handlers.
+type event struct {
+	msg        ofp.DataBlock
+	connect    bool
+	disconnect bool
+	err        error // Why the session ended, for disconnect events.
+}
+
+// isPacketIn reports whether the event is a packet in, the only events which
+// may be dropped.
+func (e *event) isPacketIn() bool {
+	switch e.msg.(type) {
+	case *ofp10.PacketIn, *ofp13.PacketIn:
+		return true
+	}
+	return false
+}
+
+// post queues an event of the datapath, it waits while the queue is full.
+// Events posted after the session ended are dropped.
+//
+// A handler waiting for the reply of a request holds the event loop, so
+// while requests of the datapath are pending the reader must not wait: an
+// event finding the queue full goes to an unbounded backlog instead, handled
+// after the queue. Packet ins are dropped rather than queued in the backlog,
+// and the packet ins posted while the backlog isn't empty are dropped as
+// well. No other event is ever dropped, so that the state kept by the
+// handlers, e.g. from the port status and flow removed messages, stays in
+// sync with the datapath.
+func (dp *Datapath) post(e event) {
+	for {
+		if dp.postBacklog(e) {
+			return
+		}
+		if atomic.LoadInt32(&dp.requests) > 0 {
+			select {
+			case dp.events <- e:
+			case <-dp.eventsDone:
+			default:
+				dp.overflow(e)
+			}
+			return
+		}
+		select {
+		case dp.events <- e:
+			return
+		case <-dp.eventsDone:
+			return
+		case <-dp.requestStarted:
+			// Check again whether the event may go to the backlog.
+		}
+	}
+}
+
+// postBacklog queues the event behind the backlog, if it isn't empty, so that
+// the events stay in order. It reports whether the event was handled.
+func (dp *Datapath) postBacklog(e event) bool {
+	dp.mu.Lock()
+	defer dp.mu.Unlock()
+	if len(dp.backlog) == 0 {
+		return false
+	}
+	if e.isPacketIn() {
+		atomic.AddUint64(&dp.droppedEvents, 1)
+	} else {
+		dp.backlog = append(dp.backlog, e)
+	}
+	return true
+}
+
+// overflow drops a packet in, or queues another event in the backlog.
+func (dp *Datapath) overflow(e event) {
+	if e.isPacketIn() {
+		atomic.AddUint64(&dp.droppedEvents, 1)
+		return
+	}
+	dp.mu.Lock()
+	dp.backlog = append(dp.backlog, e)
+	dp.mu.Unlock()
+	select {
+	case dp.backlogReady <- struct{}{}:
+	default:
+	}
+}
+
+// nextEvent waits for the next event of the datapath, the queued events come
+// before the backlog.
+func (dp *Datapath) nextEvent() event {
+	for {
+		select {
+		case e := <-dp.events:
+			return e
+		default:
+		}
+		dp.mu.Lock()
+		if len(dp.backlog) > 0 {
+			e := dp.backlog[0]
+			dp.backlog[0] = event{}
+			dp.backlog = dp.backlog[1:]
+			dp.mu.Unlock()
+			return e
+		}
+		dp.mu.Unlock()
+		select {
+		case e := <-dp.events:
+			return e
+		case <-dp.backlogReady:
+		}
+	}
+}
+
+// startEvents starts the event loop of a datapath.
+func (c *Controller) startEvents(dp *Datapath) {
+	c.workersOnce.Do(func() {
+		workers := c.Workers
+		if workers <= 0 {
+			workers = runtime.GOMAXPROCS(0)
+		}
+		c.workers = make(chan struct{}, workers)
+	})
+	size := c.EventQueueSize
+	if size <= 0 {
+		size = DefaultEventQueueSize
+	}
+	dp.events = make(chan event, size)
+	go c.runEvents(dp)
+}
+
+// stopEvents posts the disconnect event of a datapath and waits for the
+// event loop to handle it.
+func (c *Controller) stopEvents(dp *Datapath, err error) {
+	dp.post(event{disconnect: true, err: err})
+	<-dp.eventsDone
+}
+
+func (c *Controller) runEvents(dp *Datapath) {
+	defer close(dp.eventsDone)
+	for {
+		e := dp.nextEvent()
+		c.workers <- struct{}{}
+		c.handle(dp, e)
+		<-c.workers
+		if e.disconnect {
+			return
+		}
+	}
+}
+
+// handle runs the handlers of an event.
+func (c *Controller) handle(dp *Datapath, e event) {
+	h := c.handlers.snapshot()
+	switch {
+	case e.connect:
+		for _, f := range h.connect {
+			c.call(dp, func() Result { f(dp); return Continue })
+		}
+		return
+	case e.disconnect:
+		for _, f := range h.disconnect {
+			c.call(dp, func() Result { f(dp, e.err); return Continue })
+		}
+		return
+	}
+
+	switch msg := e.msg.(type) {
+	case *ofp10.PacketIn:
+		for _, f := range h.packetIn {
+			if c.call(dp, func() Result { return f(dp, msg) }) == Stop {
+				return
+			}
+		}
+	case *ofp10.FlowRemoved:
+		for _, f := range h.flowRemoved {
+			if c.call(dp, func() Result { return f(dp, msg) }) == Stop {
+				return
+			}
+		}
+	case *ofp10.PortStatus:
+		for _, f := range h.portStatus {
+			if c.call(dp, func() Result { return f(dp, msg) }) == Stop {
+				return
+			}
+		}
+	case *ofp13.PacketIn:
+		for _, f := range h.packetIn13 {
+			if c.call(dp, func() Result { return f(dp, msg) }) == Stop {
+				return
+			}
+		}
+	case *ofp13.PortStatus:
+		for _, f := range h.portStatus13 {
+			if c.call(dp, func() Result { return f(dp, msg) }) == Stop {
+				return
+			}
+		}
+	case *ofp.Error:
+		for _, f := range h.error {
+			if c.call(dp, func() Result { return f(dp, msg) }) == Stop {
+				return
+			}
+		}
+	}
+	for _, f := range h.message {
+		if c.call(dp, func() Result { return f(dp, e.msg) }) == Stop {
+			return
+		}
+	}
+}
+
+// call runs a handler, a panicking handler is reported and the event goes on.
+func (c *Controller) call(dp *Datapath, f func() Result) (result Result) {
+	defer func() {
+		if v := recover(); v != nil {
+			if c.PanicHandler != nil {
+				c.PanicHandler(dp, v)
+			} else {
+				log.Printf("openflow handler of datapath %016x panicked: %v\n%s", dp.Dpid, v, debug.Stack())
+			}
+			result = Continue
+		}
+	}()
+	return f()
+}
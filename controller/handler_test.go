@@ -0,0 +1,95 @@
+package controller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/kuun/ofgo/ofp"
+	"github.com/kuun/ofgo/ofp10"
+)
+
+// TestPostDuringRequest checks a handler waiting for a reply doesn't block
+// the reader, and that only packet ins are dropped meanwhile.
+func TestPostDuringRequest(t *testing.T) {
+	c := New()
+	c.EchoInterval = -1
+	c.EventQueueSize = 2
+
+	const packetIns, portStatuses = 10, 10
+	replied := make(chan error, 1)
+	statuses := make(chan uint16, portStatuses)
+	first := true
+	c.HandlePacketIn(func(dp *Datapath, msg *ofp10.PacketIn) Result {
+		if first {
+			first = false
+			_, err := dp.Request(context.Background(), ofp10.NewBarrierRequest())
+			replied <- err
+		}
+		return Continue
+	})
+	c.HandlePortStatus(func(dp *Datapath, msg *ofp10.PortStatus) Result {
+		statuses <- msg.Desc.PortNo
+		return Continue
+	})
+	sw, dp := connectSwitch(t, c, 1)
+
+	packetIn := &ofp10.PacketIn{Header: ofp.Header{
+		Version: ofp.OFP10_VERSION,
+		Type:    ofp10.OFPT_PACKET_IN,
+		Length:  18,
+	}}
+	if err := sw.Write(packetIn); err != nil {
+		t.Fatal(err)
+	}
+	var xid uint32
+	for xid == 0 {
+		msg, err := sw.Read()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if header := msg.(ofp.Message).MessageHeader(); header.Type == ofp10.OFPT_BARRIER_REQUEST {
+			xid = header.Xid
+		}
+	}
+	// The handler waits for the reply, the events fill the queue.
+	for i := 0; i < packetIns; i++ {
+		if err := sw.Write(packetIn); err != nil {
+			t.Fatal(err)
+		}
+		status := ofp10.NewPortStatus()
+		status.Reason = ofp10.OFPPR_MODIFY
+		status.Desc.PortNo = uint16(i + 1)
+		if err := sw.Write(status); err != nil {
+			t.Fatal(err)
+		}
+	}
+	reply := ofp10.NewBarrierRequest()
+	reply.Type = ofp10.OFPT_BARRIER_REPLY
+	reply.Xid = xid
+	if err := sw.Write(reply); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case err := <-replied:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("request blocked by the full event queue")
+	}
+
+	for i := 0; i < portStatuses; i++ {
+		select {
+		case portNo := <-statuses:
+			if portNo != uint16(i+1) {
+				t.Fatalf("port status of port %d handled, want port %d", portNo, i+1)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("%d port statuses handled, want %d", i, portStatuses)
+		}
+	}
+	if dropped := dp.DroppedEvents(); dropped > packetIns {
+		t.Errorf("%d events dropped, only %d packet ins may be", dropped, packetIns)
+	}
+}
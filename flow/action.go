@@ -0,0 +1,275 @@
+package flow
+
+import (
+	"fmt"
+
+	"github.com/kuun/ofgo/ofp"
+	"github.com/kuun/ofgo/ofp10"
+	"github.com/kuun/ofgo/ofp13"
+)
+
+// Action is a version neutral action, it renders into the actions of the
+// negotiated openflow version.
+type Action interface {
+	render10(f *Flow) ([]ofp10.Action, error)
+	render13(f *Flow) ([]ofp13.Action, error)
+}
+
+// UnsupportedActionError is returned when an action has no equivalent in the
+// openflow version a flow is rendered into.
+type UnsupportedActionError struct {
+	Action  Action
+	Version uint8
+}
+
+func (e *UnsupportedActionError) Error() string {
+	return fmt.Sprintf("flow: action %T has no equivalent in openflow version %#x", e.Action, e.Version)
+}
+
+func unsupported10(action Action) error {
+	return &UnsupportedActionError{Action: action, Version: ofp.OFP10_VERSION}
+}
+
+func unsupported13(action Action) error {
+	return &UnsupportedActionError{Action: action, Version: ofp.OFP13_VERSION}
+}
+
+// port10 converts a port number to openflow 1.0.
+func port10(action Action, port uint32) (uint16, error) {
+	p, ok := ofp13.PortToOfp10(port)
+	if !ok {
+		return 0, unsupported10(action)
+	}
+	return p, nil
+}
+
+// Output sends packets out of the port. Port numbers use the openflow 1.3
+// numbering, e.g. ofp13.OFPP_FLOOD, and are converted for openflow 1.0.
+type Output struct {
+	Port   uint32
+	MaxLen uint16 // Max bytes to send when the port is the controller.
+}
+
+func (a *Output) render10(f *Flow) ([]ofp10.Action, error) {
+	port, err := port10(a, a.Port)
+	if err != nil {
+		return nil, err
+	}
+	action := ofp10.NewActionOutput()
+	action.Port = port
+	action.MaxLen = a.MaxLen
+	return []ofp10.Action{action}, nil
+}
+
+func (a *Output) render13(f *Flow) ([]ofp13.Action, error) {
+	action := ofp13.NewActionOutput(a.Port)
+	action.MaxLen = a.MaxLen
+	return []ofp13.Action{action}, nil
+}
+
+// Enqueue sends packets out of the port through the queue. In openflow 1.3 it
+// renders into a set queue action followed by an output action.
+type Enqueue struct {
+	Port    uint32
+	QueueId uint32
+}
+
+func (a *Enqueue) render10(f *Flow) ([]ofp10.Action, error) {
+	port, err := port10(a, a.Port)
+	if err != nil {
+		return nil, err
+	}
+	action := ofp10.NewActionEnqueue()
+	action.Port = port
+	action.QueueId = a.QueueId
+	return []ofp10.Action{action}, nil
+}
+
+func (a *Enqueue) render13(f *Flow) ([]ofp13.Action, error) {
+	return []ofp13.Action{ofp13.NewActionSetQueue(a.QueueId), ofp13.NewActionOutput(a.Port)}, nil
+}
+
+// Group processes packets through the group, openflow 1.0 has no groups.
+type Group struct {
+	GroupId uint32
+}
+
+func (a *Group) render10(f *Flow) ([]ofp10.Action, error) {
+	return nil, unsupported10(a)
+}
+
+func (a *Group) render13(f *Flow) ([]ofp13.Action, error) {
+	return []ofp13.Action{ofp13.NewActionGroup(a.GroupId)}, nil
+}
+
+// SetVlanVid sets the 802.1q VLAN id.
+type SetVlanVid struct {
+	VlanId uint16
+}
+
+func (a *SetVlanVid) render10(f *Flow) ([]ofp10.Action, error) {
+	action := ofp10.NewActionVlanVid()
+	action.VlanVid = a.VlanId
+	return []ofp10.Action{action}, nil
+}
+
+func (a *SetVlanVid) render13(f *Flow) ([]ofp13.Action, error) {
+	return setField13(ofp13.OFPXMT_OFB_VLAN_VID, uint16Value(a.VlanId&0x0fff|ofp13.OFPVID_PRESENT)), nil
+}
+
+// SetVlanPcp sets the 802.1q priority.
+type SetVlanPcp struct {
+	VlanPcp uint8
+}
+
+func (a *SetVlanPcp) render10(f *Flow) ([]ofp10.Action, error) {
+	action := ofp10.NewActionVlanPcp()
+	action.VlanPcp = a.VlanPcp
+	return []ofp10.Action{action}, nil
+}
+
+func (a *SetVlanPcp) render13(f *Flow) ([]ofp13.Action, error) {
+	return setField13(ofp13.OFPXMT_OFB_VLAN_PCP, []byte{a.VlanPcp}), nil
+}
+
+// StripVlan strips the outer 802.1q header.
+type StripVlan struct{}
+
+func (a *StripVlan) render10(f *Flow) ([]ofp10.Action, error) {
+	return []ofp10.Action{ofp10.NewActionStripVlan()}, nil
+}
+
+func (a *StripVlan) render13(f *Flow) ([]ofp13.Action, error) {
+	return []ofp13.Action{ofp13.NewActionPopVlan()}, nil
+}
+
+// SetEthSrc sets the ethernet source address.
+type SetEthSrc struct {
+	Addr [ofp10.OFP_ETH_ALAN]byte
+}
+
+func (a *SetEthSrc) render10(f *Flow) ([]ofp10.Action, error) {
+	action := ofp10.NewActionDlSrc()
+	action.Addr = a.Addr
+	return []ofp10.Action{action}, nil
+}
+
+func (a *SetEthSrc) render13(f *Flow) ([]ofp13.Action, error) {
+	return setField13(ofp13.OFPXMT_OFB_ETH_SRC, append([]byte(nil), a.Addr[:]...)), nil
+}
+
+// SetEthDst sets the ethernet destination address.
+type SetEthDst struct {
+	Addr [ofp10.OFP_ETH_ALAN]byte
+}
+
+func (a *SetEthDst) render10(f *Flow) ([]ofp10.Action, error) {
+	action := ofp10.NewActionDlDst()
+	action.Addr = a.Addr
+	return []ofp10.Action{action}, nil
+}
+
+func (a *SetEthDst) render13(f *Flow) ([]ofp13.Action, error) {
+	return setField13(ofp13.OFPXMT_OFB_ETH_DST, append([]byte(nil), a.Addr[:]...)), nil
+}
+
+// SetIPv4Src sets the IPv4 source address.
+type SetIPv4Src struct {
+	Addr uint32
+}
+
+func (a *SetIPv4Src) render10(f *Flow) ([]ofp10.Action, error) {
+	action := ofp10.NewActionNwSrc()
+	action.Addr = a.Addr
+	return []ofp10.Action{action}, nil
+}
+
+func (a *SetIPv4Src) render13(f *Flow) ([]ofp13.Action, error) {
+	return setField13(ofp13.OFPXMT_OFB_IPV4_SRC, uint32Value(a.Addr)), nil
+}
+
+// SetIPv4Dst sets the IPv4 destination address.
+type SetIPv4Dst struct {
+	Addr uint32
+}
+
+func (a *SetIPv4Dst) render10(f *Flow) ([]ofp10.Action, error) {
+	action := ofp10.NewActionNwDst()
+	action.Addr = a.Addr
+	return []ofp10.Action{action}, nil
+}
+
+func (a *SetIPv4Dst) render13(f *Flow) ([]ofp13.Action, error) {
+	return setField13(ofp13.OFPXMT_OFB_IPV4_DST, uint32Value(a.Addr)), nil
+}
+
+// SetIPTos sets the IP ToS, only the DSCP bits are rewritten.
+type SetIPTos struct {
+	Tos uint8
+}
+
+func (a *SetIPTos) render10(f *Flow) ([]ofp10.Action, error) {
+	action := ofp10.NewActionNwTos()
+	action.Tos = a.Tos
+	return []ofp10.Action{action}, nil
+}
+
+func (a *SetIPTos) render13(f *Flow) ([]ofp13.Action, error) {
+	return setField13(ofp13.OFPXMT_OFB_IP_DSCP, []byte{a.Tos >> 2}), nil
+}
+
+// SetTpSrc sets the TCP/UDP source port. In openflow 1.3 the flow must match
+// the IP protocol to pick between the TCP and UDP fields.
+type SetTpSrc struct {
+	Port uint16
+}
+
+func (a *SetTpSrc) render10(f *Flow) ([]ofp10.Action, error) {
+	action := ofp10.NewActionTpSrc()
+	action.Port = a.Port
+	return []ofp10.Action{action}, nil
+}
+
+func (a *SetTpSrc) render13(f *Flow) ([]ofp13.Action, error) {
+	switch f.ipProto() {
+	case ipProtoTCP:
+		return setField13(ofp13.OFPXMT_OFB_TCP_SRC, uint16Value(a.Port)), nil
+	case ipProtoUDP:
+		return setField13(ofp13.OFPXMT_OFB_UDP_SRC, uint16Value(a.Port)), nil
+	}
+	return nil, unsupported13(a)
+}
+
+// SetTpDst sets the TCP/UDP destination port. In openflow 1.3 the flow must
+// match the IP protocol to pick between the TCP and UDP fields.
+type SetTpDst struct {
+	Port uint16
+}
+
+func (a *SetTpDst) render10(f *Flow) ([]ofp10.Action, error) {
+	action := ofp10.NewActionTpDst()
+	action.Port = a.Port
+	return []ofp10.Action{action}, nil
+}
+
+func (a *SetTpDst) render13(f *Flow) ([]ofp13.Action, error) {
+	switch f.ipProto() {
+	case ipProtoTCP:
+		return setField13(ofp13.OFPXMT_OFB_TCP_DST, uint16Value(a.Port)), nil
+	case ipProtoUDP:
+		return setField13(ofp13.OFPXMT_OFB_UDP_DST, uint16Value(a.Port)), nil
+	}
+	return nil, unsupported13(a)
+}
+
+func setField13(field uint8, value []byte) []ofp13.Action {
+	return []ofp13.Action{ofp13.NewActionSetField(ofp13.NewOxmField(field, value, nil))}
+}
+
+func uint16Value(v uint16) []byte {
+	return []byte{byte(v >> 8), byte(v)}
+}
+
+func uint32Value(v uint32) []byte {
+	return []byte{byte(v >> 24), byte(v >> 16), byte(v >> 8), byte(v)}
+}
@@ -0,0 +1,167 @@
+// Package flow describes flow entries independently of the openflow version,
+// a flow renders into the flow mod message of the version negotiated with the
+// switch.
+package flow
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/kuun/ofgo/ofp"
+	"github.com/kuun/ofgo/ofp10"
+	"github.com/kuun/ofgo/ofp13"
+)
+
+// Command is a flow table modification command, it has the same values as
+// OFPFC_* of all openflow versions.
+type Command uint8
+
+const (
+	Add          Command = iota // New flow.
+	Modify                      // Modify all matching flows.
+	ModifyStrict                // Modify entry strictly matching wildcards and priority.
+	Delete                      // Delete all matching flows.
+	DeleteStrict                // Delete entry strictly matching wildcards and priority.
+)
+
+const (
+	ethTypeIPv4 = 0x0800
+	ipProtoTCP  = 6
+	ipProtoUDP  = 17
+)
+
+// Flow is a version neutral flow entry description.
+type Flow struct {
+	// Fields to match, in openflow 1.0 form. It's converted to an OXM match
+	// for openflow 1.3.
+	Match       ofp10.Match
+	TableId     uint8  // Table of the flow, must be 0 for openflow 1.0.
+	Priority    uint16 // Priority level of flow entry.
+	IdleTimeout uint16 // Idle time before discarding (seconds).
+	HardTimeout uint16 // Max time before discarding (seconds).
+	Cookie      uint64 // Opaque controller-issued identifier.
+	// Cookie bits that must match for modify and delete commands, only
+	// supported by openflow 1.3.
+	CookieMask uint64
+	// For delete commands, require matching entries to include this as an
+	// output port, zero means no restriction.
+	OutPort         uint32
+	SendFlowRemoved bool     // Send flow removed message when flow expires or is deleted.
+	Actions         []Action // Actions to apply, an empty list drops packets.
+}
+
+// New creates a flow which matches all packets with the default priority.
+func New() *Flow {
+	return &Flow{
+		Match:    ofp10.Match{Wildcards: ofp10.OFPFW_ALL},
+		Priority: ofp10.OFP_DEFAULT_PRIORITY,
+	}
+}
+
+// AddAction appends an action to the flow.
+func (f *Flow) AddAction(action Action) *Flow {
+	f.Actions = append(f.Actions, action)
+	return f
+}
+
+// ipProto gets the IP protocol matched by the flow, 0 if it's not exact.
+func (f *Flow) ipProto() uint8 {
+	w := f.Match.Wildcards
+	if w&ofp10.OFPFW_DL_TYPE != 0 || f.Match.EthType != ethTypeIPv4 || w&ofp10.OFPFW_NW_PROTO != 0 {
+		return 0
+	}
+	return f.Match.NwProto
+}
+
+// FlowMod renders the flow into a flow mod message of the openflow version,
+// either *ofp10.FlowMod or *ofp13.FlowMod. It fails with an
+// *UnsupportedActionError if an action has no equivalent in the version.
+func (f *Flow) FlowMod(version uint8, command Command) (ofp.DataBlock, error) {
+	switch version {
+	case ofp.OFP10_VERSION:
+		return f.FlowMod10(command)
+	case ofp.OFP13_VERSION:
+		return f.FlowMod13(command)
+	}
+	return nil, fmt.Errorf("flow: unsupported openflow version %#x", version)
+}
+
+// FlowMod10 renders the flow into an openflow 1.0 flow mod message.
+func (f *Flow) FlowMod10(command Command) (*ofp10.FlowMod, error) {
+	if f.TableId != 0 {
+		return nil, errors.New("flow: openflow 1.0 has a single flow table")
+	}
+	if f.CookieMask != 0 {
+		return nil, errors.New("flow: openflow 1.0 doesn't support cookie masks")
+	}
+	msg := ofp10.NewFlowMod()
+	msg.Match = f.Match
+	msg.Cookie = f.Cookie
+	msg.Command = uint16(command)
+	msg.IdleTimeout = f.IdleTimeout
+	msg.HardTimeout = f.HardTimeout
+	msg.Priority = f.Priority
+	if f.OutPort != 0 {
+		port, ok := ofp13.PortToOfp10(f.OutPort)
+		if !ok {
+			return nil, fmt.Errorf("flow: port %d cannot be represented in openflow 1.0", f.OutPort)
+		}
+		msg.OutPort = port
+	}
+	if f.SendFlowRemoved {
+		msg.Flags |= ofp10.OFPFF_SEND_FLOW_REM
+	}
+	for _, a := range f.Actions {
+		actions, err := a.render10(f)
+		if err != nil {
+			return nil, err
+		}
+		for _, action := range actions {
+			msg.AddAction(action)
+		}
+	}
+	return msg, nil
+}
+
+// FlowMod13 renders the flow into an openflow 1.3 flow mod message, actions
+// are applied by an OFPIT_APPLY_ACTIONS instruction. It fails with an
+// *ofp13.UnsupportedFieldsError if the match cannot be represented.
+func (f *Flow) FlowMod13(command Command) (*ofp13.FlowMod, error) {
+	match, err := ofp13.MatchFromOfp10(&f.Match)
+	if err != nil {
+		return nil, err
+	}
+	msg := ofp13.NewFlowMod()
+	msg.SetMatch(match)
+	msg.Cookie = f.Cookie
+	msg.CookieMask = f.CookieMask
+	msg.TableId = f.TableId
+	msg.Command = uint8(command)
+	msg.IdleTimeout = f.IdleTimeout
+	msg.HardTimeout = f.HardTimeout
+	msg.Priority = f.Priority
+	if f.OutPort != 0 {
+		msg.OutPort = f.OutPort
+	}
+	if f.SendFlowRemoved {
+		msg.Flags |= ofp13.OFPFF_SEND_FLOW_REM
+	}
+	if command == Delete || command == DeleteStrict {
+		// Instructions are ignored by delete commands.
+		return msg, nil
+	}
+	if len(f.Actions) > 0 {
+		inst := ofp13.NewInstructionApplyActions()
+		for _, a := range f.Actions {
+			actions, err := a.render13(f)
+			if err != nil {
+				return nil, err
+			}
+			for _, action := range actions {
+				inst.AddAction(action)
+			}
+		}
+		msg.AddInstruction(inst)
+	}
+	return msg, nil
+}
@@ -0,0 +1,134 @@
+package flowtable
+
+import (
+	"bytes"
+	"sort"
+
+	"github.com/kuun/ofgo/ofp10"
+)
+
+// Kinds of the issues found by Analyze.
+const (
+	Shadowed    = iota // The entry never applies, an entry with other actions takes all its packets.
+	Redundant          // Removing the entry doesn't change how packets are handled.
+	Conflicting        // The entry overlaps an entry of the same priority with other actions.
+)
+
+// Issue is an issue of an entry of a rule set.
+type Issue struct {
+	Kind  int   // One of Shadowed, Redundant or Conflicting.
+	Entry Entry // Entry having the issue.
+	Other Entry // Entry causing the issue.
+}
+
+// rule is an entry being analyzed, the issues report the entry as given.
+type rule struct {
+	entry      Entry
+	match      ofp10.Match // Normalized match of the entry.
+	precedence int
+	actions    []byte
+}
+
+// precedence gets the rank of an entry in the lookup of a packet, openflow
+// 1.0 switches look exact matches up before the wildcarded flows.
+func precedence(e *Entry) int {
+	if e.Match.IsExact() {
+		return 0x10000
+	}
+	return int(e.Priority)
+}
+
+// Analyze finds the issues of a rule set, such as the flows of a table before
+// they are pushed to a datapath:
+//   - an entry contained in a higher priority entry is Shadowed if the other
+//     entry has other actions, else it's Redundant,
+//   - an entry contained in a lower priority entry with the same actions is
+//     Redundant if the entries between them which overlap it have the same
+//     actions too,
+//   - entries of the same priority which overlap with other actions are
+//     Conflicting, which one applies to the packets they share is undefined.
+//     Entries having the same match and priority are the same flow, the last
+//     one replaces the other ones.
+//
+// Containment is checked entry by entry, an entry covered only by the union
+// of several higher priority entries isn't reported. The issues are sorted
+// like the entries, by decreasing priority.
+func Analyze(entries []Entry) []Issue {
+	rules := make([]rule, len(entries))
+	for i := range entries {
+		rules[i] = rule{
+			entry:      entries[i],
+			match:      entries[i].Match.Normalized(),
+			precedence: precedence(&entries[i]),
+			actions:    marshalActions(entries[i].Actions),
+		}
+	}
+	sort.SliceStable(rules, func(i, j int) bool { return rules[i].precedence > rules[j].precedence })
+
+	var issues []Issue
+	for i := range rules {
+		r := &rules[i]
+		if issue, ok := coveredAbove(rules, i); ok {
+			issues = append(issues, issue)
+			continue
+		}
+		for j := i + 1; j < len(rules) && rules[j].precedence == r.precedence; j++ {
+			other := &rules[j]
+			if !r.match.Overlaps(&other.match) || bytes.Equal(r.actions, other.actions) {
+				continue
+			}
+			issues = append(issues, Issue{Kind: Conflicting, Entry: r.entry, Other: other.entry})
+		}
+		if issue, ok := coveredBelow(rules, i); ok {
+			issues = append(issues, issue)
+		}
+	}
+	return issues
+}
+
+// coveredAbove finds the first entry of higher precedence containing the
+// entry i, the rules are sorted by decreasing precedence. Equal entries of the
+// same priority count as containing each other.
+func coveredAbove(rules []rule, i int) (Issue, bool) {
+	r := &rules[i]
+	for j := range rules {
+		other := &rules[j]
+		if j == i || other.precedence < r.precedence {
+			continue
+		}
+		if other.precedence == r.precedence && (j < i || !r.match.Equal(&other.match)) {
+			// An equal entry is reported once, the last one is kept.
+			continue
+		}
+		if !r.match.SubsetOf(&other.match) {
+			continue
+		}
+		kind := Shadowed
+		if bytes.Equal(r.actions, other.actions) {
+			kind = Redundant
+		} else if other.precedence == r.precedence {
+			kind = Conflicting
+		}
+		return Issue{Kind: kind, Entry: r.entry, Other: other.entry}, true
+	}
+	return Issue{}, false
+}
+
+// coveredBelow finds the entry of lower precedence taking the packets of the
+// entry i with the same actions, if the entry is removed.
+func coveredBelow(rules []rule, i int) (Issue, bool) {
+	r := &rules[i]
+	for j := i + 1; j < len(rules); j++ {
+		other := &rules[j]
+		if other.precedence == r.precedence || !r.match.Overlaps(&other.match) {
+			continue
+		}
+		if !bytes.Equal(r.actions, other.actions) {
+			return Issue{}, false
+		}
+		if r.match.SubsetOf(&other.match) {
+			return Issue{Kind: Redundant, Entry: r.entry, Other: other.entry}, true
+		}
+	}
+	return Issue{}, false
+}
@@ -0,0 +1,146 @@
+package flowtable
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/kuun/ofgo/ofp10"
+)
+
+// testEntry gets an entry outputting to a port, the cookie identifies it in
+// the tests.
+func testEntry(cookie uint64, match ofp10.Match, priority, outPort uint16) Entry {
+	output := ofp10.NewActionOutput()
+	output.Port = outPort
+	return Entry{Match: match, Priority: priority, Cookie: cookie, Actions: []ofp10.Action{output}}
+}
+
+// portMatch gets a match on an input port, all ports if it's zero.
+func portMatch(inPort uint16) ofp10.Match {
+	m := ofp10.Match{Wildcards: ofp10.OFPFW_ALL}
+	if inPort != 0 {
+		m.Wildcards &^= ofp10.OFPFW_IN_PORT
+		m.InPort = inPort
+	}
+	return m
+}
+
+// srcMatch gets a match on an input port and a network source prefix.
+func srcMatch(inPort uint16, nwSrc uint32, prefixLen int) ofp10.Match {
+	m := portMatch(inPort)
+	m.NwSrc = nwSrc
+	m.SetNwSrcPrefixLen(prefixLen)
+	return m
+}
+
+func TestAnalyze(t *testing.T) {
+	// issue is an Issue with the entries identified by their cookie.
+	type issue struct {
+		kind         int
+		entry, other uint64
+	}
+	exact := ofp10.Match{InPort: 1, EthType: 0x800}
+	tests := []struct {
+		name    string
+		entries []Entry
+		want    []issue
+	}{
+		{
+			name: "disjoint",
+			entries: []Entry{
+				testEntry(1, portMatch(1), 100, 2),
+				testEntry(2, portMatch(2), 100, 1),
+			},
+		},
+		{
+			name: "shadowed",
+			entries: []Entry{
+				testEntry(1, portMatch(1), 200, 2),
+				testEntry(2, srcMatch(1, 0x0a000000, 8), 100, 3),
+			},
+			want: []issue{{Shadowed, 2, 1}},
+		},
+		{
+			name: "redundant below a higher priority",
+			entries: []Entry{
+				testEntry(1, portMatch(1), 200, 2),
+				testEntry(2, srcMatch(1, 0x0a000000, 8), 100, 2),
+			},
+			want: []issue{{Redundant, 2, 1}},
+		},
+		{
+			name: "redundant above a lower priority",
+			entries: []Entry{
+				testEntry(1, srcMatch(1, 0x0a000000, 8), 200, 2),
+				testEntry(2, portMatch(0), 100, 2),
+			},
+			want: []issue{{Redundant, 1, 2}},
+		},
+		{
+			name: "not redundant across other actions",
+			entries: []Entry{
+				testEntry(1, srcMatch(1, 0x0a000000, 8), 300, 2),
+				testEntry(2, portMatch(1), 200, 3),
+				testEntry(3, portMatch(0), 100, 2),
+			},
+		},
+		{
+			name: "conflicting",
+			entries: []Entry{
+				testEntry(1, portMatch(1), 100, 2),
+				testEntry(2, srcMatch(0, 0x0a000000, 8), 100, 3),
+			},
+			want: []issue{{Conflicting, 1, 2}},
+		},
+		{
+			name: "same flow",
+			entries: []Entry{
+				testEntry(1, portMatch(1), 100, 2),
+				testEntry(2, portMatch(1), 100, 3),
+			},
+			want: []issue{{Conflicting, 1, 2}},
+		},
+		{
+			name: "exact match before higher priorities",
+			entries: []Entry{
+				testEntry(1, portMatch(0), 500, 3),
+				testEntry(2, exact, 1, 2),
+			},
+		},
+		{
+			name: "exact match redundant",
+			entries: []Entry{
+				testEntry(1, portMatch(0), 500, 2),
+				testEntry(2, exact, 1, 2),
+			},
+			want: []issue{{Redundant, 2, 1}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []issue
+			for _, i := range Analyze(tt.entries) {
+				got = append(got, issue{i.Kind, i.Entry.Cookie, i.Other.Cookie})
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Analyze = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestAnalyzeEntries checks the issues carry the entries as given, not their
+// normalized matches.
+func TestAnalyzeEntries(t *testing.T) {
+	entries := []Entry{
+		testEntry(1, portMatch(1), 200, 2),
+		testEntry(2, srcMatch(1, 0x0a000001, 8), 100, 2),
+	}
+	issues := Analyze(entries)
+	if len(issues) != 1 {
+		t.Fatalf("%d issues, want 1", len(issues))
+	}
+	if issues[0].Entry.Match != entries[1].Match {
+		t.Errorf("issue entry match %+v, want %+v", issues[0].Entry.Match, entries[1].Match)
+	}
+}
@@ -0,0 +1,89 @@
+package flowtable
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/kuun/ofgo/controller"
+	"github.com/kuun/ofgo/ofp"
+	"github.com/kuun/ofgo/ofp10"
+)
+
+// Diff gets the flow mods turning the actual flows of a datapath into the
+// desired ones. Flows are the same entry when they have the same match and
+// priority, as with the strict commands:
+//   - a desired entry without actual flow is added,
+//   - an entry whose actions differ is modified with OFPFC_MODIFY_STRICT,
+//     which keeps the flow's counters,
+//   - an entry whose cookie or timeouts differ is added again, a modify
+//     doesn't change them,
+//   - an actual flow which isn't desired is deleted with
+//     OFPFC_DELETE_STRICT.
+//
+// The flags of the entries aren't compared, flow statistics don't report
+// them. The flow mods are ordered to make before break: the adds, then the
+// modifies, then the deletes, each by decreasing priority so the rules
+// overriding a flow are in place before it.
+func Diff(desired, actual []Entry) []*ofp10.FlowMod {
+	have := make(map[entryKey]*Entry, len(actual))
+	for i := range actual {
+		have[keyOf(actual[i].Match, actual[i].Priority)] = &actual[i]
+	}
+	want := make(map[entryKey]*Entry, len(desired))
+	for i := range desired {
+		want[keyOf(desired[i].Match, desired[i].Priority)] = &desired[i]
+	}
+
+	var adds, modifies, deletes []Entry
+	for key, e := range want {
+		a, ok := have[key]
+		switch {
+		case !ok || a.Cookie != e.Cookie || a.IdleTimeout != e.IdleTimeout || a.HardTimeout != e.HardTimeout:
+			adds = append(adds, *e)
+		case !a.SameActions(e):
+			modifies = append(modifies, *e)
+		}
+	}
+	for key, a := range have {
+		if _, ok := want[key]; !ok {
+			deletes = append(deletes, *a)
+		}
+	}
+
+	msgs := make([]*ofp10.FlowMod, 0, len(adds)+len(modifies)+len(deletes))
+	for _, step := range []struct {
+		entries []Entry
+		command uint16
+	}{
+		{adds, ofp10.OFPFC_ADD},
+		{modifies, ofp10.OFPFC_MODIFY_STRICT},
+		{deletes, ofp10.OFPFC_DELETE_STRICT},
+	} {
+		sortEntries(step.entries)
+		for i := range step.entries {
+			msgs = append(msgs, entryFlowMod(&step.entries[i], step.command))
+		}
+	}
+	return msgs
+}
+
+// Sync makes the flows of a datapath the desired ones: it diffs them with the
+// flow statistics of the datapath, and writes the flow mods. It returns the
+// flow mods written.
+func Sync(ctx context.Context, dp *controller.Datapath, desired []Entry) ([]*ofp10.FlowMod, error) {
+	if dp.Version != ofp.OFP10_VERSION {
+		return nil, fmt.Errorf("openflow version %#x is not supported", dp.Version)
+	}
+	actual, err := flowStats(ctx, dp)
+	if err != nil {
+		return nil, err
+	}
+	msgs := Diff(desired, actual)
+	for i, fm := range msgs {
+		fm.Xid = dp.Conn().NextXid()
+		if err = dp.Write(fm); err != nil {
+			return msgs[:i], err
+		}
+	}
+	return msgs, nil
+}
@@ -0,0 +1,96 @@
+package flowtable
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/kuun/ofgo/ofp10"
+)
+
+func TestDiff(t *testing.T) {
+	// step is a flow mod of the diff: its command, input port and priority.
+	type step struct {
+		command  uint16
+		inPort   uint16
+		priority uint16
+	}
+	timeout := testEntry(1, portMatch(1), 100, 2)
+	timeout.IdleTimeout = 10
+	tests := []struct {
+		name    string
+		desired []Entry
+		actual  []Entry
+		want    []step
+	}{
+		{
+			name:    "same",
+			desired: []Entry{testEntry(1, portMatch(1), 100, 2)},
+			actual:  []Entry{testEntry(1, portMatch(1), 100, 2)},
+		},
+		{
+			name:    "add",
+			desired: []Entry{testEntry(1, portMatch(1), 100, 2)},
+			want:    []step{{ofp10.OFPFC_ADD, 1, 100}},
+		},
+		{
+			name:   "delete",
+			actual: []Entry{testEntry(1, portMatch(1), 100, 2)},
+			want:   []step{{ofp10.OFPFC_DELETE_STRICT, 1, 100}},
+		},
+		{
+			name:    "other actions",
+			desired: []Entry{testEntry(1, portMatch(1), 100, 3)},
+			actual:  []Entry{testEntry(1, portMatch(1), 100, 2)},
+			want:    []step{{ofp10.OFPFC_MODIFY_STRICT, 1, 100}},
+		},
+		{
+			name:    "other cookie",
+			desired: []Entry{testEntry(2, portMatch(1), 100, 2)},
+			actual:  []Entry{testEntry(1, portMatch(1), 100, 2)},
+			want:    []step{{ofp10.OFPFC_ADD, 1, 100}},
+		},
+		{
+			name:    "other timeout",
+			desired: []Entry{timeout},
+			actual:  []Entry{testEntry(1, portMatch(1), 100, 2)},
+			want:    []step{{ofp10.OFPFC_ADD, 1, 100}},
+		},
+		{
+			name:    "other priority",
+			desired: []Entry{testEntry(1, portMatch(1), 200, 2)},
+			actual:  []Entry{testEntry(1, portMatch(1), 100, 2)},
+			want:    []step{{ofp10.OFPFC_ADD, 1, 200}, {ofp10.OFPFC_DELETE_STRICT, 1, 100}},
+		},
+		{
+			name: "make before break",
+			desired: []Entry{
+				testEntry(1, portMatch(1), 100, 3),
+				testEntry(1, portMatch(2), 100, 1),
+				testEntry(1, portMatch(3), 300, 1),
+			},
+			actual: []Entry{
+				testEntry(1, portMatch(1), 100, 2),
+				testEntry(1, portMatch(4), 100, 1),
+				testEntry(1, portMatch(5), 200, 1),
+			},
+			want: []step{
+				{ofp10.OFPFC_ADD, 3, 300},
+				{ofp10.OFPFC_ADD, 2, 100},
+				{ofp10.OFPFC_MODIFY_STRICT, 1, 100},
+				{ofp10.OFPFC_DELETE_STRICT, 5, 200},
+				{ofp10.OFPFC_DELETE_STRICT, 4, 100},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []step
+			for _, fm := range Diff(tt.desired, tt.actual) {
+				got = append(got, step{fm.Command, fm.Match.InPort, fm.Priority})
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Diff = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,303 @@
+package flowtable
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/kuun/ofgo/controller"
+	"github.com/kuun/ofgo/ofp"
+	"github.com/kuun/ofgo/ofp10"
+)
+
+// DefaultInterval is the default period of the reconciliations.
+const DefaultInterval = 30 * time.Second
+
+// DefaultReconcileTimeout is the default timeout of the reconciliations of
+// Connect and Run.
+const DefaultReconcileTimeout = 10 * time.Second
+
+// Report is the outcome of the reconciliation of a table with the flows of a
+// datapath.
+type Report struct {
+	Dpid       uint64
+	Missing    []Entry // Entries of the table the datapath doesn't have.
+	Unexpected []Entry // Flows of the datapath which aren't in the table.
+	Changed    []Entry // Entries of the table whose flow has other actions.
+	Expired    []Entry // Missing entries with a timeout, dropped from the table.
+	Adopted    []Entry // Flows of the datapath added to the table, see Shadow.
+	Fixed      bool    // Whether the datapath was fixed to match the table.
+}
+
+// Consistent reports whether the datapath matched the table.
+func (r *Report) Consistent() bool {
+	return len(r.Missing) == 0 && len(r.Unexpected) == 0 && len(r.Changed) == 0
+}
+
+// Shadow is the flow table shadow app. It keeps a table per openflow 1.0
+// datapath, updated by the flow mods written to the datapath and the flow
+// removed messages received from it. The tables outlive the sessions, so the
+// flows of a datapath connecting again are reconciled against what the
+// controller installed.
+//
+// A reconciliation compares a table with the flow statistics of its
+// datapath. When Fix is set, missing flows are added again, unexpected flows
+// are deleted and changed flows get back the actions of the table. A missing
+// entry with a timeout is assumed expired and is dropped from the table, its
+// flow isn't added again.
+//
+// The first reconciliation of a datapath adopts its flows: the flows which
+// aren't in the table are added to it instead of being unexpected, so that a
+// controller starting again doesn't delete the flows installed before. When
+// it fails, the next reconciliation adopts them.
+type Shadow struct {
+	Interval time.Duration // Period of the reconciliations, DefaultInterval if it's zero.
+	Timeout  time.Duration // Timeout of a reconciliation, DefaultReconcileTimeout if it's zero.
+	Fix      bool          // Fix the datapaths which don't match their table.
+	// OnReport is called with the report of every reconciliation, it's
+	// optional.
+	OnReport func(report Report)
+	// OnError is called with the errors of the reconciliations of Connect
+	// and Run, they are logged if it's nil.
+	OnError func(dpid uint64, err error)
+
+	ac *controller.AppContext
+
+	mu         sync.Mutex
+	tables     map[uint64]*Table
+	reconciled map[uint64]bool // Datapaths whose flows were adopted.
+}
+
+func New() *Shadow {
+	return &Shadow{tables: make(map[uint64]*Table), reconciled: make(map[uint64]bool)}
+}
+
+func (s *Shadow) Name() string {
+	return "flowtable"
+}
+
+// Init hooks the shadow to the flow mods written and the flow removed
+// messages of all the apps.
+func (s *Shadow) Init(ac *controller.AppContext) {
+	s.ac = ac
+	c := ac.Controller()
+	c.HandleWrite(func(dp *controller.Datapath, msg ofp.DataBlock) {
+		if fm, ok := msg.(*ofp10.FlowMod); ok && dp.Version == ofp.OFP10_VERSION {
+			s.Table(dp.Dpid).Apply(fm)
+		}
+	})
+	c.HandleFlowRemoved(func(dp *controller.Datapath, msg *ofp10.FlowRemoved) controller.Result {
+		s.Table(dp.Dpid).Remove(msg)
+		return controller.Continue
+	})
+}
+
+// Table gets the table of a datapath, it's created if needed.
+func (s *Shadow) Table(dpid uint64) *Table {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t := s.tables[dpid]
+	if t == nil {
+		t = NewTable()
+		s.tables[dpid] = t
+	}
+	return t
+}
+
+// Forget drops the table of a datapath, its flows are adopted again by the
+// next reconciliation.
+func (s *Shadow) Forget(dpid uint64) {
+	s.mu.Lock()
+	delete(s.tables, dpid)
+	delete(s.reconciled, dpid)
+	s.mu.Unlock()
+}
+
+// Connect reconciles the flows of a datapath connecting.
+func (s *Shadow) Connect(dp *controller.Datapath) {
+	if dp.Version != ofp.OFP10_VERSION {
+		return
+	}
+	// The connect handlers hold the events of the datapath, the round trip
+	// of the reconciliation must not delay them.
+	go s.reconcile(context.Background(), dp)
+}
+
+// reconcile reconciles the flows of a datapath within the timeout, and
+// reports the error.
+func (s *Shadow) reconcile(ctx context.Context, dp *controller.Datapath) {
+	timeout := s.Timeout
+	if timeout <= 0 {
+		timeout = DefaultReconcileTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	if _, err := s.Reconcile(ctx, dp); err != nil {
+		if s.OnError != nil {
+			s.OnError(dp.Dpid, err)
+		} else {
+			log.Printf("reconciliation of datapath %016x failed: %v", dp.Dpid, err)
+		}
+	}
+}
+
+// Run reconciles the flows of the datapaths periodically until the context
+// is done.
+func (s *Shadow) Run(ctx context.Context) error {
+	interval := s.Interval
+	if interval <= 0 {
+		interval = DefaultInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			for _, dp := range s.ac.Controller().Datapaths() {
+				if dp.Version == ofp.OFP10_VERSION {
+					s.reconcile(ctx, dp)
+				}
+			}
+		}
+	}
+}
+
+// Reconcile compares the table of a datapath with its flow statistics, and
+// fixes the datapath if Fix is set.
+func (s *Shadow) Reconcile(ctx context.Context, dp *controller.Datapath) (Report, error) {
+	if dp.Version != ofp.OFP10_VERSION {
+		return Report{}, fmt.Errorf("openflow version %#x is not supported", dp.Version)
+	}
+	table := s.Table(dp.Dpid)
+	start := time.Now()
+	flows, err := flowStats(ctx, dp)
+	if err != nil {
+		return Report{}, err
+	}
+	s.mu.Lock()
+	adopt := !s.reconciled[dp.Dpid]
+	s.reconciled[dp.Dpid] = true
+	s.mu.Unlock()
+
+	report := Report{Dpid: dp.Dpid}
+	actual := make(map[entryKey]*Entry, len(flows))
+	for i := range flows {
+		actual[keyOf(flows[i].Match, flows[i].Priority)] = &flows[i]
+	}
+	for _, e := range table.Entries() {
+		if e.Installed.After(start) {
+			// Too recent for the statistics to know it.
+			continue
+		}
+		key := keyOf(e.Match, e.Priority)
+		flow, ok := actual[key]
+		delete(actual, key)
+		switch {
+		case !ok && e.Expires():
+			table.Delete(e.Match, e.Priority)
+			report.Expired = append(report.Expired, e)
+		case !ok:
+			report.Missing = append(report.Missing, e)
+		case !e.SameActions(flow):
+			report.Changed = append(report.Changed, e)
+		}
+	}
+	for _, flow := range actual {
+		if current, ok := table.Lookup(flow.Match, flow.Priority); ok && current.Installed.After(start) {
+			continue
+		}
+		if adopt {
+			flow.Installed = start
+			table.Set(*flow)
+			report.Adopted = append(report.Adopted, *flow)
+			continue
+		}
+		report.Unexpected = append(report.Unexpected, *flow)
+	}
+	sortEntries(report.Unexpected)
+	sortEntries(report.Adopted)
+
+	if s.Fix && !report.Consistent() {
+		if err = s.fix(dp, &report); err == nil {
+			report.Fixed = true
+		}
+	}
+	if s.OnReport != nil {
+		s.OnReport(report)
+	}
+	return report, err
+}
+
+// flowStats gets the flows of a datapath as entries.
+func flowStats(ctx context.Context, dp *controller.Datapath) ([]Entry, error) {
+	parts, err := dp.RequestMultipart(ctx, ofp10.NewFlowStatsRequest(ofp10.Match{Wildcards: ofp10.OFPFW_ALL}))
+	if err != nil {
+		return nil, err
+	}
+	var entries []Entry
+	for _, part := range parts {
+		reply, ok := part.(*ofp10.StatsReply)
+		if !ok {
+			return nil, fmt.Errorf("unexpected reply %T to a flow statistics request", part)
+		}
+		stats, err := reply.FlowStats()
+		if err != nil {
+			return nil, err
+		}
+		for _, st := range stats {
+			entries = append(entries, Entry{
+				Match:       st.Match.Normalized(),
+				Priority:    st.Priority,
+				Cookie:      st.Cookie,
+				IdleTimeout: st.IdleTimeout,
+				HardTimeout: st.HardTimeout,
+				Actions:     st.Actions,
+			})
+		}
+	}
+	return entries, nil
+}
+
+// fix writes the flow mods making the datapath match the table.
+func (s *Shadow) fix(dp *controller.Datapath, report *Report) error {
+	var msgs []*ofp10.FlowMod
+	for i := range report.Missing {
+		msgs = append(msgs, entryFlowMod(&report.Missing[i], ofp10.OFPFC_ADD))
+	}
+	for i := range report.Changed {
+		msgs = append(msgs, entryFlowMod(&report.Changed[i], ofp10.OFPFC_MODIFY_STRICT))
+	}
+	for i := range report.Unexpected {
+		msgs = append(msgs, entryFlowMod(&report.Unexpected[i], ofp10.OFPFC_DELETE_STRICT))
+	}
+	for _, fm := range msgs {
+		fm.Xid = dp.Conn().NextXid()
+		if err := dp.Write(fm); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// entryFlowMod creates the flow mod applying a command to an entry.
+func entryFlowMod(e *Entry, command uint16) *ofp10.FlowMod {
+	fm := ofp10.NewFlowMod()
+	fm.Command = command
+	fm.Match = e.Match
+	fm.Priority = e.Priority
+	if command == ofp10.OFPFC_DELETE_STRICT {
+		return fm
+	}
+	fm.Cookie = e.Cookie
+	fm.IdleTimeout = e.IdleTimeout
+	fm.HardTimeout = e.HardTimeout
+	fm.Flags = e.Flags
+	for _, action := range e.Actions {
+		fm.AddAction(action)
+	}
+	return fm
+}
@@ -0,0 +1,175 @@
+package flowtable
+
+import (
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kuun/ofgo/controller"
+	"github.com/kuun/ofgo/ofnet"
+	"github.com/kuun/ofgo/ofp"
+	"github.com/kuun/ofgo/ofp10"
+)
+
+// fakeSwitch is an openflow 1.0 switch keeping its flows in a Table. It
+// answers barriers and flow statistics requests.
+type fakeSwitch struct {
+	conn  *ofnet.Conn
+	flows *Table
+
+	mu           sync.Mutex
+	reject       func(fm *ofp10.FlowMod) bool // Flow mods answered with an error.
+	dropBarriers int                          // Barriers left unanswered.
+}
+
+// connectSwitch connects a fake switch to the controller, it returns once the
+// datapath is registered.
+func connectSwitch(t *testing.T, c *controller.Controller, dpid uint64) (*fakeSwitch, *controller.Datapath) {
+	t.Helper()
+	controllerEnd, switchEnd := net.Pipe()
+	t.Cleanup(func() {
+		controllerEnd.Close()
+		switchEnd.Close()
+	})
+	go c.Serve(nil, ofnet.NewConn(controllerEnd))
+
+	sw := &fakeSwitch{conn: ofnet.NewConn(switchEnd), flows: NewTable()}
+	if _, err := sw.conn.Read(); err != nil {
+		t.Fatal(err)
+	}
+	if err := sw.conn.Write(ofp10.NewHello()); err != nil {
+		t.Fatal(err)
+	}
+	sw.conn.SetVersion(ofp.OFP10_VERSION)
+	msg, err := sw.conn.Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	reply := &ofp10.FeaturesReply{
+		Header: ofp.Header{
+			Version: ofp.OFP10_VERSION,
+			Type:    ofp10.OFPT_FEATURES_REPLY,
+			Length:  32,
+			Xid:     msg.(ofp.Message).MessageHeader().Xid,
+		},
+		Dpid: dpid,
+	}
+	if err = sw.conn.Write(reply); err != nil {
+		t.Fatal(err)
+	}
+	go sw.run()
+
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		if dp := c.Datapath(dpid); dp != nil {
+			return sw, dp
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatal("datapath didn't register")
+	return nil, nil
+}
+
+func (sw *fakeSwitch) run() {
+	for {
+		msg, err := sw.conn.Read()
+		if err != nil {
+			return
+		}
+		switch msg := msg.(type) {
+		case *ofp10.FlowMod:
+			sw.mu.Lock()
+			rejected := sw.reject != nil && sw.reject(msg)
+			sw.mu.Unlock()
+			if !rejected {
+				sw.flows.Apply(msg)
+				continue
+			}
+			reply := ofp.NewError(ofp.OFP10_VERSION, ofp.OFPET_FLOW_MOD_FAILED, 0, nil)
+			reply.Xid = msg.Xid
+			err = sw.conn.Write(reply)
+		case *ofp10.StatsRequest:
+			err = sw.conn.Write(sw.flowStats(msg.Xid))
+		case *ofp.RawMessage:
+			if msg.Type != ofp10.OFPT_BARRIER_REQUEST {
+				continue
+			}
+			sw.mu.Lock()
+			drop := sw.dropBarriers > 0
+			if drop {
+				sw.dropBarriers--
+			}
+			sw.mu.Unlock()
+			if drop {
+				continue
+			}
+			reply := ofp10.NewBarrierRequest()
+			reply.Type = ofp10.OFPT_BARRIER_REPLY
+			reply.Xid = msg.Xid
+			err = sw.conn.Write(reply)
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// flowStats creates the reply of a flow statistics request.
+func (sw *fakeSwitch) flowStats(xid uint32) *ofp10.StatsReply {
+	var body []byte
+	for _, e := range sw.flows.Entries() {
+		stats := ofp10.NewFlowStats()
+		stats.Match = e.Match
+		stats.Priority = e.Priority
+		stats.Cookie = e.Cookie
+		stats.IdleTimeout = e.IdleTimeout
+		stats.HardTimeout = e.HardTimeout
+		for _, action := range e.Actions {
+			stats.AddAction(action)
+		}
+		buf := make([]byte, stats.Len())
+		stats.Marshal(buf)
+		body = append(body, buf...)
+	}
+	return &ofp10.StatsReply{
+		Header: ofp.Header{
+			Version: ofp.OFP10_VERSION,
+			Type:    ofp10.OFPT_STATS_REPLY,
+			Length:  uint16(12 + len(body)),
+			Xid:     xid,
+		},
+		StatsType: ofp10.OFPST_FLOW,
+		Body:      body,
+	}
+}
+
+// flowMod creates a flow mod of the flows entering a port, outputting to
+// another port.
+func flowMod(command uint16, inPort, outPort, priority uint16) *ofp10.FlowMod {
+	fm := ofp10.NewFlowMod()
+	fm.Command = command
+	fm.Match.Wildcards = ofp10.OFPFW_ALL &^ ofp10.OFPFW_IN_PORT
+	fm.Match.InPort = inPort
+	fm.Priority = priority
+	if outPort != 0 {
+		output := ofp10.NewActionOutput()
+		output.Port = outPort
+		fm.AddAction(output)
+	}
+	return fm
+}
+
+// outputs gets the output port of the entries, by input port.
+func outputs(entries []Entry) map[uint16]uint16 {
+	ports := make(map[uint16]uint16)
+	for _, e := range entries {
+		ports[e.Match.InPort] = 0
+		for _, action := range e.Actions {
+			if output, ok := action.(*ofp10.ActionOutput); ok {
+				ports[e.Match.InPort] = output.Port
+			}
+		}
+	}
+	return ports
+}
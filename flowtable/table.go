@@ -0,0 +1,243 @@
+// Package flowtable keeps a controller side view of the flow tables of
+// openflow 1.0 datapaths.
+package flowtable
+
+import (
+	"bytes"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/kuun/ofgo/ofp10"
+)
+
+// Entry is a flow entry of a table.
+type Entry struct {
+	Match       ofp10.Match
+	Priority    uint16
+	Cookie      uint64
+	IdleTimeout uint16 // Idle timeout (seconds).
+	HardTimeout uint16 // Hard timeout (seconds).
+	Flags       uint16 // OFPFF_* the flow was added with.
+	Actions     []ofp10.Action
+	Installed   time.Time // When the flow was added or last modified.
+}
+
+// Expires reports whether the switch may remove the flow on its own.
+func (e *Entry) Expires() bool {
+	return e.IdleTimeout != 0 || e.HardTimeout != 0
+}
+
+// SameActions reports whether the entries have the same actions.
+func (e *Entry) SameActions(other *Entry) bool {
+	return bytes.Equal(marshalActions(e.Actions), marshalActions(other.Actions))
+}
+
+func marshalActions(actions []ofp10.Action) []byte {
+	var buff []byte
+	for _, action := range actions {
+		b := make([]byte, action.Len())
+		action.Marshal(b)
+		buff = append(buff, b...)
+	}
+	return buff
+}
+
+// NewEntry creates the entry a flow mod adds.
+func NewEntry(fm *ofp10.FlowMod) Entry {
+	return Entry{
+		Match:       fm.Match.Normalized(),
+		Priority:    fm.Priority,
+		Cookie:      fm.Cookie,
+		IdleTimeout: fm.IdleTimeout,
+		HardTimeout: fm.HardTimeout,
+		Flags:       fm.Flags,
+		Actions:     copyActions(fm.Actions),
+	}
+}
+
+// entryKey identifies an entry, two flows with the same match and priority
+// are the same entry.
+type entryKey struct {
+	match    ofp10.Match
+	priority uint16
+}
+
+func keyOf(match ofp10.Match, priority uint16) entryKey {
+	return entryKey{match.Normalized(), priority}
+}
+
+// Table is the flow table of a datapath, as the flow mods written to the
+// datapath and the flow removed messages received from it make it.
+type Table struct {
+	mu      sync.Mutex
+	entries map[entryKey]*Entry
+}
+
+func NewTable() *Table {
+	return &Table{entries: make(map[entryKey]*Entry)}
+}
+
+// Apply updates the table with a flow mod, the way an openflow 1.0 switch
+// does.
+func (t *Table) Apply(fm *ofp10.FlowMod) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	now := time.Now()
+	key := keyOf(fm.Match, fm.Priority)
+	switch fm.Command {
+	case ofp10.OFPFC_ADD:
+		t.add(key, fm, now)
+	case ofp10.OFPFC_MODIFY, ofp10.OFPFC_MODIFY_STRICT:
+		modified := false
+		for k, e := range t.entries {
+			if fm.Command == ofp10.OFPFC_MODIFY_STRICT && k != key ||
+				fm.Command == ofp10.OFPFC_MODIFY && !k.match.SubsetOf(&fm.Match) {
+				continue
+			}
+			e.Actions = copyActions(fm.Actions)
+			e.Installed = now
+			modified = true
+		}
+		if !modified {
+			// A modify matching nothing adds the flow.
+			t.add(key, fm, now)
+		}
+	case ofp10.OFPFC_DELETE, ofp10.OFPFC_DELETE_STRICT:
+		for k, e := range t.entries {
+			if fm.Command == ofp10.OFPFC_DELETE_STRICT && k != key ||
+				fm.Command == ofp10.OFPFC_DELETE && !k.match.SubsetOf(&fm.Match) {
+				continue
+			}
+			if fm.OutPort == ofp10.OFPP_NONE || outputsTo(e.Actions, fm.OutPort) {
+				delete(t.entries, k)
+			}
+		}
+	}
+}
+
+func (t *Table) add(key entryKey, fm *ofp10.FlowMod, now time.Time) {
+	e := NewEntry(fm)
+	e.Installed = now
+	t.entries[key] = &e
+}
+
+func copyActions(actions []ofp10.Action) []ofp10.Action {
+	return append([]ofp10.Action(nil), actions...)
+}
+
+// outputsTo reports whether the actions send packets out of the port.
+func outputsTo(actions []ofp10.Action, port uint16) bool {
+	for _, action := range actions {
+		if output, ok := action.(*ofp10.ActionOutput); ok && output.Port == port {
+			return true
+		}
+	}
+	return false
+}
+
+// Remove removes the entry of a flow removed message.
+func (t *Table) Remove(msg *ofp10.FlowRemoved) {
+	t.mu.Lock()
+	delete(t.entries, keyOf(msg.Match, msg.Priority))
+	t.mu.Unlock()
+}
+
+// Set adds or replaces an entry.
+func (t *Table) Set(e Entry) {
+	t.mu.Lock()
+	key := keyOf(e.Match, e.Priority)
+	e.Match = key.match
+	e.Actions = copyActions(e.Actions)
+	t.entries[key] = &e
+	t.mu.Unlock()
+}
+
+// sync makes the table match the flows of its datapath, read from 'start' on.
+// The entries changed since 'start' are left alone, an entry having the
+// cookie, timeouts and actions of its flow is kept with its flags.
+func (t *Table) sync(flows []Entry, start time.Time) {
+	actual := make(map[entryKey]*Entry, len(flows))
+	for i := range flows {
+		actual[keyOf(flows[i].Match, flows[i].Priority)] = &flows[i]
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for key, e := range t.entries {
+		if _, ok := actual[key]; !ok && !e.Installed.After(start) {
+			delete(t.entries, key)
+		}
+	}
+	for key, flow := range actual {
+		e, ok := t.entries[key]
+		if ok && (e.Installed.After(start) || e.sameFlow(flow)) {
+			continue
+		}
+		synced := *flow
+		synced.Match = key.match
+		synced.Actions = copyActions(flow.Actions)
+		synced.Installed = start
+		t.entries[key] = &synced
+	}
+}
+
+// sameFlow reports whether the entries have the same cookie, timeouts and
+// actions.
+func (e *Entry) sameFlow(other *Entry) bool {
+	return e.Cookie == other.Cookie && e.IdleTimeout == other.IdleTimeout &&
+		e.HardTimeout == other.HardTimeout && e.SameActions(other)
+}
+
+// Delete removes the entry having a match and priority.
+func (t *Table) Delete(match ofp10.Match, priority uint16) {
+	t.mu.Lock()
+	delete(t.entries, keyOf(match, priority))
+	t.mu.Unlock()
+}
+
+// Lookup gets the entry having a match and priority.
+func (t *Table) Lookup(match ofp10.Match, priority uint16) (Entry, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	e, ok := t.entries[keyOf(match, priority)]
+	if !ok {
+		return Entry{}, false
+	}
+	return *e, true
+}
+
+// Entries gets the entries of the table, by decreasing priority.
+func (t *Table) Entries() []Entry {
+	t.mu.Lock()
+	entries := make([]Entry, 0, len(t.entries))
+	for _, e := range t.entries {
+		entries = append(entries, *e)
+	}
+	t.mu.Unlock()
+	sortEntries(entries)
+	return entries
+}
+
+// Len gets the number of entries of the table.
+func (t *Table) Len() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return len(t.entries)
+}
+
+// sortEntries sorts entries by decreasing priority, then by match so the
+// order is stable.
+func sortEntries(entries []Entry) {
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Priority != entries[j].Priority {
+			return entries[i].Priority > entries[j].Priority
+		}
+		return bytes.Compare(marshalMatch(entries[i].Match), marshalMatch(entries[j].Match)) < 0
+	})
+}
+
+func marshalMatch(match ofp10.Match) []byte {
+	buff := make([]byte, match.Len())
+	match.Marshal(buff)
+	return buff
+}
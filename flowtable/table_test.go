@@ -0,0 +1,110 @@
+package flowtable
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/kuun/ofgo/ofp10"
+)
+
+// flow identifies an entry of the tests by its input port and priority.
+type flow struct {
+	inPort, priority uint16
+}
+
+func TestTableApply(t *testing.T) {
+	// The flows of the table before the flow mod, the results map the
+	// flows to their output port.
+	initial := []*ofp10.FlowMod{
+		flowMod(ofp10.OFPFC_ADD, 1, 2, 100),
+		flowMod(ofp10.OFPFC_ADD, 1, 3, 200),
+		flowMod(ofp10.OFPFC_ADD, 2, 1, 100),
+	}
+	all := func(command, outPort, priority uint16) *ofp10.FlowMod {
+		fm := flowMod(command, 0, outPort, priority)
+		fm.Match.Wildcards = ofp10.OFPFW_ALL
+		return fm
+	}
+	deleteTo := func(command, inPort, outPort uint16) *ofp10.FlowMod {
+		fm := flowMod(command, inPort, 0, 100)
+		fm.OutPort = outPort
+		return fm
+	}
+	tests := []struct {
+		name string
+		fm   *ofp10.FlowMod
+		want map[flow]uint16
+	}{
+		{
+			name: "add",
+			fm:   flowMod(ofp10.OFPFC_ADD, 3, 1, 100),
+			want: map[flow]uint16{{1, 100}: 2, {1, 200}: 3, {2, 100}: 1, {3, 100}: 1},
+		},
+		{
+			name: "add replaces",
+			fm:   flowMod(ofp10.OFPFC_ADD, 1, 4, 100),
+			want: map[flow]uint16{{1, 100}: 4, {1, 200}: 3, {2, 100}: 1},
+		},
+		{
+			name: "modify strict",
+			fm:   flowMod(ofp10.OFPFC_MODIFY_STRICT, 1, 4, 100),
+			want: map[flow]uint16{{1, 100}: 4, {1, 200}: 3, {2, 100}: 1},
+		},
+		{
+			name: "modify every priority",
+			fm:   flowMod(ofp10.OFPFC_MODIFY, 1, 4, 0),
+			want: map[flow]uint16{{1, 100}: 4, {1, 200}: 4, {2, 100}: 1},
+		},
+		{
+			name: "modify all",
+			fm:   all(ofp10.OFPFC_MODIFY, 4, 0),
+			want: map[flow]uint16{{1, 100}: 4, {1, 200}: 4, {2, 100}: 4},
+		},
+		{
+			name: "modify nothing adds",
+			fm:   flowMod(ofp10.OFPFC_MODIFY, 3, 4, 100),
+			want: map[flow]uint16{{1, 100}: 2, {1, 200}: 3, {2, 100}: 1, {3, 100}: 4},
+		},
+		{
+			name: "delete strict",
+			fm:   flowMod(ofp10.OFPFC_DELETE_STRICT, 1, 0, 100),
+			want: map[flow]uint16{{1, 200}: 3, {2, 100}: 1},
+		},
+		{
+			name: "delete every priority",
+			fm:   flowMod(ofp10.OFPFC_DELETE, 1, 0, 0),
+			want: map[flow]uint16{{2, 100}: 1},
+		},
+		{
+			name: "delete all",
+			fm:   all(ofp10.OFPFC_DELETE, 0, 0),
+			want: map[flow]uint16{},
+		},
+		{
+			name: "delete by output port",
+			fm:   deleteTo(ofp10.OFPFC_DELETE, 1, 3),
+			want: map[flow]uint16{{1, 100}: 2, {2, 100}: 1},
+		},
+		{
+			name: "delete strict by other output port",
+			fm:   deleteTo(ofp10.OFPFC_DELETE_STRICT, 1, 3),
+			want: map[flow]uint16{{1, 100}: 2, {1, 200}: 3, {2, 100}: 1},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			table := NewTable()
+			for _, fm := range initial {
+				table.Apply(fm)
+			}
+			table.Apply(tt.fm)
+			got := make(map[flow]uint16)
+			for _, e := range table.Entries() {
+				got[flow{e.Match.InPort, e.Priority}] = outputs([]Entry{e})[e.Match.InPort]
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("flows %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,172 @@
+package flowtable
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/kuun/ofgo/controller"
+	"github.com/kuun/ofgo/ofp"
+	"github.com/kuun/ofgo/ofp10"
+)
+
+// DefaultRollbackTimeout bounds the rollback of a transaction, which doesn't
+// run with the context of the commit since it may be done.
+const DefaultRollbackTimeout = 10 * time.Second
+
+// TransactionError is the error of a transaction some flow mods of failed, or
+// which was interrupted. The flow mods which succeeded were rolled back,
+// unless RollbackErr is set.
+type TransactionError struct {
+	// Err is why the transaction was interrupted, e.g. a failed write or a
+	// barrier without reply, nil if the datapath rejected flow mods.
+	Err         error
+	Errors      map[uint32]*ofp.Error // Errors of the datapath, by Xid of the failed flow mod.
+	Failed      []*ofp10.FlowMod      // Flow mods the datapath rejected.
+	Rollback    []*ofp10.FlowMod      // Compensating flow mods written.
+	RollbackErr error                 // Why the rollback failed or didn't run.
+}
+
+func (e *TransactionError) Error() string {
+	var msg string
+	if e.Err != nil {
+		msg = "flowtable: transaction interrupted: " + e.Err.Error()
+	} else {
+		first := e.Errors[e.Failed[0].Xid]
+		msg = fmt.Sprintf("flowtable: %d of the flow mods failed, first error: type %d, code %d",
+			len(e.Failed), first.Type, first.Code)
+	}
+	if e.RollbackErr != nil {
+		msg += ", rollback failed: " + e.RollbackErr.Error()
+	}
+	return msg
+}
+
+func (e *TransactionError) Unwrap() error {
+	return e.Err
+}
+
+// Transaction is a batch of flow mods applied to an openflow 1.0 datapath
+// all or nothing. The batch is written followed by a barrier, if the datapath
+// rejects some flow mods, the ones it applied are compensated to restore the
+// flows it had before the batch.
+//
+// When the batch is interrupted, by a failed write or a barrier whose reply
+// doesn't come in time, it's unknown which flow mods were applied: the
+// compensation is the diff of the flow statistics with the flows before the
+// batch.
+//
+// The flows before the batch are taken from the shadow table of the
+// datapath if there's one, else from its flow statistics, which don't report
+// the flags of the flows. Flows changed by others while the transaction runs
+// may be overwritten by the rollback. After a rollback, the shadow table is
+// brought in line with the flow statistics.
+type Transaction struct {
+	dp    *controller.Datapath
+	table *Table
+	msgs  []*ofp10.FlowMod
+}
+
+// NewTransaction creates a transaction of a datapath, 'table' is the shadow
+// table of the datapath, or nil.
+func NewTransaction(dp *controller.Datapath, table *Table) *Transaction {
+	return &Transaction{dp: dp, table: table}
+}
+
+// Add adds a flow mod to the batch.
+func (tx *Transaction) Add(fm *ofp10.FlowMod) *Transaction {
+	tx.msgs = append(tx.msgs, fm)
+	return tx
+}
+
+// Commit writes the batch and waits for the datapath to process it. It
+// returns a *TransactionError if flow mods failed or the batch was
+// interrupted, the rollback runs even if the context is done.
+func (tx *Transaction) Commit(ctx context.Context) error {
+	dp := tx.dp
+	if dp.Version != ofp.OFP10_VERSION {
+		return fmt.Errorf("openflow version %#x is not supported", dp.Version)
+	}
+	var before []Entry
+	if tx.table != nil {
+		before = tx.table.Entries()
+	} else {
+		var err error
+		if before, err = flowStats(ctx, dp); err != nil {
+			return err
+		}
+	}
+
+	errs, err := tx.write(ctx, tx.msgs)
+	if err == nil && len(errs) == 0 {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultRollbackTimeout)
+	defer cancel()
+	txErr := &TransactionError{Err: err, Errors: errs}
+	if err != nil {
+		// Any of the flow mods may have been applied, the flows are read
+		// back.
+		var actual []Entry
+		if actual, txErr.RollbackErr = flowStats(ctx, dp); txErr.RollbackErr == nil {
+			txErr.Rollback = Diff(before, actual)
+		}
+	} else {
+		// The flows are what the flow mods which succeeded made them.
+		after := NewTable()
+		for _, e := range before {
+			after.Set(e)
+		}
+		for _, fm := range tx.msgs {
+			if errs[fm.Xid] != nil {
+				txErr.Failed = append(txErr.Failed, fm)
+			} else {
+				after.Apply(fm)
+			}
+		}
+		txErr.Rollback = Diff(before, after.Entries())
+	}
+	if txErr.RollbackErr == nil {
+		rollbackErrs, err := tx.write(ctx, txErr.Rollback)
+		if err == nil && len(rollbackErrs) > 0 {
+			err = fmt.Errorf("%d of the compensating flow mods failed", len(rollbackErrs))
+		}
+		txErr.RollbackErr = err
+	}
+	if tx.table != nil {
+		// The shadow table saw the failed flow mods too, while the flows
+		// removed meanwhile must stay removed.
+		start := time.Now()
+		if actual, err := flowStats(ctx, dp); err == nil {
+			tx.table.sync(actual, start)
+		}
+	}
+	return txErr
+}
+
+// write writes flow mods followed by a barrier, and gets the errors of the
+// datapath about them.
+func (tx *Transaction) write(ctx context.Context, msgs []*ofp10.FlowMod) (map[uint32]*ofp.Error, error) {
+	dp := tx.dp
+	if len(msgs) == 0 {
+		return nil, nil
+	}
+	xids := make([]uint32, len(msgs))
+	for i, fm := range msgs {
+		fm.Xid = dp.Conn().NextXid()
+		xids[i] = fm.Xid
+	}
+	watch := dp.WatchErrors(xids...)
+	defer watch.Stop()
+	for _, fm := range msgs {
+		if err := dp.Write(fm); err != nil {
+			return nil, err
+		}
+	}
+	barrier := ofp10.NewBarrierRequest()
+	if _, err := dp.Request(ctx, barrier); err != nil {
+		return nil, err
+	}
+	return watch.Errors(), nil
+}
@@ -0,0 +1,98 @@
+package flowtable
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/kuun/ofgo/controller"
+	"github.com/kuun/ofgo/ofp10"
+)
+
+func TestTransactionCommit(t *testing.T) {
+	tests := []struct {
+		name         string
+		reject       func(fm *ofp10.FlowMod) bool
+		dropBarriers int
+		wantErr      bool
+		wantFailed   int
+		interrupted  bool
+	}{
+		{
+			name: "applied",
+		},
+		{
+			name:       "rejected",
+			reject:     func(fm *ofp10.FlowMod) bool { return fm.Match.InPort == 3 },
+			wantErr:    true,
+			wantFailed: 1,
+		},
+		{
+			name:         "barrier lost",
+			dropBarriers: 1,
+			wantErr:      true,
+			interrupted:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := controller.New()
+			c.EchoInterval = -1
+			shadow := New()
+			if _, err := c.RegisterApp(shadow, 100, 200); err != nil {
+				t.Fatal(err)
+			}
+			sw, dp := connectSwitch(t, c, 1)
+			table := shadow.Table(dp.Dpid)
+
+			// The flows before the transaction.
+			if err := NewTransaction(dp, table).Add(flowMod(ofp10.OFPFC_ADD, 1, 10, 100)).Commit(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+			before := outputs(sw.flows.Entries())
+
+			sw.mu.Lock()
+			sw.reject, sw.dropBarriers = tt.reject, tt.dropBarriers
+			sw.mu.Unlock()
+			ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+			defer cancel()
+			err := NewTransaction(dp, table).
+				Add(flowMod(ofp10.OFPFC_MODIFY_STRICT, 1, 11, 100)).
+				Add(flowMod(ofp10.OFPFC_ADD, 2, 20, 100)).
+				Add(flowMod(ofp10.OFPFC_ADD, 3, 30, 100)).
+				Commit(ctx)
+
+			if !tt.wantErr {
+				if err != nil {
+					t.Fatal(err)
+				}
+				want := map[uint16]uint16{1: 11, 2: 20, 3: 30}
+				if got := outputs(sw.flows.Entries()); !reflect.DeepEqual(got, want) {
+					t.Errorf("switch flows %v, want %v", got, want)
+				}
+				return
+			}
+			var txErr *TransactionError
+			if !errors.As(err, &txErr) {
+				t.Fatalf("error %v, want a *TransactionError", err)
+			}
+			if txErr.RollbackErr != nil {
+				t.Fatalf("rollback failed: %v", txErr.RollbackErr)
+			}
+			if len(txErr.Failed) != tt.wantFailed {
+				t.Errorf("%d flow mods failed, want %d", len(txErr.Failed), tt.wantFailed)
+			}
+			if interrupted := errors.Is(err, context.DeadlineExceeded); interrupted != tt.interrupted {
+				t.Errorf("error %v, want interrupted %v", err, tt.interrupted)
+			}
+			if got := outputs(sw.flows.Entries()); !reflect.DeepEqual(got, before) {
+				t.Errorf("switch flows %v after the rollback, want %v", got, before)
+			}
+			if got := outputs(table.Entries()); !reflect.DeepEqual(got, before) {
+				t.Errorf("shadow flows %v after the rollback, want %v", got, before)
+			}
+		})
+	}
+}
@@ -0,0 +1,235 @@
+package ofnet
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/kuun/ofgo/ofp"
+	"github.com/kuun/ofgo/ofp13"
+)
+
+var (
+	// ErrNoMainConnection is returned when an auxiliary connection arrives for
+	// a datapath which has no main connection.
+	ErrNoMainConnection = errors.New("openflow datapath has no main connection")
+	// ErrConnGroupClosed is returned by writes on a torn down connection group.
+	ErrConnGroupClosed = errors.New("openflow connection group is closed")
+)
+
+// ConnGroup is the main connection of an openflow 1.3 datapath together with
+// its auxiliary connections. Packet outs are spread over the auxiliary
+// connections, all the other messages go through the main connection. Packet
+// ins may be received on any connection of the group, their read loops should
+// hand them to the same datapath.
+type ConnGroup struct {
+	Dpid uint64
+
+	mu     sync.Mutex
+	main   *Conn
+	aux    map[uint8]*Conn // Auxiliary id -> connection.
+	order  []uint8         // Auxiliary ids, in the order packet outs use them.
+	next   int             // Index in order of the next packet out connection.
+	closed bool
+	done   chan struct{}
+
+	send func(msg ofp.DataBlock) error // Writes on the main connection, guarded by mu.
+}
+
+// Main gets the main connection.
+func (g *ConnGroup) Main() *Conn {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.main
+}
+
+// Auxiliary gets the auxiliary connection of the id, nil if there is none.
+func (g *ConnGroup) Auxiliary(auxId uint8) *Conn {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.aux[auxId]
+}
+
+// Auxiliaries gets the number of auxiliary connections.
+func (g *ConnGroup) Auxiliaries() int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return len(g.aux)
+}
+
+// Done is closed when the group is torn down.
+func (g *ConnGroup) Done() <-chan struct{} {
+	return g.done
+}
+
+// isPacketOut reports whether the message is an openflow 1.3 packet out.
+func isPacketOut(msg ofp.DataBlock) bool {
+	m, ok := msg.(ofp.Message)
+	if !ok {
+		return false
+	}
+	header := m.MessageHeader()
+	return header.Version == ofp.OFP13_VERSION && header.Type == ofp13.OFPT_PACKET_OUT
+}
+
+// SetSend sets how the messages routed to the main connection are written,
+// e.g. through its SendQueue, Conn.Write by default.
+func (g *ConnGroup) SetSend(send func(msg ofp.DataBlock) error) {
+	g.mu.Lock()
+	g.send = send
+	g.mu.Unlock()
+}
+
+// writeMain writes a message on the main connection.
+func (g *ConnGroup) writeMain(msg ofp.DataBlock) error {
+	g.mu.Lock()
+	main, send := g.main, g.send
+	g.mu.Unlock()
+	if send != nil {
+		return send(msg)
+	}
+	return main.Write(msg)
+}
+
+// route picks the connection of a message.
+func (g *ConnGroup) route(msg ofp.DataBlock) (*Conn, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.closed {
+		return nil, ErrConnGroupClosed
+	}
+	if len(g.order) == 0 || !isPacketOut(msg) {
+		return g.main, nil
+	}
+	g.next %= len(g.order)
+	c := g.aux[g.order[g.next]]
+	g.next++
+	return c, nil
+}
+
+// Write writes a message on the connection it's routed to. A failed write on
+// an auxiliary connection removes that connection, and the message is retried
+// on the main connection.
+func (g *ConnGroup) Write(msg ofp.DataBlock) error {
+	c, err := g.route(msg)
+	if err != nil {
+		return err
+	}
+	if c == g.Main() {
+		return g.writeMain(msg)
+	}
+	if err = c.Write(msg); err == nil {
+		return nil
+	}
+	g.removeConn(c)
+	c.Close()
+	return g.writeMain(msg)
+}
+
+// removeConn removes an auxiliary connection, it reports whether it was one
+// of the group.
+func (g *ConnGroup) removeConn(c *Conn) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	for i, id := range g.order {
+		if g.aux[id] == c {
+			delete(g.aux, id)
+			g.order = append(g.order[:i], g.order[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// close tears the group down, the auxiliary connections are closed.
+func (g *ConnGroup) close() {
+	g.mu.Lock()
+	if g.closed {
+		g.mu.Unlock()
+		return
+	}
+	g.closed = true
+	aux := g.aux
+	g.aux, g.order = nil, nil
+	close(g.done)
+	g.mu.Unlock()
+	for _, c := range aux {
+		c.Close()
+	}
+}
+
+// AuxRegistry associates the auxiliary connections of openflow 1.3 datapaths
+// with their main connection by Dpid. The id of a connection is the
+// AuxiliaryId of its features reply.
+type AuxRegistry struct {
+	mu     sync.Mutex
+	groups map[uint64]*ConnGroup
+}
+
+func NewAuxRegistry() *AuxRegistry {
+	return &AuxRegistry{groups: make(map[uint64]*ConnGroup)}
+}
+
+// Group gets the connection group of a datapath, nil if it has no main
+// connection.
+func (r *AuxRegistry) Group(dpid uint64) *ConnGroup {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.groups[dpid]
+}
+
+// Register adds a connection of a datapath. A main connection starts a new
+// group, tearing down the previous group of the datapath if any. An auxiliary
+// connection joins the group of the main connection and replaces a previous
+// auxiliary connection of the same id.
+func (r *AuxRegistry) Register(dpid uint64, auxId uint8, c *Conn) (*ConnGroup, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	g := r.groups[dpid]
+	if auxId == 0 {
+		if g != nil {
+			g.close()
+		}
+		g = &ConnGroup{
+			Dpid: dpid,
+			main: c,
+			aux:  make(map[uint8]*Conn),
+			done: make(chan struct{}),
+		}
+		r.groups[dpid] = g
+		return g, nil
+	}
+	if g == nil {
+		return nil, ErrNoMainConnection
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.closed {
+		return nil, ErrNoMainConnection
+	}
+	if old, ok := g.aux[auxId]; ok {
+		old.Close()
+	} else {
+		g.order = append(g.order, auxId)
+	}
+	g.aux[auxId] = c
+	return g, nil
+}
+
+// Unregister removes a connection of a datapath once it ended. When the main
+// connection ends its group is torn down and the auxiliary connections are
+// closed.
+func (r *AuxRegistry) Unregister(dpid uint64, c *Conn) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	g := r.groups[dpid]
+	if g == nil {
+		return
+	}
+	if g.Main() == c {
+		delete(r.groups, dpid)
+		g.close()
+		return
+	}
+	g.removeConn(c)
+}
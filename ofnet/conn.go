@@ -0,0 +1,187 @@
+// Package ofnet implements the openflow channel between controllers and
+// switches on top of stream connections.
+package ofnet
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+
+	"github.com/kuun/ofgo/ofp"
+
+	// Register the message decoders of the supported versions.
+	_ "github.com/kuun/ofgo/ofp10"
+	_ "github.com/kuun/ofgo/ofp13"
+)
+
+// DefaultMaxMessageSize is the largest message a 16 bits length can describe.
+const DefaultMaxMessageSize = 0xffff
+
+// ErrMessageTooLarge is returned when a received message exceeds the maximum
+// message size. The message is discarded, the connection stays usable.
+var ErrMessageTooLarge = errors.New("openflow message is too large")
+
+// Conn is an openflow connection, it reads and writes whole messages framed by
+// their header's Length. Reads and writes are each serialized, a message is
+// never interleaved with another one.
+type Conn struct {
+	conn   net.Conn
+	reader *bufio.Reader
+
+	rmu            sync.Mutex // Serializes reads, guards maxMessageSize.
+	wmu            sync.Mutex // Serializes writes.
+	maxMessageSize int
+
+	mu      sync.Mutex
+	version uint8
+	xid     uint32 // Last allocated Xid.
+}
+
+// NewConn wraps a net.Conn into an openflow connection.
+func NewConn(conn net.Conn) *Conn {
+	return &Conn{
+		conn:           conn,
+		reader:         bufio.NewReader(conn),
+		maxMessageSize: DefaultMaxMessageSize,
+	}
+}
+
+// SetMaxMessageSize sets the size of the largest message accepted by ReadMessage.
+func (c *Conn) SetMaxMessageSize(size int) {
+	c.rmu.Lock()
+	c.maxMessageSize = size
+	c.rmu.Unlock()
+}
+
+// Version gets the openflow version negotiated on the connection, 0 before
+// the hello exchange.
+func (c *Conn) Version() uint8 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.version
+}
+
+// SetVersion records the openflow version negotiated on the connection.
+func (c *Conn) SetVersion(version uint8) {
+	c.mu.Lock()
+	c.version = version
+	c.mu.Unlock()
+}
+
+// NextXid allocates a transaction id for a request sent on the connection.
+func (c *Conn) NextXid() uint32 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.xid++
+	if c.xid == 0 {
+		c.xid++
+	}
+	return c.xid
+}
+
+// NetConn gets the underlying net.Conn.
+func (c *Conn) NetConn() net.Conn {
+	return c.conn
+}
+
+func (c *Conn) LocalAddr() net.Addr {
+	return c.conn.LocalAddr()
+}
+
+func (c *Conn) RemoteAddr() net.Addr {
+	return c.conn.RemoteAddr()
+}
+
+func (c *Conn) Close() error {
+	return c.conn.Close()
+}
+
+// ReadMessage reads a whole message, header included. The returned buffer is
+// owned by the caller.
+func (c *Conn) ReadMessage() ([]byte, error) {
+	c.rmu.Lock()
+	defer c.rmu.Unlock()
+
+	var headerBuf [ofp.HeaderLength]byte
+	if _, err := io.ReadFull(c.reader, headerBuf[:]); err != nil {
+		return nil, err
+	}
+	header := ofp.Header{}
+	if _, err := header.Unmarshal(headerBuf[:]); err != nil {
+		return nil, err
+	}
+	length := int(header.Length)
+	if length < ofp.HeaderLength {
+		return nil, fmt.Errorf("bad openflow message length %d", length)
+	}
+	if length > c.maxMessageSize {
+		if _, err := io.CopyN(io.Discard, c.reader, int64(length-ofp.HeaderLength)); err != nil {
+			return nil, err
+		}
+		return nil, ErrMessageTooLarge
+	}
+	buf := make([]byte, length)
+	copy(buf, headerBuf[:])
+	if _, err := io.ReadFull(c.reader, buf[ofp.HeaderLength:]); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	return buf, nil
+}
+
+// Read reads a whole message and decodes it with the codec of the message's
+// version, message types without a typed form are returned as *ofp.RawMessage.
+func (c *Conn) Read() (ofp.DataBlock, error) {
+	buf, err := c.ReadMessage()
+	if err != nil {
+		return nil, err
+	}
+	return ofp.Decode(buf)
+}
+
+// WriteMessage writes a whole binary message.
+func (c *Conn) WriteMessage(buf []byte) error {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	return c.writeFull(buf)
+}
+
+// Write marshals and writes a message, it's marshaled in a pooled buffer.
+func (c *Conn) Write(msg ofp.DataBlock) error {
+	buf := ofp.GetBuffer()
+	defer ofp.PutBuffer(buf)
+	b, err := ofp.AppendBinary(*buf, msg)
+	if err != nil {
+		return err
+	}
+	*buf = b
+	return c.WriteMessage(b)
+}
+
+// WriteBuffers writes whole binary messages with a single vectored write
+// where the connection supports it.
+func (c *Conn) WriteBuffers(bufs *net.Buffers) (int64, error) {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	return bufs.WriteTo(c.conn)
+}
+
+// writeFull writes the whole buffer, retrying on partial writes.
+func (c *Conn) writeFull(buf []byte) error {
+	for len(buf) > 0 {
+		n, err := c.conn.Write(buf)
+		buf = buf[n:]
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			return io.ErrShortWrite
+		}
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+package ofnet
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// DefaultPort is the IANA assigned openflow port.
+const DefaultPort = 6653
+
+// Endpoint is where an openflow connection is dialed to or accepted from. It's
+// described by strings in the usual openflow switch syntax:
+//
+//	tcp:host[:port]    active TCP connection
+//	ssl:host[:port]    active TLS connection
+//	unix:path          active unix domain socket connection
+//	ptcp:[port][:ip]   passive TCP connections
+//	pssl:[port][:ip]   passive TLS connections
+//	punix:path         passive unix domain socket connections
+type Endpoint struct {
+	Passive bool   // Listens for connections instead of dialing.
+	Network string // "tcp" or "unix".
+	Address string // Address to dial or to listen on, in net package syntax.
+	TLS     bool   // The connection is secured by TLS.
+}
+
+// ParseEndpoint parses an endpoint string, the port defaults to DefaultPort.
+func ParseEndpoint(s string) (*Endpoint, error) {
+	idx := strings.Index(s, ":")
+	if idx < 0 {
+		return nil, fmt.Errorf("bad openflow endpoint %q", s)
+	}
+	kind, rest := s[:idx], s[idx+1:]
+	ep := &Endpoint{}
+	switch kind {
+	case "tcp", "ssl":
+		host, port := rest, strconv.Itoa(DefaultPort)
+		if h, p, err := net.SplitHostPort(rest); err == nil {
+			host, port = h, p
+		} else {
+			host = strings.Trim(host, "[]")
+		}
+		if host == "" {
+			return nil, fmt.Errorf("openflow endpoint %q has no host", s)
+		}
+		ep.Network, ep.Address, ep.TLS = "tcp", net.JoinHostPort(host, port), kind == "ssl"
+	case "ptcp", "pssl":
+		port, ip := rest, ""
+		if i := strings.Index(rest, ":"); i >= 0 {
+			port, ip = rest[:i], strings.Trim(rest[i+1:], "[]")
+		}
+		if port == "" {
+			port = strconv.Itoa(DefaultPort)
+		}
+		ep.Passive, ep.Network, ep.Address, ep.TLS = true, "tcp", net.JoinHostPort(ip, port), kind == "pssl"
+	case "unix", "punix":
+		if rest == "" {
+			return nil, fmt.Errorf("openflow endpoint %q has no path", s)
+		}
+		ep.Passive, ep.Network, ep.Address = kind == "punix", "unix", rest
+	default:
+		return nil, fmt.Errorf("bad openflow endpoint type %q", kind)
+	}
+	if ep.Network == "tcp" {
+		if _, port, _ := net.SplitHostPort(ep.Address); port != "" {
+			if _, err := strconv.ParseUint(port, 10, 16); err != nil {
+				return nil, fmt.Errorf("bad port in openflow endpoint %q", s)
+			}
+		}
+	}
+	return ep, nil
+}
+
+func (ep *Endpoint) String() string {
+	kind := "tcp"
+	switch {
+	case ep.Network == "unix":
+		kind = "unix"
+	case ep.TLS:
+		kind = "ssl"
+	}
+	if ep.Passive {
+		kind = "p" + kind
+	}
+	if ep.Passive && ep.Network == "tcp" {
+		host, port, _ := net.SplitHostPort(ep.Address)
+		if host == "" {
+			return kind + ":" + port
+		}
+		if strings.Contains(host, ":") {
+			host = "[" + host + "]"
+		}
+		return kind + ":" + port + ":" + host
+	}
+	return kind + ":" + ep.Address
+}
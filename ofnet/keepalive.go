@@ -0,0 +1,197 @@
+package ofnet
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/kuun/ofgo/ofp"
+)
+
+// ErrPeerDead is returned by Keepalive.Run when the peer missed too many echo
+// requests in a row.
+var ErrPeerDead = errors.New("openflow peer is not answering echo requests")
+
+// KeepaliveStats are liveness metrics of a connection.
+type KeepaliveStats struct {
+	Sent     uint64        // Echo requests sent.
+	Replied  uint64        // Echo replies received in time.
+	Missed   uint64        // Echo requests which were not answered in time.
+	Answered uint64        // Echo requests of the peer we answered.
+	LastRTT  time.Duration // Round-trip time of the last answered echo.
+	MinRTT   time.Duration
+	MaxRTT   time.Duration
+	AvgRTT   time.Duration // Exponentially weighted moving average, 1/8 weight for new samples.
+}
+
+// Keepalive keeps an openflow connection alive with echo messages. It sends
+// an echo request when nothing was received during the idle interval, answers
+// the peer's echo requests, and declares the peer dead after a number of
+// consecutive unanswered requests.
+//
+// Every message read from the connection must be passed to Received, and Run
+// must be running for requests to be sent.
+type Keepalive struct {
+	// Send writes the echo messages, e.g. through the SendQueue of the
+	// connection, Conn.Write if it's nil.
+	Send func(msg ofp.DataBlock) error
+
+	conn      *Conn
+	interval  time.Duration
+	maxMisses int
+
+	mu          sync.Mutex
+	lastRx      time.Time
+	outstanding map[uint32]time.Time // Xid of the pending echo requests -> send time.
+	misses      int
+	stats       KeepaliveStats
+}
+
+// NewKeepalive creates the keepalive of a connection. An echo request is sent
+// after 'interval' without traffic, and a request which isn't answered within
+// 'interval' is a miss.
+func NewKeepalive(conn *Conn, interval time.Duration, maxMisses int) *Keepalive {
+	if maxMisses < 1 {
+		maxMisses = 1
+	}
+	return &Keepalive{
+		conn:        conn,
+		interval:    interval,
+		maxMisses:   maxMisses,
+		lastRx:      time.Now(),
+		outstanding: make(map[uint32]time.Time),
+	}
+}
+
+// Stats gets a snapshot of the keepalive metrics.
+func (k *Keepalive) Stats() KeepaliveStats {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	return k.stats
+}
+
+// Received records activity of the peer. Echo requests are answered with a
+// response echoing their Xid and payload, echo replies to our requests update
+// the round-trip time. It returns true if the message was an echo message
+// consumed by the keepalive.
+func (k *Keepalive) Received(msg ofp.DataBlock) (handled bool, err error) {
+	now := time.Now()
+	k.mu.Lock()
+	k.lastRx = now
+	k.misses = 0
+	k.mu.Unlock()
+
+	switch msg := msg.(type) {
+	case *ofp.EchoRequest:
+		if err = k.send(ofp.NewEchoResponse(msg)); err != nil {
+			return true, err
+		}
+		k.mu.Lock()
+		k.stats.Answered++
+		k.mu.Unlock()
+		return true, nil
+	case *ofp.EchoResponse:
+		k.mu.Lock()
+		defer k.mu.Unlock()
+		sent, ok := k.outstanding[msg.Xid]
+		if !ok {
+			// Not ours, or it came too late.
+			return false, nil
+		}
+		delete(k.outstanding, msg.Xid)
+		k.recordRTT(now.Sub(sent))
+		return true, nil
+	}
+	return false, nil
+}
+
+func (k *Keepalive) recordRTT(rtt time.Duration) {
+	s := &k.stats
+	s.Replied++
+	s.LastRTT = rtt
+	if s.MinRTT == 0 || rtt < s.MinRTT {
+		s.MinRTT = rtt
+	}
+	if rtt > s.MaxRTT {
+		s.MaxRTT = rtt
+	}
+	if s.AvgRTT == 0 {
+		s.AvgRTT = rtt
+	} else {
+		s.AvgRTT += (rtt - s.AvgRTT) / 8
+	}
+}
+
+// Run sends echo requests on idle intervals until the context is done, the
+// peer is declared dead, or a write fails. The caller should close the
+// connection when it returns ErrPeerDead.
+func (k *Keepalive) Run(ctx context.Context) error {
+	tick := k.interval / 4
+	if tick <= 0 {
+		tick = k.interval
+	}
+	ticker := time.NewTicker(tick)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case now := <-ticker.C:
+			send, err := k.check(now)
+			if err != nil {
+				return err
+			}
+			if send {
+				if err = k.sendEcho(now); err != nil {
+					return err
+				}
+			}
+		}
+	}
+}
+
+// check expires the unanswered echo requests, and reports whether a new
+// request should be sent.
+func (k *Keepalive) check(now time.Time) (send bool, err error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	for xid, sent := range k.outstanding {
+		if now.Sub(sent) >= k.interval {
+			delete(k.outstanding, xid)
+			k.misses++
+			k.stats.Missed++
+		}
+	}
+	if k.misses >= k.maxMisses {
+		return false, ErrPeerDead
+	}
+	return len(k.outstanding) == 0 && now.Sub(k.lastRx) >= k.interval, nil
+}
+
+func (k *Keepalive) sendEcho(now time.Time) error {
+	version := k.conn.Version()
+	if version == 0 {
+		version = ofp.OFP10_VERSION
+	}
+	req := &ofp.EchoRequest{
+		Header: ofp.Header{
+			Version: version,
+			Type:    ofp.OFPT_ECHO_REQUEST,
+			Length:  ofp.HeaderLength,
+			Xid:     k.conn.NextXid(),
+		},
+	}
+	k.mu.Lock()
+	k.outstanding[req.Xid] = now
+	k.stats.Sent++
+	k.mu.Unlock()
+	return k.send(req)
+}
+
+func (k *Keepalive) send(msg ofp.DataBlock) error {
+	if k.Send != nil {
+		return k.Send(msg)
+	}
+	return k.conn.Write(msg)
+}
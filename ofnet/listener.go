@@ -0,0 +1,46 @@
+package ofnet
+
+import (
+	"net"
+)
+
+// Listener is a listener of openflow connections.
+type Listener struct {
+	l net.Listener
+}
+
+// Listen listens for plain openflow connections.
+func Listen(network, addr string) (*Listener, error) {
+	l, err := net.Listen(network, addr)
+	if err != nil {
+		return nil, err
+	}
+	return &Listener{l: l}, nil
+}
+
+// Accept waits for and returns the next openflow connection. For TLS
+// listeners, the handshake is completed by the first read or write.
+func (l *Listener) Accept() (*Conn, error) {
+	conn, err := l.l.Accept()
+	if err != nil {
+		return nil, err
+	}
+	return NewConn(conn), nil
+}
+
+func (l *Listener) Close() error {
+	return l.l.Close()
+}
+
+func (l *Listener) Addr() net.Addr {
+	return l.l.Addr()
+}
+
+// Dial dials a plain openflow connection.
+func Dial(network, addr string) (*Conn, error) {
+	conn, err := net.Dial(network, addr)
+	if err != nil {
+		return nil, err
+	}
+	return NewConn(conn), nil
+}
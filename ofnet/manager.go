@@ -0,0 +1,321 @@
+package ofnet
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"math"
+	"math/rand"
+	"net"
+	"sync"
+	"time"
+)
+
+// Backoff computes the delays between reconnection attempts of an active
+// endpoint. The delay grows exponentially from Initial up to Max, then a
+// random jitter of up to Jitter times the delay is added or removed.
+type Backoff struct {
+	Initial    time.Duration // Delay before the first retry.
+	Max        time.Duration // Upper bound of the delay, unbounded if it's not positive.
+	Multiplier float64       // Growth factor between attempts, at least 1.
+	Jitter     float64       // Random fraction of the delay, between 0 and 1.
+	// Stable is how long a connection must stay up for the delays to start
+	// from Initial again, Max if it's zero, or Initial without Max.
+	Stable time.Duration
+}
+
+// maxBackoffDelay bounds the delays of a backoff without Max, so that they
+// don't overflow, jitter included.
+const maxBackoffDelay = float64(math.MaxInt64 / 4)
+
+// DefaultBackoff is the backoff used when a Manager doesn't set one.
+var DefaultBackoff = Backoff{
+	Initial:    time.Second,
+	Max:        30 * time.Second,
+	Multiplier: 2,
+	Jitter:     0.2,
+}
+
+// Delay gets the delay before the retry following 'attempt' failed attempts.
+// An Initial which isn't positive is taken as DefaultBackoff.Initial, and a
+// Multiplier below 1 as 1, so the delays never shrink to a busy loop.
+func (b Backoff) Delay(attempt int) time.Duration {
+	if b.Initial <= 0 {
+		b.Initial = DefaultBackoff.Initial
+	}
+	if b.Multiplier < 1 {
+		b.Multiplier = 1
+	}
+	delay := float64(b.Initial)
+	for i := 0; i < attempt && (b.Max <= 0 || delay < float64(b.Max)) && delay < maxBackoffDelay; i++ {
+		delay *= b.Multiplier
+	}
+	if b.Max > 0 && delay > float64(b.Max) {
+		delay = float64(b.Max)
+	}
+	if delay > maxBackoffDelay {
+		delay = maxBackoffDelay
+	}
+	if b.Jitter > 0 {
+		delay += delay * b.Jitter * (2*rand.Float64() - 1)
+	}
+	return time.Duration(delay)
+}
+
+// stable gets how long a connection must stay up for the delays to start
+// from Initial again.
+func (b Backoff) stable() time.Duration {
+	switch {
+	case b.Stable > 0:
+		return b.Stable
+	case b.Max > 0:
+		return b.Max
+	}
+	return b.Initial
+}
+
+// Manager maintains the openflow connections of a list of endpoints. Passive
+// endpoints accept connections until the manager stops, active endpoints are
+// redialed with backoff whenever their connection fails or ends, which covers
+// a controller dialing switches as well as a switch dialing its controllers.
+type Manager struct {
+	// Serve runs an established connection and returns when it ends, it's
+	// called in a goroutine of its own. The manager closes the connection
+	// after Serve returns and when the manager stops.
+	Serve func(ep *Endpoint, c *Conn) error
+	// TLSConfig is used by ssl and pssl endpoints.
+	TLSConfig *tls.Config
+	// Backoff of active endpoints, DefaultBackoff if it's zero.
+	Backoff Backoff
+
+	// Lifecycle callbacks, all of them are optional.
+	OnConnect    func(ep *Endpoint, c *Conn)                        // A connection is established.
+	OnDisconnect func(ep *Endpoint, c *Conn, err error)             // A connection ended, err is what Serve returned.
+	OnError      func(ep *Endpoint, err error, retry time.Duration) // Dialing or listening failed, retried after the delay.
+}
+
+// Run parses the endpoints and maintains their connections until the context
+// is done. It returns an error if an endpoint is malformed.
+func (m *Manager) Run(ctx context.Context, endpoints ...string) error {
+	if m.Serve == nil {
+		return errors.New("openflow connection manager has no Serve function")
+	}
+	eps := make([]*Endpoint, 0, len(endpoints))
+	for _, s := range endpoints {
+		ep, err := ParseEndpoint(s)
+		if err != nil {
+			return err
+		}
+		if ep.TLS && m.TLSConfig == nil {
+			return errors.New("openflow endpoint " + s + " requires a TLS config")
+		}
+		eps = append(eps, ep)
+	}
+	var wg sync.WaitGroup
+	for _, ep := range eps {
+		wg.Add(1)
+		go func(ep *Endpoint) {
+			defer wg.Done()
+			if ep.Passive {
+				m.runPassive(ctx, ep)
+			} else {
+				m.runActive(ctx, ep)
+			}
+		}(ep)
+	}
+	wg.Wait()
+	return nil
+}
+
+func (m *Manager) backoff() Backoff {
+	if m.Backoff == (Backoff{}) {
+		return DefaultBackoff
+	}
+	return m.Backoff
+}
+
+// wait sleeps for the delay, returns false if the context is done first.
+func wait(ctx context.Context, delay time.Duration) bool {
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+func (m *Manager) dial(ctx context.Context, ep *Endpoint) (*Conn, error) {
+	dialer := &net.Dialer{}
+	if ep.TLS {
+		tlsDialer := &tls.Dialer{NetDialer: dialer, Config: m.TLSConfig}
+		conn, err := tlsDialer.DialContext(ctx, ep.Network, ep.Address)
+		if err != nil {
+			return nil, err
+		}
+		return NewConn(conn), nil
+	}
+	conn, err := dialer.DialContext(ctx, ep.Network, ep.Address)
+	if err != nil {
+		return nil, err
+	}
+	return NewConn(conn), nil
+}
+
+func (m *Manager) runActive(ctx context.Context, ep *Endpoint) {
+	backoff := m.backoff()
+	attempt := 0
+	for ctx.Err() == nil {
+		c, err := m.dial(ctx, ep)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			delay := backoff.Delay(attempt)
+			attempt++
+			if m.OnError != nil {
+				m.OnError(ep, err, delay)
+			}
+			if !wait(ctx, delay) {
+				return
+			}
+			continue
+		}
+		// A peer closing the connections right away must not be redialed
+		// without backoff.
+		start := time.Now()
+		m.serve(ctx, ep, c)
+		if time.Since(start) >= backoff.stable() {
+			attempt = 0
+		}
+		delay := backoff.Delay(attempt)
+		attempt++
+		if !wait(ctx, delay) {
+			return
+		}
+	}
+}
+
+func (m *Manager) listen(ep *Endpoint) (*Listener, error) {
+	if ep.TLS {
+		return ListenTLS(ep.Network, ep.Address, m.TLSConfig)
+	}
+	return Listen(ep.Network, ep.Address)
+}
+
+func (m *Manager) runPassive(ctx context.Context, ep *Endpoint) {
+	// The sessions outlive the listener they were accepted from, listening
+	// again doesn't wait for them.
+	var sessions sync.WaitGroup
+	defer sessions.Wait()
+	backoff := m.backoff()
+	attempt := 0
+	for ctx.Err() == nil {
+		l, err := m.listen(ep)
+		if err != nil {
+			delay := backoff.Delay(attempt)
+			attempt++
+			if m.OnError != nil {
+				m.OnError(ep, err, delay)
+			}
+			if !wait(ctx, delay) {
+				return
+			}
+			continue
+		}
+		start := time.Now()
+		m.acceptLoop(ctx, ep, l, &sessions)
+		if time.Since(start) >= backoff.stable() {
+			attempt = 0
+		}
+		delay := backoff.Delay(attempt)
+		attempt++
+		if !wait(ctx, delay) {
+			return
+		}
+	}
+}
+
+// Bounds of the delay before accepting again after a temporary error.
+const (
+	minAcceptDelay = 5 * time.Millisecond
+	maxAcceptDelay = time.Second
+)
+
+// acceptLoop accepts connections until the context is done or the listener
+// fails, the accepted connections are added to 'sessions'. Temporary errors,
+// such as running out of file descriptors, are retried after a short delay.
+func (m *Manager) acceptLoop(ctx context.Context, ep *Endpoint, l *Listener, sessions *sync.WaitGroup) {
+	stop := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-stop:
+		}
+		l.Close()
+	}()
+	defer close(stop)
+
+	var delay time.Duration
+	for {
+		c, err := l.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			if !isTemporary(err) {
+				if m.OnError != nil {
+					m.OnError(ep, err, 0)
+				}
+				return
+			}
+			if delay *= 2; delay == 0 {
+				delay = minAcceptDelay
+			} else if delay > maxAcceptDelay {
+				delay = maxAcceptDelay
+			}
+			if m.OnError != nil {
+				m.OnError(ep, err, delay)
+			}
+			if !wait(ctx, delay) {
+				return
+			}
+			continue
+		}
+		delay = 0
+		sessions.Add(1)
+		go func() {
+			defer sessions.Done()
+			m.serve(ctx, ep, c)
+		}()
+	}
+}
+
+// isTemporary reports whether an accept error is worth retrying on the same
+// listener.
+func isTemporary(err error) bool {
+	var ne interface{ Temporary() bool }
+	return errors.As(err, &ne) && ne.Temporary()
+}
+
+// serve runs the connection until Serve returns or the context is done.
+func (m *Manager) serve(ctx context.Context, ep *Endpoint, c *Conn) {
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			c.Close()
+		case <-done:
+		}
+	}()
+	if m.OnConnect != nil {
+		m.OnConnect(ep, c)
+	}
+	err := m.Serve(ep, c)
+	close(done)
+	c.Close()
+	if m.OnDisconnect != nil {
+		m.OnDisconnect(ep, c, err)
+	}
+}
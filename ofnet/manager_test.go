@@ -0,0 +1,28 @@
+package ofnet
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBackoffDelay(t *testing.T) {
+	tests := []struct {
+		name    string
+		backoff Backoff
+		attempt int
+		want    time.Duration
+	}{
+		{"first", Backoff{Initial: time.Second, Max: time.Minute, Multiplier: 2}, 0, time.Second},
+		{"grows", Backoff{Initial: time.Second, Max: time.Minute, Multiplier: 2}, 3, 8 * time.Second},
+		{"capped", Backoff{Initial: time.Second, Max: time.Minute, Multiplier: 2}, 10, time.Minute},
+		{"no max", Backoff{Initial: time.Second, Multiplier: 2}, 5, 32 * time.Second},
+		{"no max overflow", Backoff{Initial: time.Second, Multiplier: 2}, 1000, time.Duration(maxBackoffDelay)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.backoff.Delay(tt.attempt); got != tt.want {
+				t.Errorf("Delay(%d) = %v, want %v", tt.attempt, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,340 @@
+package ofnet
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/kuun/ofgo/ofp"
+	"github.com/kuun/ofgo/ofp10"
+	"github.com/kuun/ofgo/ofp13"
+)
+
+// OverflowPolicy tells what a SendQueue does with a message sent while its
+// lane is full.
+type OverflowPolicy int
+
+const (
+	OverflowBlock      OverflowPolicy = iota // Wait until the lane has room.
+	OverflowDropOldest                       // Drop the oldest queued message of the lane which may be dropped, else wait.
+	OverflowDropNew                          // Drop the sent message, Send returns ErrQueueFull.
+	OverflowDisconnect                       // Close the queue and the connection.
+)
+
+var (
+	// ErrQueueFull is returned when a message is dropped or the connection
+	// is closed because the queue is full.
+	ErrQueueFull = errors.New("openflow send queue is full")
+	// ErrQueueClosed is returned by Send once the queue is closed without a
+	// more specific error.
+	ErrQueueClosed = errors.New("openflow send queue is closed")
+)
+
+// Send queue lanes, the priority lane is drained first.
+const (
+	lanePriority = iota
+	laneBulk
+	laneCount
+)
+
+// maxQueueBatch is the number of queued messages the writer takes at most at
+// once.
+const maxQueueBatch = 64
+
+// queued is a marshaled message waiting in a lane.
+type queued struct {
+	buf []byte
+	// Sequence number of a bulk message. For a barrier, the sequence number
+	// of the last bulk message queued before it, which must be written first.
+	seq     uint64
+	barrier bool
+}
+
+// QueueStats are metrics of a SendQueue.
+type QueueStats struct {
+	Depth         int    // Messages waiting in the bulk lane.
+	PriorityDepth int    // Messages waiting in the priority lane.
+	MaxDepth      int    // Highest total depth seen.
+	Enqueued      uint64 // Messages accepted.
+	Sent          uint64 // Messages written.
+	Dropped       uint64 // Messages dropped by the overflow policy.
+	Blocked       uint64 // Sends which waited for room.
+}
+
+// SendQueue is a bounded outbound queue of a connection, written by a
+// goroutine of its own so that a slow peer doesn't block the senders. Echo
+// and barrier messages go through a priority lane so that they aren't
+// starved by bulk traffic, a barrier still waits for the bulk messages queued
+// before it since it confirms them. The overflow policy applies to the bulk
+// lane, a full priority lane always blocks so that liveness messages and
+// barriers are never dropped. OverflowDropOldest never drops flow mods, it
+// waits when the lane holds only those.
+//
+// The writer coalesces the queued messages with a BatchWriter, a batch is
+// written once the queue is drained.
+//
+// Messages are marshaled when sent, the caller may reuse them right away.
+// Messages written directly on the Conn are not ordered with the queued ones.
+type SendQueue struct {
+	conn     *Conn
+	capacity int
+	policy   OverflowPolicy
+	writer   *BatchWriter
+
+	mu      sync.Mutex
+	cond    *sync.Cond // Signaled when a lane changes or the queue is closed.
+	lanes   [laneCount][]queued
+	bulkSeq uint64 // Sequence number of the last queued bulk message.
+	stats   QueueStats
+	closed  error
+	done    chan struct{}
+}
+
+// NewSendQueue creates the send queue of a connection and starts its writer.
+// Each lane holds up to 'capacity' messages.
+func NewSendQueue(conn *Conn, capacity int, policy OverflowPolicy) *SendQueue {
+	if capacity < 1 {
+		capacity = 1
+	}
+	q := &SendQueue{
+		conn:     conn,
+		capacity: capacity,
+		policy:   policy,
+		writer:   NewBatchWriter(conn, 0, 0),
+		done:     make(chan struct{}),
+	}
+	q.cond = sync.NewCond(&q.mu)
+	go q.run()
+	return q
+}
+
+// isBarrier reports whether the marshaled message is a barrier request or
+// reply.
+func isBarrier(buf []byte) bool {
+	switch buf[0] {
+	case ofp.OFP10_VERSION:
+		return buf[1] == ofp10.OFPT_BARRIER_REQUEST || buf[1] == ofp10.OFPT_BARRIER_REPLY
+	case ofp.OFP13_VERSION:
+		return buf[1] == ofp13.OFPT_BARRIER_REQUEST || buf[1] == ofp13.OFPT_BARRIER_REPLY
+	}
+	return false
+}
+
+// isPriority reports whether the marshaled message goes through the priority
+// lane.
+func isPriority(buf []byte) bool {
+	return buf[1] == ofp.OFPT_ECHO_REQUEST || buf[1] == ofp.OFPT_ECHO_REPLY || isBarrier(buf)
+}
+
+// isDroppable reports whether OverflowDropOldest may drop the marshaled
+// message, a dropped flow mod would silently leave the flows wrong.
+func isDroppable(buf []byte) bool {
+	switch buf[0] {
+	case ofp.OFP10_VERSION:
+		return buf[1] != ofp10.OFPT_FLOW_MOD
+	case ofp.OFP13_VERSION:
+		return buf[1] != ofp13.OFPT_FLOW_MOD
+	}
+	return true
+}
+
+// dropOldest drops the oldest droppable message of a lane, it returns false
+// if there's none.
+func (q *SendQueue) dropOldest(lane int) bool {
+	messages := q.lanes[lane]
+	for i := range messages {
+		if isDroppable(messages[i].buf) {
+			copy(messages[i:], messages[i+1:])
+			messages[len(messages)-1] = queued{}
+			q.lanes[lane] = messages[:len(messages)-1]
+			q.stats.Dropped++
+			return true
+		}
+	}
+	return false
+}
+
+// Stats gets a snapshot of the queue's metrics.
+func (q *SendQueue) Stats() QueueStats {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	stats := q.stats
+	stats.PriorityDepth = len(q.lanes[lanePriority])
+	stats.Depth = len(q.lanes[laneBulk])
+	return stats
+}
+
+// Send marshals the message and queues it, applying the overflow policy when
+// its lane is full.
+func (q *SendQueue) Send(msg ofp.DataBlock) error {
+	// A queued message holds a buffer of its own size, not a pooled one which
+	// can hold the largest message.
+	buf, err := ofp.AppendBinary(make([]byte, 0, msg.Len()), msg)
+	if err != nil {
+		return err
+	}
+	lane := laneBulk
+	if isPriority(buf) {
+		lane = lanePriority
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	blocked := false
+	for q.closed == nil && len(q.lanes[lane]) >= q.capacity {
+		policy := q.policy
+		if lane == lanePriority {
+			policy = OverflowBlock
+		}
+		if policy == OverflowDropOldest && q.dropOldest(lane) {
+			continue
+		}
+		switch policy {
+		case OverflowDropNew:
+			q.stats.Dropped++
+			return ErrQueueFull
+		case OverflowDisconnect:
+			q.closeLocked(ErrQueueFull)
+			q.conn.Close()
+			return ErrQueueFull
+		default:
+			// OverflowBlock, or OverflowDropOldest with nothing droppable.
+			if !blocked {
+				blocked = true
+				q.stats.Blocked++
+			}
+			q.cond.Wait()
+		}
+	}
+	if q.closed != nil {
+		return q.closed
+	}
+	m := queued{buf: buf}
+	if lane == laneBulk {
+		q.bulkSeq++
+		m.seq = q.bulkSeq
+	} else if isBarrier(buf) {
+		m.seq, m.barrier = q.bulkSeq, true
+	}
+	q.lanes[lane] = append(q.lanes[lane], m)
+	q.stats.Enqueued++
+	if depth := len(q.lanes[lanePriority]) + len(q.lanes[laneBulk]); depth > q.stats.MaxDepth {
+		q.stats.MaxDepth = depth
+	}
+	q.cond.Broadcast()
+	return nil
+}
+
+// pop removes the next message to write, the first priority message which
+// may be written, else the oldest bulk message.
+func (q *SendQueue) pop() ([]byte, bool) {
+	bulk := q.lanes[laneBulk]
+	priority := q.lanes[lanePriority]
+	for i := range priority {
+		m := priority[i]
+		if m.barrier && len(bulk) > 0 && bulk[0].seq <= m.seq {
+			// Bulk messages queued before the barrier are still waiting.
+			continue
+		}
+		copy(priority[i:], priority[i+1:])
+		priority[len(priority)-1] = queued{}
+		q.lanes[lanePriority] = priority[:len(priority)-1]
+		return m.buf, true
+	}
+	if len(bulk) == 0 {
+		return nil, false
+	}
+	buf := bulk[0].buf
+	bulk[0] = queued{}
+	q.lanes[laneBulk] = bulk[1:]
+	return buf, true
+}
+
+// next waits for the next messages to write, up to maxQueueBatch, it returns
+// nil once the queue is closed.
+func (q *SendQueue) next() [][]byte {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for q.closed == nil {
+		var bufs [][]byte
+		for len(bufs) < maxQueueBatch {
+			buf, ok := q.pop()
+			if !ok {
+				break
+			}
+			bufs = append(bufs, buf)
+		}
+		if len(bufs) > 0 {
+			q.cond.Broadcast()
+			return bufs
+		}
+		q.cond.Wait()
+	}
+	return nil
+}
+
+// write writes messages as a batch.
+func (q *SendQueue) write(bufs [][]byte) error {
+	for _, buf := range bufs {
+		if err := q.writer.writeMessage(buf); err != nil {
+			return err
+		}
+	}
+	return q.writer.Flush()
+}
+
+func (q *SendQueue) run() {
+	defer close(q.done)
+	for {
+		bufs := q.next()
+		if bufs == nil {
+			return
+		}
+		err := q.write(bufs)
+		q.mu.Lock()
+		if err != nil {
+			q.closeLocked(err)
+			q.mu.Unlock()
+			return
+		}
+		q.stats.Sent += uint64(len(bufs))
+		q.mu.Unlock()
+	}
+}
+
+func (q *SendQueue) closeLocked(err error) {
+	if q.closed != nil {
+		return
+	}
+	q.closed = err
+	for lane := range q.lanes {
+		q.lanes[lane] = nil
+	}
+	q.cond.Broadcast()
+}
+
+// Close stops the queue, the queued messages are discarded and the following
+// sends fail. The connection stays open.
+func (q *SendQueue) Close() {
+	q.mu.Lock()
+	q.closeLocked(ErrQueueClosed)
+	q.mu.Unlock()
+}
+
+// BatchStats gets the metrics of the writes coalescing the queued messages.
+func (q *SendQueue) BatchStats() BatchStats {
+	return q.writer.Stats()
+}
+
+// Err gets the error which closed the queue, nil while it's open. A failed
+// write closes the queue with the write error.
+func (q *SendQueue) Err() error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.closed
+}
+
+// Done is closed when the queue's writer exits, after a closed queue it
+// waits for the write in progress, if any, to return.
+func (q *SendQueue) Done() <-chan struct{} {
+	return q.done
+}
@@ -0,0 +1,113 @@
+package ofnet
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/kuun/ofgo/ofp"
+	"github.com/kuun/ofgo/ofp10"
+)
+
+// TestSendQueueBarrier checks a barrier is written after the bulk messages
+// queued before it, and before the ones queued after it.
+func TestSendQueueBarrier(t *testing.T) {
+	local, remote := net.Pipe()
+	defer local.Close()
+	defer remote.Close()
+	q := NewSendQueue(NewConn(local), 16, OverflowBlock)
+	defer q.Close()
+
+	send := func(msg ofp.Message, xid uint32) {
+		msg.MessageHeader().Xid = xid
+		if err := q.Send(msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Nothing is read yet, the messages wait in the queue except the one
+	// the writer may be holding.
+	send(ofp10.NewFlowMod(), 1)
+	send(ofp10.NewFlowMod(), 2)
+	send(ofp10.NewBarrierRequest(), 3)
+	send(ofp10.NewFlowMod(), 4)
+	send(&ofp.EchoRequest{Header: ofp.Header{
+		Version: ofp.OFP10_VERSION,
+		Type:    ofp.OFPT_ECHO_REQUEST,
+		Length:  ofp.HeaderLength,
+	}}, 5)
+
+	peer := NewConn(remote)
+	seen := make(map[uint32]int)
+	for i := 0; i < 5; i++ {
+		msg, err := peer.Read()
+		if err != nil {
+			t.Fatal(err)
+		}
+		seen[msg.(ofp.Message).MessageHeader().Xid] = i
+	}
+	if seen[3] < seen[1] || seen[3] < seen[2] {
+		t.Errorf("barrier written before the flow mods it confirms: %v", seen)
+	}
+	if seen[3] > seen[4] {
+		t.Errorf("barrier written after a later flow mod: %v", seen)
+	}
+}
+
+// TestSendQueueDropOldest checks the flow mods are kept while the oldest
+// droppable message is dropped.
+func TestSendQueueDropOldest(t *testing.T) {
+	q := &SendQueue{capacity: 2, policy: OverflowDropOldest}
+	fm, err := ofp.AppendBinary(nil, ofp10.NewFlowMod())
+	if err != nil {
+		t.Fatal(err)
+	}
+	po, err := ofp.AppendBinary(nil, ofp10.NewPacketOut())
+	if err != nil {
+		t.Fatal(err)
+	}
+	q.lanes[laneBulk] = []queued{{buf: fm, seq: 1}, {buf: po, seq: 2}}
+	if !q.dropOldest(laneBulk) {
+		t.Fatal("nothing dropped")
+	}
+	if len(q.lanes[laneBulk]) != 1 || q.lanes[laneBulk][0].seq != 1 {
+		t.Errorf("dropped the flow mod instead of the packet out")
+	}
+	if q.dropOldest(laneBulk) {
+		t.Errorf("dropped a flow mod")
+	}
+}
+
+// TestSendQueueBatches checks the messages queued while a write is blocked
+// are coalesced in the following writes.
+func TestSendQueueBatches(t *testing.T) {
+	local, remote := net.Pipe()
+	defer local.Close()
+	defer remote.Close()
+	q := NewSendQueue(NewConn(local), 16, OverflowBlock)
+	defer q.Close()
+
+	const messages = 10
+	for i := 0; i < messages; i++ {
+		if err := q.Send(ofp10.NewFlowMod()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	peer := NewConn(remote)
+	for i := 0; i < messages; i++ {
+		if _, err := peer.Read(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	deadline := time.Now().Add(time.Second)
+	stats := q.BatchStats()
+	for stats.Messages < messages && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+		stats = q.BatchStats()
+	}
+	if stats.Messages != messages {
+		t.Fatalf("%d messages written, want %d", stats.Messages, messages)
+	}
+	if stats.Batches >= messages {
+		t.Errorf("%d batches for %d messages, want them coalesced", stats.Batches, messages)
+	}
+}
@@ -0,0 +1,230 @@
+package ofnet
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/kuun/ofgo/ofp"
+	"github.com/kuun/ofgo/ofp10"
+	"github.com/kuun/ofgo/ofp13"
+)
+
+// ErrRequesterClosed is returned to pending and new requests once the
+// requester is closed without a more specific error.
+var ErrRequesterClosed = errors.New("openflow requester is closed")
+
+// lateReplyWindow is how long the Xid of an abandoned request is remembered,
+// so that its late replies are dropped instead of being seen as unsolicited.
+const lateReplyWindow = time.Minute
+
+// replyMoreFlag is the "more parts follow" flag of statistics and multipart
+// replies, OFPSF_REPLY_MORE in openflow 1.0 and OFPMPF_REPLY_MORE in 1.3.
+const replyMoreFlag = 1
+
+type pendingRequest struct {
+	multipart bool
+	parts     []ofp.DataBlock
+	done      chan struct{}
+	err       error
+}
+
+// RequesterStats are counters of a requester.
+type RequesterStats struct {
+	Pending     int    // Requests waiting for their reply.
+	LateReplies uint64 // Replies received after their request was abandoned.
+}
+
+// Requester sends requests with a freshly allocated Xid and correlates the
+// replies by Xid. Every message read from the connection must be passed to
+// Dispatch.
+type Requester struct {
+	conn *Conn
+	// Timeout applied to requests whose context has no deadline, zero means
+	// no timeout.
+	Timeout time.Duration
+	// Send writes the requests, e.g. through the SendQueue of the
+	// connection, Conn.Write if it's nil.
+	Send func(msg ofp.DataBlock) error
+
+	mu          sync.Mutex
+	pending     map[uint32]*pendingRequest
+	abandoned   map[uint32]time.Time // Xid -> when the request was abandoned.
+	lateReplies uint64
+	closed      error
+}
+
+// NewRequester creates the requester of a connection.
+func NewRequester(conn *Conn) *Requester {
+	return &Requester{
+		conn:      conn,
+		pending:   make(map[uint32]*pendingRequest),
+		abandoned: make(map[uint32]time.Time),
+	}
+}
+
+// Stats gets a snapshot of the requester's counters.
+func (r *Requester) Stats() RequesterStats {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return RequesterStats{Pending: len(r.pending), LateReplies: r.lateReplies}
+}
+
+// Request sends the request and waits for its reply. If the switch answers
+// with an error message carrying the request's Xid, the *ofp.Error is returned
+// as the error.
+func (r *Requester) Request(ctx context.Context, req ofp.Message) (ofp.DataBlock, error) {
+	parts, err := r.do(ctx, req, false)
+	if err != nil {
+		return nil, err
+	}
+	return parts[0], nil
+}
+
+// RequestMultipart sends the request and waits for all parts of its
+// statistics or multipart reply.
+func (r *Requester) RequestMultipart(ctx context.Context, req ofp.Message) ([]ofp.DataBlock, error) {
+	return r.do(ctx, req, true)
+}
+
+func (r *Requester) do(ctx context.Context, req ofp.Message, multipart bool) ([]ofp.DataBlock, error) {
+	if _, ok := ctx.Deadline(); !ok && r.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, r.Timeout)
+		defer cancel()
+	}
+	xid := r.conn.NextXid()
+	req.MessageHeader().Xid = xid
+	p := &pendingRequest{multipart: multipart, done: make(chan struct{})}
+
+	r.mu.Lock()
+	if r.closed != nil {
+		err := r.closed
+		r.mu.Unlock()
+		return nil, err
+	}
+	r.pending[xid] = p
+	r.mu.Unlock()
+
+	send := r.conn.Write
+	if r.Send != nil {
+		send = r.Send
+	}
+	if err := send(req); err != nil {
+		r.abandon(xid)
+		return nil, err
+	}
+	select {
+	case <-p.done:
+		if p.err != nil {
+			return nil, p.err
+		}
+		return p.parts, nil
+	case <-ctx.Done():
+		r.abandon(xid)
+		return nil, ctx.Err()
+	}
+}
+
+// abandon forgets a pending request, its replies will be dropped.
+func (r *Requester) abandon(xid uint32) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.pending[xid]; ok {
+		delete(r.pending, xid)
+		r.abandoned[xid] = time.Now()
+	}
+}
+
+// isAsync reports whether the message type is never a reply, the types are
+// the same in openflow 1.0 and 1.3.
+func isAsync(msgType uint8) bool {
+	switch msgType {
+	case ofp.OFPT_HELLO, ofp.OFPT_ECHO_REQUEST, ofp10.OFPT_PACKET_IN,
+		ofp10.OFPT_FLOW_REMOVED, ofp10.OFPT_PORT_STATUS:
+		return true
+	}
+	return false
+}
+
+// hasMore reports whether more parts of a multipart reply follow the message.
+func hasMore(msg ofp.Message) bool {
+	if part, ok := msg.(ofp.Multipart); ok {
+		return part.More()
+	}
+	header := msg.MessageHeader()
+	isMultipart := (header.Version == ofp.OFP10_VERSION && header.Type == ofp10.OFPT_STATS_REPLY) ||
+		(header.Version == ofp.OFP13_VERSION && header.Type == ofp13.OFPT_MULTIPART_REPLY)
+	if raw, ok := msg.(*ofp.RawMessage); ok && isMultipart && len(raw.Body) >= 4 {
+		return binary.BigEndian.Uint16(raw.Body[2:])&replyMoreFlag != 0
+	}
+	return false
+}
+
+// Dispatch delivers a received message to the request waiting for it. It
+// returns true if the message was consumed as a reply, including late replies
+// of abandoned requests which are dropped.
+func (r *Requester) Dispatch(msg ofp.DataBlock) bool {
+	m, ok := msg.(ofp.Message)
+	if !ok {
+		return false
+	}
+	header := m.MessageHeader()
+	if isAsync(header.Type) {
+		return false
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	p, ok := r.pending[header.Xid]
+	if !ok {
+		if abandonedAt, ok := r.abandoned[header.Xid]; ok && time.Since(abandonedAt) < lateReplyWindow {
+			r.lateReplies++
+			return true
+		}
+		r.pruneAbandoned()
+		return false
+	}
+	if errMsg, ok := msg.(*ofp.Error); ok {
+		p.err = errMsg
+	} else {
+		p.parts = append(p.parts, msg)
+		if p.multipart && hasMore(m) {
+			return true
+		}
+	}
+	delete(r.pending, header.Xid)
+	close(p.done)
+	return true
+}
+
+// pruneAbandoned forgets abandoned requests older than the late reply window.
+func (r *Requester) pruneAbandoned() {
+	now := time.Now()
+	for xid, abandonedAt := range r.abandoned {
+		if now.Sub(abandonedAt) >= lateReplyWindow {
+			delete(r.abandoned, xid)
+		}
+	}
+}
+
+// Close fails the pending requests and the following ones with the error, or
+// with ErrRequesterClosed if it's nil. It's called when the connection ends.
+func (r *Requester) Close(err error) {
+	if err == nil {
+		err = ErrRequesterClosed
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed != nil {
+		return
+	}
+	r.closed = err
+	for xid, p := range r.pending {
+		p.err = err
+		close(p.done)
+		delete(r.pending, xid)
+	}
+}
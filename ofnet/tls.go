@@ -0,0 +1,121 @@
+package ofnet
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ErrNotSecure is returned when a certificate is required from a connection
+// which doesn't use TLS.
+var ErrNotSecure = errors.New("openflow connection is not secured by TLS")
+
+// ServerTLSConfig creates the TLS config of a controller, switches must
+// present a certificate signed by one of the client CAs.
+func ServerTLSConfig(cert tls.Certificate, clientCAs *x509.CertPool) *tls.Config {
+	return &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    clientCAs,
+		MinVersion:   tls.VersionTLS12,
+	}
+}
+
+// ClientTLSConfig creates the TLS config of the dialing side, the peer must
+// present a certificate signed by one of the root CAs for the server name.
+func ClientTLSConfig(cert tls.Certificate, rootCAs *x509.CertPool, serverName string) *tls.Config {
+	return &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		RootCAs:      rootCAs,
+		ServerName:   serverName,
+		MinVersion:   tls.VersionTLS12,
+	}
+}
+
+// ListenTLS listens for openflow connections secured by TLS.
+func ListenTLS(network, addr string, config *tls.Config) (*Listener, error) {
+	l, err := tls.Listen(network, addr, config)
+	if err != nil {
+		return nil, err
+	}
+	return &Listener{l: l}, nil
+}
+
+// DialTLS dials an openflow peer and completes the TLS handshake.
+func DialTLS(network, addr string, config *tls.Config) (*Conn, error) {
+	conn, err := tls.Dial(network, addr, config)
+	if err != nil {
+		return nil, err
+	}
+	return NewConn(conn), nil
+}
+
+// PeerCertificate completes the TLS handshake if needed and gets the verified
+// leaf certificate of the peer.
+func (c *Conn) PeerCertificate() (*x509.Certificate, error) {
+	tlsConn, ok := c.conn.(*tls.Conn)
+	if !ok {
+		return nil, ErrNotSecure
+	}
+	if err := tlsConn.Handshake(); err != nil {
+		return nil, err
+	}
+	state := tlsConn.ConnectionState()
+	if len(state.VerifiedChains) == 0 || len(state.PeerCertificates) == 0 {
+		return nil, errors.New("openflow peer presented no verified certificate")
+	}
+	return state.PeerCertificates[0], nil
+}
+
+// DpidMapper maps the verified certificate of a switch to the datapath id the
+// switch is expected to report in its FeaturesReply. It returns an error if
+// the certificate doesn't identify any datapath.
+type DpidMapper func(cert *x509.Certificate) (dpid uint64, err error)
+
+// DpidMismatchError is returned when a switch reports a datapath id which
+// differs from the identity of its certificate.
+type DpidMismatchError struct {
+	Expected uint64 // Datapath id mapped from the certificate.
+	Reported uint64 // Datapath id of the FeaturesReply.
+}
+
+func (e *DpidMismatchError) Error() string {
+	return fmt.Sprintf("datapath %016x presented the certificate of datapath %016x", e.Reported, e.Expected)
+}
+
+// VerifyDpid checks that the datapath id reported by the switch in its
+// FeaturesReply is the one its certificate was issued for. A connection
+// failing the check must be rejected.
+func (c *Conn) VerifyDpid(dpid uint64, mapper DpidMapper) error {
+	cert, err := c.PeerCertificate()
+	if err != nil {
+		return err
+	}
+	expected, err := mapper(cert)
+	if err != nil {
+		return err
+	}
+	if expected != dpid {
+		return &DpidMismatchError{Expected: expected, Reported: dpid}
+	}
+	return nil
+}
+
+// DpidFromCommonName is a DpidMapper which reads the datapath id as 16 hex
+// digits from the certificate's common name, optionally separated by colons
+// and prefixed by "dpid:", e.g. "dpid:00:00:00:00:00:00:00:01".
+func DpidFromCommonName(cert *x509.Certificate) (uint64, error) {
+	name := strings.TrimPrefix(cert.Subject.CommonName, "dpid:")
+	name = strings.Replace(name, ":", "", -1)
+	if len(name) != 16 {
+		return 0, fmt.Errorf("certificate common name %q is not a datapath id", cert.Subject.CommonName)
+	}
+	dpid, err := strconv.ParseUint(name, 16, 64)
+	if err != nil {
+		return 0, fmt.Errorf("certificate common name %q is not a datapath id", cert.Subject.CommonName)
+	}
+	return dpid, nil
+}
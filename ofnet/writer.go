@@ -0,0 +1,228 @@
+package ofnet
+
+import (
+	"net"
+	"sync"
+	"time"
+
+	"github.com/kuun/ofgo/ofp"
+)
+
+// batchChunkSize is the size of the pooled buffers messages are coalesced in.
+const batchChunkSize = 16 * 1024
+
+var chunkPool = sync.Pool{
+	New: func() interface{} {
+		b := make([]byte, 0, batchChunkSize)
+		return &b
+	},
+}
+
+// BatchStats are metrics of a BatchWriter.
+type BatchStats struct {
+	Batches          uint64 // Vectored writes issued.
+	Messages         uint64 // Messages written.
+	Bytes            uint64 // Bytes written.
+	MaxBatchMessages int    // Largest batch, in messages.
+	MaxBatchBytes    int    // Largest batch, in bytes.
+	// Histogram of batch sizes in messages, bucket i counts the batches of
+	// [2^i, 2^(i+1)) messages, the last bucket counts the larger ones.
+	BatchSizes [8]uint64
+}
+
+// BatchWriter coalesces outbound messages into pooled buffers and writes them
+// with vectored writes. A batch is flushed when it reaches the size
+// threshold, when its oldest message waited for the flush delay, and right
+// after a barrier so that barriers are never held back.
+//
+// Messages written directly on the Conn are not ordered with the batched ones.
+type BatchWriter struct {
+	conn       *Conn
+	maxBytes   int
+	flushDelay time.Duration
+
+	flushMu sync.Mutex // Serializes flushes to keep batches in order.
+
+	mu       sync.Mutex
+	chunks   []*[]byte // Pending pooled buffers, the last one is being filled.
+	messages int       // Messages in the pending batch.
+	bytes    int       // Bytes in the pending batch.
+	timer    *time.Timer
+	stats    BatchStats
+	err      error // Sticky write error.
+}
+
+// NewBatchWriter creates a batch writer of the connection. A zero flush delay
+// flushes every write.
+func NewBatchWriter(conn *Conn, maxBytes int, flushDelay time.Duration) *BatchWriter {
+	if maxBytes <= 0 {
+		maxBytes = 64 * 1024
+	}
+	return &BatchWriter{
+		conn:       conn,
+		maxBytes:   maxBytes,
+		flushDelay: flushDelay,
+	}
+}
+
+// Stats gets a snapshot of the writer's metrics.
+func (w *BatchWriter) Stats() BatchStats {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.stats
+}
+
+// Write marshals the message into the pending batch, and flushes the batch
+// if a threshold is reached. It returns the error of a previous failed flush.
+func (w *BatchWriter) Write(msg ofp.DataBlock) error {
+	length := msg.Len()
+	w.mu.Lock()
+	if w.err != nil {
+		err := w.err
+		w.mu.Unlock()
+		return err
+	}
+	buf := w.reserve(length)
+	if _, err := msg.Marshal(buf); err != nil {
+		w.unreserve(length)
+		w.mu.Unlock()
+		return err
+	}
+	flush := w.added(buf) || w.flushDelay <= 0
+	if !flush && w.timer == nil {
+		w.timer = time.AfterFunc(w.flushDelay, func() { w.Flush() })
+	}
+	w.mu.Unlock()
+
+	if flush {
+		return w.Flush()
+	}
+	return nil
+}
+
+// writeMessage copies a whole binary message into the pending batch, and
+// flushes the batch if it reaches the size threshold or after a barrier. The
+// flush delay doesn't apply, the caller flushes.
+func (w *BatchWriter) writeMessage(msg []byte) error {
+	w.mu.Lock()
+	if w.err != nil {
+		err := w.err
+		w.mu.Unlock()
+		return err
+	}
+	copy(w.reserve(len(msg)), msg)
+	flush := w.added(msg)
+	w.mu.Unlock()
+
+	if flush {
+		return w.Flush()
+	}
+	return nil
+}
+
+// added counts a message added to the pending batch, it reports whether the
+// batch must be flushed.
+func (w *BatchWriter) added(msg []byte) bool {
+	w.messages++
+	w.bytes += len(msg)
+	return w.bytes >= w.maxBytes || isBarrier(msg)
+}
+
+// reserve gets 'length' bytes at the end of the pending batch.
+func (w *BatchWriter) reserve(length int) []byte {
+	var chunk *[]byte
+	if len(w.chunks) > 0 {
+		chunk = w.chunks[len(w.chunks)-1]
+	}
+	if chunk == nil || cap(*chunk)-len(*chunk) < length {
+		if length > batchChunkSize {
+			b := make([]byte, 0, length)
+			chunk = &b
+		} else {
+			chunk = chunkPool.Get().(*[]byte)
+		}
+		w.chunks = append(w.chunks, chunk)
+	}
+	start := len(*chunk)
+	*chunk = (*chunk)[:start+length]
+	buf := (*chunk)[start:]
+	// Marshal doesn't write all padding bytes, don't leak a previous batch.
+	for i := range buf {
+		buf[i] = 0
+	}
+	return buf
+}
+
+// unreserve gives back the bytes of the last reservation.
+func (w *BatchWriter) unreserve(length int) {
+	chunk := w.chunks[len(w.chunks)-1]
+	*chunk = (*chunk)[:len(*chunk)-length]
+}
+
+// Flush writes the pending batch with a single vectored write.
+func (w *BatchWriter) Flush() error {
+	w.flushMu.Lock()
+	defer w.flushMu.Unlock()
+
+	w.mu.Lock()
+	if w.timer != nil {
+		w.timer.Stop()
+		w.timer = nil
+	}
+	chunks, messages, bytes := w.chunks, w.messages, w.bytes
+	w.chunks, w.messages, w.bytes = nil, 0, 0
+	err := w.err
+	w.mu.Unlock()
+
+	if err == nil && messages > 0 {
+		bufs := make(net.Buffers, 0, len(chunks))
+		for _, chunk := range chunks {
+			if len(*chunk) > 0 {
+				bufs = append(bufs, *chunk)
+			}
+		}
+		_, err = w.conn.WriteBuffers(&bufs)
+	}
+	for _, chunk := range chunks {
+		if cap(*chunk) == batchChunkSize {
+			*chunk = (*chunk)[:0]
+			chunkPool.Put(chunk)
+		}
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if err != nil {
+		if w.err == nil {
+			w.err = err
+		}
+		return w.err
+	}
+	if messages > 0 {
+		w.record(messages, bytes)
+	}
+	return nil
+}
+
+func (w *BatchWriter) record(messages, bytes int) {
+	s := &w.stats
+	s.Batches++
+	s.Messages += uint64(messages)
+	s.Bytes += uint64(bytes)
+	if messages > s.MaxBatchMessages {
+		s.MaxBatchMessages = messages
+	}
+	if bytes > s.MaxBatchBytes {
+		s.MaxBatchBytes = bytes
+	}
+	bucket := 0
+	for n := messages; n > 1 && bucket < len(s.BatchSizes)-1; n >>= 1 {
+		bucket++
+	}
+	s.BatchSizes[bucket]++
+}
+
+// Close flushes the pending batch, the connection stays open.
+func (w *BatchWriter) Close() error {
+	return w.Flush()
+}
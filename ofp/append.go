@@ -0,0 +1,53 @@
+package ofp
+
+import (
+	"sync"
+)
+
+// AppendBinary appends the binary form of the DataBlock to buf and returns
+// the extended buffer. It doesn't allocate when buf has enough capacity.
+func AppendBinary(buf []byte, block DataBlock) ([]byte, error) {
+	length := block.Len()
+	start := len(buf)
+	if cap(buf)-start < length {
+		grown := make([]byte, start, 2*cap(buf)+length)
+		copy(grown, buf)
+		buf = grown
+	}
+	buf = buf[:start+length]
+	b := buf[start:]
+	// Marshal doesn't write all padding bytes.
+	for i := range b {
+		b[i] = 0
+	}
+	if _, err := block.Marshal(b); err != nil {
+		return buf[:start], err
+	}
+	return buf, nil
+}
+
+// bufferSize is the capacity of the pooled buffers, the largest message.
+const bufferSize = 0xffff
+
+var bufferPool = sync.Pool{
+	New: func() interface{} {
+		b := make([]byte, 0, bufferSize)
+		return &b
+	},
+}
+
+// GetBuffer gets an empty buffer from the pool of outbound message buffers,
+// it can hold any message. The buffer must be given back with PutBuffer once
+// it's no longer used.
+func GetBuffer() *[]byte {
+	return bufferPool.Get().(*[]byte)
+}
+
+// PutBuffer gives a buffer back to the pool.
+func PutBuffer(buf *[]byte) {
+	if cap(*buf) < bufferSize {
+		return
+	}
+	*buf = (*buf)[:0]
+	bufferPool.Put(buf)
+}
@@ -0,0 +1,75 @@
+package ofp
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// Decoder decodes a whole message of an openflow version into its typed form.
+type Decoder func(buf []byte) (DataBlock, error)
+
+var (
+	decodersMu sync.RWMutex
+	decoders   = map[uint8]Decoder{}
+)
+
+// RegisterDecoder registers the message decoder of an openflow version, the
+// version packages register themselves when they are imported.
+func RegisterDecoder(version uint8, decoder Decoder) {
+	decodersMu.Lock()
+	defer decodersMu.Unlock()
+	decoders[version] = decoder
+}
+
+// Decode decodes a whole message with the decoder of the message's version.
+func Decode(buf []byte) (DataBlock, error) {
+	if len(buf) < HeaderLength {
+		return nil, errors.New("buffer is too short")
+	}
+	decodersMu.RLock()
+	decoder := decoders[buf[0]]
+	decodersMu.RUnlock()
+	if decoder == nil {
+		return nil, fmt.Errorf("no decoder for openflow version %#x", buf[0])
+	}
+	return decoder(buf)
+}
+
+// RawMessage is a message kept as binary, it's used for message types which
+// have no typed form.
+type RawMessage struct {
+	Header
+	Body []byte // Message body following the header.
+}
+
+func (msg *RawMessage) Marshal(buf []byte) (n int, err error) {
+	if len(buf) < msg.Len() {
+		return 0, errors.New("buffer is too short")
+	}
+	if n, err = msg.Header.Marshal(buf); err != nil {
+		return n, err
+	}
+	copy(buf[n:msg.Len()], msg.Body)
+	return msg.Len(), nil
+}
+
+func (msg *RawMessage) Unmarshal(buf []byte) (n int, err error) {
+	if n, err = msg.Header.Unmarshal(buf); err != nil {
+		return n, err
+	}
+	if len(buf) < msg.Len() || msg.Len() < n {
+		return 0, errors.New("buffer is too short")
+	}
+	msg.Body = append([]byte(nil), buf[n:msg.Len()]...)
+	return msg.Len(), nil
+}
+
+func (msg *RawMessage) Len() int {
+	return int(msg.Header.Length)
+}
+
+// AppendBinary appends the message's binary form to buf.
+func (msg *RawMessage) AppendBinary(buf []byte) ([]byte, error) {
+	return AppendBinary(buf, msg)
+}
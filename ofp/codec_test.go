@@ -0,0 +1,85 @@
+package ofp_test
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/kuun/ofgo/ofp"
+	"github.com/kuun/ofgo/ofp10"
+	"github.com/kuun/ofgo/ofp13"
+)
+
+// TestDecodeRoundTrip checks the messages decode to their typed form, which
+// marshals to the same bytes.
+func TestDecodeRoundTrip(t *testing.T) {
+	fm10 := ofp10.NewFlowMod()
+	fm10.Match.Wildcards = ofp10.OFPFW_ALL &^ ofp10.OFPFW_IN_PORT
+	fm10.Match.InPort = 1
+	fm10.Priority = 100
+	output10 := ofp10.NewActionOutput()
+	output10.Port = 2
+	fm10.AddAction(output10)
+
+	po10 := ofp10.NewPacketOut()
+	po10.AddAction(output10)
+	po10.SetData([]byte{1, 2, 3, 4, 5, 6})
+
+	fm13 := ofp13.NewFlowMod()
+	fm13.Priority = 100
+	fm13.AddInstruction(ofp13.NewInstructionApplyActions().AddAction(ofp13.NewActionOutput(2)))
+
+	tests := []struct {
+		name string
+		msg  ofp.DataBlock
+	}{
+		{"hello 1.0", ofp10.NewHello()},
+		{"hello 1.3", ofp13.NewHello(ofp.OFP10_VERSION, ofp.OFP13_VERSION)},
+		{"echo request", ofp10.NewEchoRequest()},
+		{"error", ofp.NewError(ofp.OFP10_VERSION, ofp.OFPET_BAD_REQUEST, 0, []byte{1, 2, 3})},
+		{"barrier request", ofp10.NewBarrierRequest()},
+		{"flow mod 1.0", fm10},
+		{"packet out 1.0", po10},
+		{"port status 1.0", ofp10.NewPortStatus()},
+		{"flow mod 1.3", fm13},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buf, err := ofp.AppendBinary(nil, tt.msg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			decoded, err := ofp.Decode(buf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if reflect.TypeOf(decoded) != reflect.TypeOf(tt.msg) {
+				t.Fatalf("decoded a %T, want a %T", decoded, tt.msg)
+			}
+			again, err := ofp.AppendBinary(nil, decoded)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(again, buf) {
+				t.Errorf("decoded message marshals to %x, want %x", again, buf)
+			}
+		})
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		buf  []byte
+	}{
+		{"short", []byte{ofp.OFP10_VERSION, 0, 0}},
+		{"unknown version", []byte{0x7f, 0, 0, 8, 0, 0, 0, 0}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ofp.Decode(tt.buf); err == nil {
+				t.Error("Decode succeeded")
+			}
+		})
+	}
+}
@@ -27,14 +27,10 @@ func (msg *EchoRequest) Unmarshal(buf []byte) (n int, err error) {
 	if n, err = msg.Header.Unmarshal(buf); err != nil {
 		return n, err
 	}
-	if len(buf) < msg.Len() {
+	if len(buf) < msg.Len() || msg.Len() < HeaderLength {
 		return 0, errors.New("buffer is too short")
 	}
-	dataLen := msg.Len() - n
-	if dataLen > 0 {
-		msg.data = make([]byte, dataLen, dataLen)
-		copy(msg.data, buf[n:])
-	}
+	msg.data = append([]byte(nil), buf[n:msg.Len()]...)
 	return msg.Len(), nil
 }
 
@@ -45,7 +41,7 @@ func (msg *EchoRequest) Len() int {
 // SetData sets the message's payload data. the message will own the 'data'.
 func (msg *EchoRequest) SetData(data []byte) *EchoRequest {
 	msg.data = data
-	msg.Header.Length = uint16(msg.Len() + len(data))
+	msg.Header.Length = uint16(HeaderLength + len(data))
 
 	return msg
 }
@@ -54,3 +50,8 @@ func (msg *EchoRequest) SetData(data []byte) *EchoRequest {
 func (msg *EchoRequest) Data() []byte {
 	return msg.data
 }
+
+// AppendBinary appends the message's binary form to buf.
+func (msg *EchoRequest) AppendBinary(buf []byte) ([]byte, error) {
+	return AppendBinary(buf, msg)
+}
@@ -25,17 +25,13 @@ func (msg *EchoResponse) Marshal(buf []byte) (n int, err error) {
 }
 
 func (msg *EchoResponse) Unmarshal(buf []byte) (n int, err error) {
-	if len(buf) < msg.Len() {
-		return 0, errors.New("buffer is too short")
-	}
 	if n, err = msg.Header.Unmarshal(buf); err != nil {
 		return n, err
 	}
-	dataLen := msg.Len() - n
-	if dataLen > 0 {
-		msg.data = make([]byte, dataLen, dataLen)
-		copy(msg.data, buf[n:])
+	if len(buf) < msg.Len() || msg.Len() < HeaderLength {
+		return 0, errors.New("buffer is too short")
 	}
+	msg.data = append([]byte(nil), buf[n:msg.Len()]...)
 	return msg.Len(), nil
 }
 
@@ -44,14 +40,32 @@ func (msg *EchoResponse) Len() int {
 }
 
 // SetData sets the message's payload data. the message will own the 'data'.
-func (msg *EchoResponse) SetData(data []byte, len int) *EchoResponse {
+func (msg *EchoResponse) SetData(data []byte) *EchoResponse {
 	msg.data = data
-	
+	msg.Header.Length = uint16(HeaderLength + len(data))
+
 	return msg
 }
 
+// NewEchoResponse creates the response of an echo request, it carries the
+// request's version, Xid and payload data.
+func NewEchoResponse(req *EchoRequest) *EchoResponse {
+	resp := &EchoResponse{
+		Header: Header{
+			Version: req.Version,
+			Type:    OFPT_ECHO_REPLY,
+			Xid:     req.Xid,
+		},
+	}
+	return resp.SetData(req.Data())
+}
+
 // Data gets the message's payload data.
 func (msg *EchoResponse) Data() []byte {
 	return msg.data
 }
 
+// AppendBinary appends the message's binary form to buf.
+func (msg *EchoResponse) AppendBinary(buf []byte) ([]byte, error) {
+	return AppendBinary(buf, msg)
+}
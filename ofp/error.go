@@ -3,6 +3,7 @@ package ofp
 import (
 	"encoding/binary"
 	"errors"
+	"fmt"
 )
 
 // Values for 'Type' in Error.  These values are immutable: they
@@ -97,25 +98,50 @@ func (msg *Error) Marshal(buf []byte) (n int, err error) {
 	n += 2
 	binary.BigEndian.PutUint16(buf[n:], msg.Code)
 	n += 2
-	copy(buf[n:], msg.Data)
-	return n, nil
+	copy(buf[n:msg.Len()], msg.Data)
+	return msg.Len(), nil
 }
 
 func (msg *Error) Unmarshal(buf []byte) (n int, err error) {
 	if n, err = msg.Header.Unmarshal(buf); err != nil {
 		return
 	}
-	if len(buf) < msg.Len() {
+	if len(buf) < msg.Len() || msg.Len() < HeaderLength+4 {
 		return 0, errors.New("buffer is too short")
 	}
 	msg.Type = binary.BigEndian.Uint16(buf[n:])
 	n += 2
 	msg.Code = binary.BigEndian.Uint16(buf[n:])
 	n += 2
-	copy(msg.Data, buf[n:msg.Len()-n])
+	msg.Data = append([]byte(nil), buf[n:msg.Len()]...)
 	return msg.Len(), nil
 }
 
 func (msg *Error) Len() int {
 	return int(msg.Header.Length)
 }
+
+// NewError creates an error message of the openflow version, the message will
+// own the 'data'.
+func NewError(version uint8, errType, code uint16, data []byte) *Error {
+	return &Error{
+		Header: Header{
+			Version: version,
+			Type:    OFPT_ERROR,
+			Length:  uint16(HeaderLength + 4 + len(data)),
+		},
+		Type: errType,
+		Code: code,
+		Data: data,
+	}
+}
+
+// Error makes the error message usable as a go error.
+func (msg *Error) Error() string {
+	return fmt.Sprintf("openflow error: type %d, code %d, xid %d", msg.Type, msg.Code, msg.Xid)
+}
+
+// AppendBinary appends the message's binary form to buf.
+func (msg *Error) AppendBinary(buf []byte) ([]byte, error) {
+	return AppendBinary(buf, msg)
+}
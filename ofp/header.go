@@ -49,3 +49,9 @@ func (h *Header) Unmarshal(b []byte) (n int, err error) {
 func (h *Header) Len() int {
 	return HeaderLength
 }
+
+// MessageHeader gets the header itself, it's promoted to all messages
+// embedding Header.
+func (h *Header) MessageHeader() *Header {
+	return h
+}
@@ -1,7 +1,244 @@
 package ofp
 
+import (
+	"encoding/binary"
+	"errors"
+)
+
+// Hello element types.
+const (
+	OFPHET_VERSIONBITMAP = 1 // Bitmap of version supported.
+)
+
+// helloElemHeaderLen is the binary length of a hello element header.
+const helloElemHeaderLen = 4
+
+// HelloElem is an element carried in the body of a hello message.
+type HelloElem interface {
+	DataBlock
+	// Type gets the element type, one of OFPHET_*.
+	Type() uint16
+}
+
+// HelloElemHeader is common to all hello elements. Length excludes the
+// padding used to make the element 64-bit aligned.
+type HelloElemHeader struct {
+	ElemType uint16 // One of OFPHET_*.
+	Length   uint16 // Length in bytes of the element, including this header, excluding padding.
+}
+
+func (h *HelloElemHeader) Marshal(buf []byte) (n int, err error) {
+	if len(buf) < helloElemHeaderLen {
+		return 0, errors.New("buffer is too short")
+	}
+	binary.BigEndian.PutUint16(buf, h.ElemType)
+	binary.BigEndian.PutUint16(buf[2:], h.Length)
+	return helloElemHeaderLen, nil
+}
+
+func (h *HelloElemHeader) Unmarshal(buf []byte) (n int, err error) {
+	if len(buf) < helloElemHeaderLen {
+		return 0, errors.New("buffer is too short")
+	}
+	h.ElemType = binary.BigEndian.Uint16(buf)
+	h.Length = binary.BigEndian.Uint16(buf[2:])
+	return helloElemHeaderLen, nil
+}
+
+func (h *HelloElemHeader) Len() int {
+	return helloElemHeaderLen
+}
+
+// HelloElemVersionBitmap is the OFPHET_VERSIONBITMAP hello element. Bit
+// 'v % 32' of Bitmaps[v / 32] is set when openflow version 'v' is supported.
+type HelloElemVersionBitmap struct {
+	HelloElemHeader
+	Bitmaps []uint32 // List of bitmaps of supported versions.
+}
+
+// NewHelloElemVersionBitmap creates a version bitmap element advertising the
+// given versions.
+func NewHelloElemVersionBitmap(versions ...uint8) *HelloElemVersionBitmap {
+	elem := &HelloElemVersionBitmap{
+		HelloElemHeader: HelloElemHeader{ElemType: OFPHET_VERSIONBITMAP},
+	}
+	for _, v := range versions {
+		idx := int(v) / 32
+		for len(elem.Bitmaps) <= idx {
+			elem.Bitmaps = append(elem.Bitmaps, 0)
+		}
+		elem.Bitmaps[idx] |= 1 << (v % 32)
+	}
+	elem.Length = uint16(helloElemHeaderLen + 4*len(elem.Bitmaps))
+	return elem
+}
+
+func (elem *HelloElemVersionBitmap) Type() uint16 {
+	return OFPHET_VERSIONBITMAP
+}
+
+// Len gets the element's binary length, including the trailing padding.
+func (elem *HelloElemVersionBitmap) Len() int {
+	return (int(elem.Length) + 7) / 8 * 8
+}
+
+func (elem *HelloElemVersionBitmap) Marshal(buf []byte) (n int, err error) {
+	if len(buf) < elem.Len() {
+		return 0, errors.New("buffer is too short")
+	}
+	if n, err = elem.HelloElemHeader.Marshal(buf); err != nil {
+		return n, err
+	}
+	for _, bitmap := range elem.Bitmaps {
+		binary.BigEndian.PutUint32(buf[n:], bitmap)
+		n += 4
+	}
+	for ; n < elem.Len(); n++ {
+		buf[n] = 0
+	}
+	return n, nil
+}
+
+func (elem *HelloElemVersionBitmap) Unmarshal(buf []byte) (n int, err error) {
+	if n, err = elem.HelloElemHeader.Unmarshal(buf); err != nil {
+		return n, err
+	}
+	if int(elem.Length) < helloElemHeaderLen || len(buf) < elem.Len() {
+		return 0, errors.New("bad hello element length")
+	}
+	count := (int(elem.Length) - helloElemHeaderLen) / 4
+	elem.Bitmaps = make([]uint32, count)
+	for i := 0; i < count; i++ {
+		elem.Bitmaps[i] = binary.BigEndian.Uint32(buf[n:])
+		n += 4
+	}
+	return elem.Len(), nil
+}
+
+// Supports reports whether the bitmap advertises the version.
+func (elem *HelloElemVersionBitmap) Supports(version uint8) bool {
+	idx := int(version) / 32
+	if idx >= len(elem.Bitmaps) {
+		return false
+	}
+	return elem.Bitmaps[idx]&(1<<(version%32)) != 0
+}
+
 // Hello is openflow hello message.
 type Hello struct {
 	Header
+	Elements []HelloElem // Hello element list, only understood since openflow 1.3.
 }
 
+func (msg *Hello) Marshal(buf []byte) (n int, err error) {
+	if len(buf) < msg.Len() {
+		return 0, errors.New("buffer is too short")
+	}
+	if n, err = msg.Header.Marshal(buf); err != nil {
+		return n, err
+	}
+	for _, elem := range msg.Elements {
+		var m int
+		if m, err = elem.Marshal(buf[n:]); err != nil {
+			return n + m, err
+		}
+		n += m
+	}
+	return msg.Len(), nil
+}
+
+// Unmarshal unmarshals the hello message, elements of unknown type are
+// ignored as the specification requires.
+func (msg *Hello) Unmarshal(buf []byte) (n int, err error) {
+	if n, err = msg.Header.Unmarshal(buf); err != nil {
+		return n, err
+	}
+	if len(buf) < msg.Len() || msg.Len() < HeaderLength {
+		return 0, errors.New("buffer is too short")
+	}
+	msg.Elements = nil
+	for n+helloElemHeaderLen <= msg.Len() {
+		elemHeader := HelloElemHeader{}
+		if _, err = elemHeader.Unmarshal(buf[n:]); err != nil {
+			return n, err
+		}
+		elemLen := (int(elemHeader.Length) + 7) / 8 * 8
+		if elemHeader.Length < helloElemHeaderLen || n+elemLen > msg.Len() {
+			return n, errors.New("bad hello element length")
+		}
+		if elemHeader.ElemType == OFPHET_VERSIONBITMAP {
+			elem := &HelloElemVersionBitmap{}
+			if _, err = elem.Unmarshal(buf[n : n+elemLen]); err != nil {
+				return n, err
+			}
+			msg.Elements = append(msg.Elements, elem)
+		}
+		n += elemLen
+	}
+	return msg.Len(), nil
+}
+
+func (msg *Hello) Len() int {
+	return int(msg.Header.Length)
+}
+
+// AddElement appends a hello element to the message and updates the message length.
+func (msg *Hello) AddElement(elem HelloElem) *Hello {
+	msg.Elements = append(msg.Elements, elem)
+	msg.Header.Length += uint16(elem.Len())
+	return msg
+}
+
+// VersionBitmap gets the message's version bitmap element, returns nil if the
+// peer didn't send one.
+func (msg *Hello) VersionBitmap() *HelloElemVersionBitmap {
+	for _, elem := range msg.Elements {
+		if bitmap, ok := elem.(*HelloElemVersionBitmap); ok {
+			return bitmap
+		}
+	}
+	return nil
+}
+
+// supports reports whether the sender of the hello supports the version.
+// Without a version bitmap, the sender supports only its header version.
+func (msg *Hello) supports(version uint8) bool {
+	if bitmap := msg.VersionBitmap(); bitmap != nil {
+		return bitmap.Supports(version)
+	}
+	return version == msg.Version
+}
+
+// Negotiate picks the openflow version used on a connection from the hello
+// we sent and the hello we received. If both hellos carry a version bitmap,
+// the highest version set in both bitmaps wins, otherwise the smaller header
+// version is used provided that both sides support it. When negotiation
+// fails the returned error is an OFPET_HELLO_FAILED/OFPHFC_INCOMPATIBLE
+// *Error message ready to be sent to the peer.
+func Negotiate(local, remote *Hello) (version uint8, err error) {
+	localBitmap, remoteBitmap := local.VersionBitmap(), remote.VersionBitmap()
+	if localBitmap != nil && remoteBitmap != nil {
+		for v := 255; v > 0; v-- {
+			if localBitmap.Supports(uint8(v)) && remoteBitmap.Supports(uint8(v)) {
+				return uint8(v), nil
+			}
+		}
+	} else {
+		version = local.Version
+		if remote.Version < version {
+			version = remote.Version
+		}
+		if local.supports(version) && (remoteBitmap == nil || remote.supports(version)) {
+			return version, nil
+		}
+	}
+	errMsg := NewError(local.Version, OFPET_HELLO_FAILED, OFPHFC_INCOMPATIBLE,
+		[]byte("no common openflow version"))
+	errMsg.Xid = remote.Xid
+	return 0, errMsg
+}
+
+// AppendBinary appends the message's binary form to buf.
+func (msg *Hello) AppendBinary(buf []byte) ([]byte, error) {
+	return AppendBinary(buf, msg)
+}
@@ -0,0 +1,83 @@
+package ofp
+
+import (
+	"errors"
+	"testing"
+)
+
+// testHello gets a hello with a header version, and a version bitmap if
+// versions are given.
+func testHello(version uint8, versions ...uint8) *Hello {
+	hello := &Hello{Header: Header{
+		Version: version,
+		Type:    OFPT_HELLO,
+		Length:  HeaderLength,
+		Xid:     7,
+	}}
+	if len(versions) > 0 {
+		hello.AddElement(NewHelloElemVersionBitmap(versions...))
+	}
+	return hello
+}
+
+func TestNegotiate(t *testing.T) {
+	tests := []struct {
+		name          string
+		local, remote *Hello
+		want          uint8 // Zero if the negotiation fails.
+	}{
+		{"same version", testHello(OFP13_VERSION), testHello(OFP13_VERSION), OFP13_VERSION},
+		{"remote lower", testHello(OFP13_VERSION), testHello(OFP10_VERSION), 0},
+		{"local lower", testHello(OFP10_VERSION), testHello(OFP13_VERSION), OFP10_VERSION},
+		{"bitmaps", testHello(OFP13_VERSION, OFP10_VERSION, OFP13_VERSION), testHello(OFP13_VERSION, OFP10_VERSION, OFP13_VERSION), OFP13_VERSION},
+		{"bitmaps highest common", testHello(OFP13_VERSION, OFP10_VERSION, OFP13_VERSION), testHello(OFP13_VERSION, OFP10_VERSION, 0x05), OFP10_VERSION},
+		{"bitmaps disjoint", testHello(OFP13_VERSION, OFP13_VERSION), testHello(OFP10_VERSION, OFP10_VERSION), 0},
+		{"local bitmap", testHello(OFP13_VERSION, OFP10_VERSION, OFP13_VERSION), testHello(OFP10_VERSION), OFP10_VERSION},
+		{"local bitmap without the version", testHello(OFP13_VERSION, OFP13_VERSION), testHello(OFP10_VERSION), 0},
+		{"remote bitmap", testHello(OFP10_VERSION), testHello(OFP13_VERSION, OFP10_VERSION, OFP13_VERSION), OFP10_VERSION},
+		{"remote bitmap without the version", testHello(OFP10_VERSION), testHello(OFP13_VERSION, OFP13_VERSION), 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			version, err := Negotiate(tt.local, tt.remote)
+			if tt.want != 0 {
+				if err != nil || version != tt.want {
+					t.Errorf("Negotiate = %#x, %v, want %#x", version, err, tt.want)
+				}
+				return
+			}
+			var errMsg *Error
+			if !errors.As(err, &errMsg) {
+				t.Fatalf("Negotiate = %#x, %v, want an error message", version, err)
+			}
+			if errMsg.Type != OFPET_HELLO_FAILED || errMsg.Code != OFPHFC_INCOMPATIBLE || errMsg.Xid != tt.remote.Xid {
+				t.Errorf("error message %+v, want an incompatible hello failure of xid %d", errMsg, tt.remote.Xid)
+			}
+		})
+	}
+}
+
+func TestHelloRoundTrip(t *testing.T) {
+	hello := testHello(OFP13_VERSION, OFP10_VERSION, OFP13_VERSION)
+	buf, err := hello.AppendBinary(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(buf) != hello.Len() || len(buf)%8 != 0 {
+		t.Fatalf("hello of %d bytes, want %d bytes 64-bit aligned", len(buf), hello.Len())
+	}
+	var decoded Hello
+	if _, err = decoded.Unmarshal(buf); err != nil {
+		t.Fatal(err)
+	}
+	bitmap := decoded.VersionBitmap()
+	if bitmap == nil {
+		t.Fatal("version bitmap lost")
+	}
+	for v := 0; v < 8; v++ {
+		want := v == OFP10_VERSION || v == OFP13_VERSION
+		if got := bitmap.Supports(uint8(v)); got != want {
+			t.Errorf("version %#x supported %v, want %v", v, got, want)
+		}
+	}
+}
@@ -13,3 +13,18 @@ type DataBlock interface {
 	// Len gets DataBlock's binary length by byte.
 	Len() int
 }
+
+// Message is an openflow message, a DataBlock which starts with a Header.
+type Message interface {
+	DataBlock
+	// MessageHeader gets the message's header, all messages get it by
+	// embedding Header.
+	MessageHeader() *Header
+}
+
+// Multipart is implemented by reply messages which may be split in several
+// parts, like openflow 1.0 statistics replies and 1.3 multipart replies.
+type Multipart interface {
+	// More reports whether more parts of the reply follow this one.
+	More() bool
+}
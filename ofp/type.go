@@ -0,0 +1,9 @@
+package ofp
+
+// Immutable message types, they are the same in all openflow versions.
+const (
+	OFPT_HELLO        = 0 // Symmetric message.
+	OFPT_ERROR        = 1 // Symmetric message.
+	OFPT_ECHO_REQUEST = 2 // Symmetric message.
+	OFPT_ECHO_REPLY   = 3 // Symmetric message.
+)
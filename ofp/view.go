@@ -0,0 +1,45 @@
+package ofp
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+// HeaderView is a read-only view of a message header over received bytes,
+// fields are read from the bytes when accessed.
+type HeaderView []byte
+
+// NewHeaderView creates the header view of a message, 'buf' must hold at
+// least the header.
+func NewHeaderView(buf []byte) (HeaderView, error) {
+	if len(buf) < HeaderLength {
+		return nil, errors.New("buffer is too short")
+	}
+	return HeaderView(buf[:HeaderLength]), nil
+}
+
+func (v HeaderView) Version() uint8 {
+	return v[0]
+}
+
+func (v HeaderView) Type() uint8 {
+	return v[1]
+}
+
+func (v HeaderView) Length() uint16 {
+	return binary.BigEndian.Uint16(v[2:])
+}
+
+func (v HeaderView) Xid() uint32 {
+	return binary.BigEndian.Uint32(v[4:])
+}
+
+// Header copies the viewed header into an owned Header.
+func (v HeaderView) Header() Header {
+	return Header{
+		Version: v.Version(),
+		Type:    v.Type(),
+		Length:  v.Length(),
+		Xid:     v.Xid(),
+	}
+}
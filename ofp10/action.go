@@ -2,6 +2,8 @@ package ofp10
 
 import (
 	"encoding/binary"
+	"errors"
+
 	"github.com/kuun/ofgo/ofp"
 )
 
@@ -25,6 +27,7 @@ const (
 type ActionType uint16
 
 type Action interface {
+	ofp.DataBlock
 	Type() ActionType
 }
 
@@ -164,6 +167,7 @@ func (self *ActionEnqueue) Unmarshal(buff []byte) (n int, err error) {
 	self.Port = binary.BigEndian.Uint16(buff[n:])
 	n += 8
 	self.QueueId = binary.BigEndian.Uint32(buff[n:])
+	n += 4
 	return n, nil
 }
 
@@ -459,11 +463,57 @@ func (self *ActionTpPort) Unmarshal(buff []byte) (n int, err error) {
 	return n, nil
 }
 
+// ActionStripVlan is action for OFPAT_STRIP_VLAN.
+type ActionStripVlan struct {
+	ActionHeader
+	Pad [4]byte
+}
+
+func NewActionStripVlan() *ActionStripVlan {
+	return &ActionStripVlan{
+		ActionHeader: ActionHeader{Type: OFPAT_STRIP_VLAN, Length: 8},
+	}
+}
+
+func (self *ActionStripVlan) Type() ActionType {
+	return self.ActionHeader.Type
+}
+
+func (self *ActionStripVlan) Len() int {
+	return int(self.ActionHeader.Length)
+}
+
+func (self *ActionStripVlan) Marshal(buff []byte) (n int, err error) {
+	if len(buff) < self.Len() {
+		return 0, ofp.NewNoBuffError()
+	}
+	if n, err = self.ActionHeader.Marshal(buff); err != nil {
+		return n, err
+	}
+	n += 4
+	return n, nil
+}
+
+func (self *ActionStripVlan) Unmarshal(buff []byte) (n int, err error) {
+	if len(buff) < self.Len() {
+		return 0, ofp.NewNoBuffError()
+	}
+	if n, err = self.ActionHeader.Unmarshal(buff); err != nil {
+		return n, err
+	}
+	n += 4
+	return n, nil
+}
+
 type ActionVendorHeader struct {
 	ActionHeader
 	Vendor uint32
 }
 
+func (self *ActionVendorHeader) Type() ActionType {
+	return self.ActionHeader.Type
+}
+
 func (self *ActionVendorHeader) Len() int {
 	return int(self.ActionHeader.Length)
 }
@@ -491,3 +541,76 @@ func (self *ActionVendorHeader) Unmarshal(buff []byte) (n int, err error) {
 	n += 4
 	return n, nil
 }
+
+// newAction creates an empty action of the action type, returns nil if the
+// type is unknown.
+func newAction(actionType ActionType) Action {
+	switch actionType {
+	case OFPAT_OUTPUT:
+		return NewActionOutput()
+	case OFPAT_SET_VLAN_VID:
+		return NewActionVlanVid()
+	case OFPAT_SET_VLAN_PCP:
+		return NewActionVlanPcp()
+	case OFPAT_STRIP_VLAN:
+		return NewActionStripVlan()
+	case OFPAT_SET_DL_SRC, OFPAT_SET_DL_DST:
+		return newActionDlAddr(actionType)
+	case OFPAT_SET_NW_SRC, OFPAT_SET_NW_DST:
+		return newActionNwAddr(actionType)
+	case OFPAT_SET_NW_TOS:
+		return NewActionNwTos()
+	case OFPAT_SET_TP_SRC, OFPAT_SET_TP_DST:
+		return newActionTpPort(actionType)
+	case OFPAT_ENQUEUE:
+		return NewActionEnqueue()
+	case OFPAT_VENDOR:
+		return &ActionVendorHeader{ActionHeader: ActionHeader{Type: OFPAT_VENDOR}}
+	}
+	return nil
+}
+
+// UnmarshalActions unmarshals an action list which fills the whole buffer.
+func UnmarshalActions(buff []byte) (actions []Action, err error) {
+	n := 0
+	for n < len(buff) {
+		header := ActionHeader{}
+		if _, err = header.Unmarshal(buff[n:]); err != nil {
+			return nil, err
+		}
+		length := int(header.Length)
+		if length < 8 || length%8 != 0 || n+length > len(buff) {
+			return nil, errors.New("bad action length")
+		}
+		action := newAction(header.Type)
+		if action == nil {
+			return nil, errors.New("unknown action type")
+		}
+		if _, err = action.Unmarshal(buff[n : n+length]); err != nil {
+			return nil, err
+		}
+		actions = append(actions, action)
+		n += length
+	}
+	return actions, nil
+}
+
+// ActionsLen gets the binary length of an action list.
+func ActionsLen(actions []Action) int {
+	length := 0
+	for _, action := range actions {
+		length += action.Len()
+	}
+	return length
+}
+
+// MarshalActions marshals an action list to buffer.
+func MarshalActions(buff []byte, actions []Action) (n int, err error) {
+	for _, action := range actions {
+		if _, err = action.Marshal(buff[n:]); err != nil {
+			return n, err
+		}
+		n += action.Len()
+	}
+	return n, nil
+}
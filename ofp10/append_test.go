@@ -0,0 +1,68 @@
+package ofp10
+
+import (
+	"testing"
+)
+
+func benchFlowMod() *FlowMod {
+	fm := NewFlowMod()
+	fm.Match.Wildcards = OFPFW_ALL &^ OFPFW_IN_PORT
+	fm.Match.InPort = 1
+	fm.Priority = 0x8000
+	output := NewActionOutput()
+	output.Port = 2
+	fm.AddAction(output)
+	return fm
+}
+
+func benchPacketOut() *PacketOut {
+	po := NewPacketOut()
+	output := NewActionOutput()
+	output.Port = OFPP_FLOOD
+	po.AddAction(output)
+	po.SetData(make([]byte, 64))
+	return po
+}
+
+func BenchmarkFlowModAppendBinary(b *testing.B) {
+	fm := benchFlowMod()
+	buf := make([]byte, 0, fm.Len())
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		var err error
+		if buf, err = fm.AppendBinary(buf[:0]); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkPacketOutAppendBinary(b *testing.B) {
+	po := benchPacketOut()
+	buf := make([]byte, 0, po.Len())
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		var err error
+		if buf, err = po.AppendBinary(buf[:0]); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// TestAppendBinaryAllocs checks the encoding into a buffer having enough
+// capacity doesn't allocate.
+func TestAppendBinaryAllocs(t *testing.T) {
+	fm, po := benchFlowMod(), benchPacketOut()
+	buf := make([]byte, 0, fm.Len()+po.Len())
+	allocs := testing.AllocsPerRun(100, func() {
+		var err error
+		if buf, err = fm.AppendBinary(buf[:0]); err != nil {
+			t.Fatal(err)
+		}
+		if buf, err = po.AppendBinary(buf); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if allocs != 0 {
+		t.Errorf("AppendBinary allocated %v times per run, want 0", allocs)
+	}
+}
@@ -23,7 +23,8 @@ type FeaturesReply struct {
 	ofp.Header
 	Dpid         uint64  // Datapath unique id, the lower 48-bits are for a MAC address, while the upper 16-bits are implementer-defined.
 	NBuffers     uint32  // Max packets buffered at once.
-	pad           [3]byte // Align to 64-bits.
+	NTables      uint8   // Number of tables supported by datapath.
+	pad          [3]byte // Align to 64-bits.
 	Capabilities uint32  // Datapath capabilities, bitmap of OFPC_*
 	Actions      uint32  // Supported actions, bitmap of OFPAT_*
 	Ports        []Port // Port definitions.  The number of ports is inferred from the length field in the header.
@@ -33,22 +34,51 @@ func (msg *FeaturesReply) Len() int {
 	return int(msg.Length)
 }
 
+// features reply binary size without ports, in byte
+const featuresReplySize = 32
+
 func (msg *FeaturesReply) Marshal(buf []byte) (n int, err error) {
-	panic("not implement!")
+	if len(buf) < msg.Len() {
+		return 0, errors.New("buffer is too short")
+	}
+	if n, err = msg.Header.Marshal(buf); err != nil {
+		return
+	}
+	binary.BigEndian.PutUint64(buf[n:], msg.Dpid)
+	n += 8
+	binary.BigEndian.PutUint32(buf[n:], msg.NBuffers)
+	n += 4
+	buf[n] = msg.NTables
+	n++
+	copy(buf[n:], msg.pad[:])
+	n += 3
+	binary.BigEndian.PutUint32(buf[n:], msg.Capabilities)
+	n += 4
+	binary.BigEndian.PutUint32(buf[n:], msg.Actions)
+	n += 4
+	for i := range msg.Ports {
+		var m int
+		if m, err = msg.Ports[i].Marshal(buf[n:]); err != nil {
+			return n + m, err
+		}
+		n += m
+	}
+	return
 }
 
 func (msg *FeaturesReply) Unmarshal(buf []byte) (n int, err error) {
 	if n, err = msg.Header.Unmarshal(buf); err != nil {
 		return
 	}
-	if len(buf) < msg.Len() {
+	if len(buf) < msg.Len() || msg.Len() < featuresReplySize {
 		return 0, errors.New("buffer is too short")
 	}
-	n += msg.Header.Len()
 	msg.Dpid = binary.BigEndian.Uint64(buf[n:])
 	n += 8
 	msg.NBuffers = binary.BigEndian.Uint32(buf[n:])
 	n += 4
+	msg.NTables = buf[n]
+	n++
 	n += 3 // 3 bytes pad
 	msg.Capabilities = binary.BigEndian.Uint32(buf[n:])
 	n += 4
@@ -56,6 +86,7 @@ func (msg *FeaturesReply) Unmarshal(buf []byte) (n int, err error) {
 	n += 4
 	leftSize := int(msg.Len()) - n
 	portNum := leftSize / portSize
+	msg.Ports = nil
 	for i := 0; i < portNum; i++ {
 		port := Port{}
 		var m int
@@ -67,3 +98,8 @@ func (msg *FeaturesReply) Unmarshal(buf []byte) (n int, err error) {
 	}
 	return
 }
+
+// AppendBinary appends the message's binary form to buf.
+func (msg *FeaturesReply) AppendBinary(buf []byte) ([]byte, error) {
+	return ofp.AppendBinary(buf, msg)
+}
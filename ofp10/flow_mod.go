@@ -0,0 +1,155 @@
+package ofp10
+
+import (
+	"encoding/binary"
+	"errors"
+
+	"github.com/kuun/ofgo/ofp"
+)
+
+// Flow mod commands.
+const (
+	OFPFC_ADD           = iota // New flow.
+	OFPFC_MODIFY               // Modify all matching flows.
+	OFPFC_MODIFY_STRICT        // Modify entry strictly matching wildcards.
+	OFPFC_DELETE               // Delete all matching flows.
+	OFPFC_DELETE_STRICT        // Strictly match wildcards and priority.
+)
+
+// Flow mod flags.
+const (
+	OFPFF_SEND_FLOW_REM = 1 << iota // Send flow removed message when flow expires or is deleted.
+	OFPFF_CHECK_OVERLAP             // Check for overlapping entries first.
+	OFPFF_EMERG                     // Remark this is for emergency.
+)
+
+const (
+	OFP_DEFAULT_PRIORITY = 0x8000     // By default, choose a priority in the middle.
+	OFP_NO_BUFFER        = 0xffffffff // The packet isn't buffered on the switch.
+	OFP_FLOW_PERMANENT   = 0          // Value used in idle_timeout and hard_timeout to indicate that the entry is permanent.
+)
+
+// flow mod binary size without actions, in byte
+const flowModSize = 72
+
+// FlowMod is flow setup and teardown message, controller -> switch.
+type FlowMod struct {
+	ofp.Header
+	Match Match // Fields to match.
+
+	Cookie uint64 // Opaque controller-issued identifier.
+
+	Command     uint16 // One of OFPFC_*.
+	IdleTimeout uint16 // Idle time before discarding (seconds).
+	HardTimeout uint16 // Max time before discarding (seconds).
+	Priority    uint16 // Priority level of flow entry.
+	// Buffered packet to apply to (or OFP_NO_BUFFER). Not meaningful for
+	// OFPFC_DELETE*.
+	BufferId uint32
+	// For OFPFC_DELETE* commands, require matching entries to include this as
+	// an output port. A value of OFPP_NONE indicates no restriction.
+	OutPort uint16
+	Flags   uint16   // One of OFPFF_*.
+	Actions []Action // The action length is inferred from the length field in the header.
+}
+
+func NewFlowMod() *FlowMod {
+	return &FlowMod{
+		Header: ofp.Header{
+			Version: ofp.OFP10_VERSION,
+			Type:    OFPT_FLOW_MOD,
+			Length:  flowModSize,
+		},
+		Match:    Match{Wildcards: OFPFW_ALL},
+		Priority: OFP_DEFAULT_PRIORITY,
+		BufferId: OFP_NO_BUFFER,
+		OutPort:  OFPP_NONE,
+	}
+}
+
+// AddAction appends an action to the message and updates the message length.
+func (msg *FlowMod) AddAction(action Action) *FlowMod {
+	msg.Actions = append(msg.Actions, action)
+	msg.Header.Length += uint16(action.Len())
+	return msg
+}
+
+func (msg *FlowMod) Len() int {
+	return int(msg.Header.Length)
+}
+
+func (msg *FlowMod) Marshal(buf []byte) (n int, err error) {
+	if msg.Len() != flowModSize+ActionsLen(msg.Actions) {
+		return 0, errors.New("bad flow mod length")
+	}
+	if len(buf) < msg.Len() {
+		return 0, errors.New("buffer is too short")
+	}
+	if n, err = msg.Header.Marshal(buf); err != nil {
+		return n, err
+	}
+	var m int
+	if m, err = msg.Match.Marshal(buf[n:]); err != nil {
+		return n + m, err
+	}
+	n += msg.Match.Len()
+	binary.BigEndian.PutUint64(buf[n:], msg.Cookie)
+	n += 8
+	binary.BigEndian.PutUint16(buf[n:], msg.Command)
+	n += 2
+	binary.BigEndian.PutUint16(buf[n:], msg.IdleTimeout)
+	n += 2
+	binary.BigEndian.PutUint16(buf[n:], msg.HardTimeout)
+	n += 2
+	binary.BigEndian.PutUint16(buf[n:], msg.Priority)
+	n += 2
+	binary.BigEndian.PutUint32(buf[n:], msg.BufferId)
+	n += 4
+	binary.BigEndian.PutUint16(buf[n:], msg.OutPort)
+	n += 2
+	binary.BigEndian.PutUint16(buf[n:], msg.Flags)
+	n += 2
+	if m, err = MarshalActions(buf[n:msg.Len()], msg.Actions); err != nil {
+		return n + m, err
+	}
+	return msg.Len(), nil
+}
+
+func (msg *FlowMod) Unmarshal(buf []byte) (n int, err error) {
+	if n, err = msg.Header.Unmarshal(buf); err != nil {
+		return n, err
+	}
+	if len(buf) < msg.Len() || msg.Len() < flowModSize {
+		return 0, errors.New("buffer is too short")
+	}
+	var m int
+	if m, err = msg.Match.Unmarshal(buf[n:]); err != nil {
+		return n + m, err
+	}
+	n += msg.Match.Len()
+	msg.Cookie = binary.BigEndian.Uint64(buf[n:])
+	n += 8
+	msg.Command = binary.BigEndian.Uint16(buf[n:])
+	n += 2
+	msg.IdleTimeout = binary.BigEndian.Uint16(buf[n:])
+	n += 2
+	msg.HardTimeout = binary.BigEndian.Uint16(buf[n:])
+	n += 2
+	msg.Priority = binary.BigEndian.Uint16(buf[n:])
+	n += 2
+	msg.BufferId = binary.BigEndian.Uint32(buf[n:])
+	n += 4
+	msg.OutPort = binary.BigEndian.Uint16(buf[n:])
+	n += 2
+	msg.Flags = binary.BigEndian.Uint16(buf[n:])
+	n += 2
+	if msg.Actions, err = UnmarshalActions(buf[n:msg.Len()]); err != nil {
+		return n, err
+	}
+	return msg.Len(), nil
+}
+
+// AppendBinary appends the message's binary form to buf.
+func (msg *FlowMod) AppendBinary(buf []byte) ([]byte, error) {
+	return ofp.AppendBinary(buf, msg)
+}
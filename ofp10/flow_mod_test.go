@@ -0,0 +1,31 @@
+package ofp10
+
+import (
+	"testing"
+
+	"github.com/kuun/ofgo/ofp"
+)
+
+// TestMarshalBadLength checks the messages whose length disagrees with their
+// content fail to marshal instead of panicking.
+func TestMarshalBadLength(t *testing.T) {
+	output := NewActionOutput()
+	tests := []struct {
+		name string
+		msg  ofp.DataBlock
+	}{
+		{"zero flow mod", (&FlowMod{}).AddAction(output)},
+		{"flow mod without action length", &FlowMod{Header: ofp.Header{Length: flowModSize}, Actions: []Action{output}}},
+		{"zero packet out", (&PacketOut{}).AddAction(output)},
+		{"packet out without data length", &PacketOut{Header: ofp.Header{Length: packetOutSize}, Data: make([]byte, 64)}},
+		{"zero flow stats", (&FlowStats{}).AddAction(output)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buf := make([]byte, 256)
+			if _, err := tt.msg.Marshal(buf); err == nil {
+				t.Errorf("Marshal succeeded with length %d", tt.msg.Len())
+			}
+		})
+	}
+}
@@ -0,0 +1,113 @@
+package ofp10
+
+import (
+	"encoding/binary"
+	"errors"
+
+	"github.com/kuun/ofgo/ofp"
+)
+
+// Why was this flow removed?
+const (
+	OFPRR_IDLE_TIMEOUT = iota // Flow idle time exceeded idle_timeout.
+	OFPRR_HARD_TIMEOUT        // Time exceeded hard_timeout.
+	OFPRR_DELETE              // Evicted by a DELETE flow mod.
+)
+
+// flow removed binary size, in byte
+const flowRemovedSize = 88
+
+// FlowRemoved is the message telling a flow was removed, switch -> controller.
+type FlowRemoved struct {
+	ofp.Header
+	Match        Match  // Description of fields.
+	Cookie       uint64 // Opaque controller-issued identifier.
+	Priority     uint16 // Priority level of flow entry.
+	Reason       uint8  // One of OFPRR_*.
+	DurationSec  uint32 // Time flow was alive in seconds.
+	DurationNsec uint32 // Time flow was alive in nanoseconds beyond duration_sec.
+	IdleTimeout  uint16 // Idle timeout from original flow mod.
+	PacketCount  uint64
+	ByteCount    uint64
+}
+
+func NewFlowRemoved() *FlowRemoved {
+	return &FlowRemoved{
+		Header: ofp.Header{
+			Version: ofp.OFP10_VERSION,
+			Type:    OFPT_FLOW_REMOVED,
+			Length:  flowRemovedSize,
+		},
+	}
+}
+
+func (msg *FlowRemoved) Len() int {
+	return int(msg.Header.Length)
+}
+
+func (msg *FlowRemoved) Marshal(buf []byte) (n int, err error) {
+	if len(buf) < msg.Len() || msg.Len() < flowRemovedSize {
+		return 0, errors.New("buffer is too short")
+	}
+	if n, err = msg.Header.Marshal(buf); err != nil {
+		return n, err
+	}
+	var m int
+	if m, err = msg.Match.Marshal(buf[n:]); err != nil {
+		return n + m, err
+	}
+	n += msg.Match.Len()
+	binary.BigEndian.PutUint64(buf[n:], msg.Cookie)
+	n += 8
+	binary.BigEndian.PutUint16(buf[n:], msg.Priority)
+	n += 2
+	buf[n] = msg.Reason
+	n += 2 // plus one padding byte
+	binary.BigEndian.PutUint32(buf[n:], msg.DurationSec)
+	n += 4
+	binary.BigEndian.PutUint32(buf[n:], msg.DurationNsec)
+	n += 4
+	binary.BigEndian.PutUint16(buf[n:], msg.IdleTimeout)
+	n += 4 // plus 2 padding bytes
+	binary.BigEndian.PutUint64(buf[n:], msg.PacketCount)
+	n += 8
+	binary.BigEndian.PutUint64(buf[n:], msg.ByteCount)
+	n += 8
+	return msg.Len(), nil
+}
+
+func (msg *FlowRemoved) Unmarshal(buf []byte) (n int, err error) {
+	if n, err = msg.Header.Unmarshal(buf); err != nil {
+		return n, err
+	}
+	if len(buf) < msg.Len() || msg.Len() < flowRemovedSize {
+		return 0, errors.New("buffer is too short")
+	}
+	var m int
+	if m, err = msg.Match.Unmarshal(buf[n:]); err != nil {
+		return n + m, err
+	}
+	n += msg.Match.Len()
+	msg.Cookie = binary.BigEndian.Uint64(buf[n:])
+	n += 8
+	msg.Priority = binary.BigEndian.Uint16(buf[n:])
+	n += 2
+	msg.Reason = buf[n]
+	n += 2
+	msg.DurationSec = binary.BigEndian.Uint32(buf[n:])
+	n += 4
+	msg.DurationNsec = binary.BigEndian.Uint32(buf[n:])
+	n += 4
+	msg.IdleTimeout = binary.BigEndian.Uint16(buf[n:])
+	n += 4
+	msg.PacketCount = binary.BigEndian.Uint64(buf[n:])
+	n += 8
+	msg.ByteCount = binary.BigEndian.Uint64(buf[n:])
+	n += 8
+	return msg.Len(), nil
+}
+
+// AppendBinary appends the message's binary form to buf.
+func (msg *FlowRemoved) AppendBinary(buf []byte) ([]byte, error) {
+	return ofp.AppendBinary(buf, msg)
+}
@@ -7,6 +7,10 @@ import (
 
 const OFP_ETH_ALAN = 6
 
+// The VLAN id is 12-bits, so we can use the entire 16 bits to indicate
+// special conditions.
+const OFP_VLAN_NONE = 0xffff // No VLAN id was set.
+
 // Flow wildcards
 const (
 	OFPFW_IN_PORT      = 1 << iota //
@@ -126,6 +130,45 @@ func (self *Match)Unmarshal(buff []byte) (n int, err error) {
     self.TpSrc = binary.BigEndian.Uint16(buff[n:])
     n += 2
     self.TpDst = binary.BigEndian.Uint16(buff[n:])
+    n += 2
 
     return n, nil
-}
\ No newline at end of file
+}
+
+// NwSrcPrefixLen gets the CIDR prefix length matched on NwSrc, which is 32
+// minus the wildcard bit count, 0 if the whole field is wildcarded.
+func (self *Match)NwSrcPrefixLen() int {
+    return nwPrefixLen(self.Wildcards, OFPFW_NW_SRC_MASK, OFPFW_NW_SRC_SHIFT)
+}
+
+// SetNwSrcPrefixLen sets the CIDR prefix length matched on NwSrc.
+func (self *Match)SetNwSrcPrefixLen(prefixLen int) {
+    self.Wildcards = setNwPrefixLen(self.Wildcards, OFPFW_NW_SRC_MASK, OFPFW_NW_SRC_SHIFT, prefixLen)
+}
+
+// NwDstPrefixLen gets the CIDR prefix length matched on NwDst.
+func (self *Match)NwDstPrefixLen() int {
+    return nwPrefixLen(self.Wildcards, OFPFW_NW_DST_MASK, OFPFW_NW_DST_SHIFT)
+}
+
+// SetNwDstPrefixLen sets the CIDR prefix length matched on NwDst.
+func (self *Match)SetNwDstPrefixLen(prefixLen int) {
+    self.Wildcards = setNwPrefixLen(self.Wildcards, OFPFW_NW_DST_MASK, OFPFW_NW_DST_SHIFT, prefixLen)
+}
+
+func nwPrefixLen(wildcards, mask uint32, shift uint) int {
+    bits := int((wildcards & mask) >> shift)
+    if bits >= 32 {
+        return 0
+    }
+    return 32 - bits
+}
+
+func setNwPrefixLen(wildcards, mask uint32, shift uint, prefixLen int) uint32 {
+    if prefixLen < 0 {
+        prefixLen = 0
+    } else if prefixLen > 32 {
+        prefixLen = 32
+    }
+    return wildcards&^mask | uint32(32-prefixLen)<<shift
+}
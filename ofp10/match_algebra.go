@@ -0,0 +1,152 @@
+package ofp10
+
+// matchFields are the wildcard bits of the fields matched as a whole, the
+// network addresses are matched on a prefix.
+var matchFields = [...]uint32{
+	OFPFW_IN_PORT, OFPFW_DL_VLAN, OFPFW_DL_SRC, OFPFW_DL_DST, OFPFW_DL_TYPE,
+	OFPFW_NW_PROTO, OFPFW_TP_SRC, OFPFW_TP_DST, OFPFW_DL_VLAN_PCP, OFPFW_NW_TOS,
+}
+
+// The algebra of matches works field by field. It doesn't apply the
+// prerequisites of the fields, e.g. a match on NwProto without EthType is
+// taken as is.
+
+// Normalized gets the match with the wildcarded fields and the host bits of
+// the network addresses cleared, matches matching the same packets have the
+// same normalized match.
+func (self *Match) Normalized() Match {
+	n := Match{Wildcards: self.Wildcards & OFPFW_ALL}
+	n.copyFields(self, ^n.Wildcards)
+	srcLen, dstLen := self.NwSrcPrefixLen(), self.NwDstPrefixLen()
+	n.SetNwSrcPrefixLen(srcLen)
+	n.SetNwDstPrefixLen(dstLen)
+	n.NwSrc = self.NwSrc & prefixMask(srcLen)
+	n.NwDst = self.NwDst & prefixMask(dstLen)
+	return n
+}
+
+// copyFields copies the fields whose wildcard bits are set in 'fields'.
+func (self *Match) copyFields(from *Match, fields uint32) {
+	if fields&OFPFW_IN_PORT != 0 {
+		self.InPort = from.InPort
+	}
+	if fields&OFPFW_DL_VLAN != 0 {
+		self.VlanId = from.VlanId
+	}
+	if fields&OFPFW_DL_SRC != 0 {
+		self.EthSrc = from.EthSrc
+	}
+	if fields&OFPFW_DL_DST != 0 {
+		self.EthDst = from.EthDst
+	}
+	if fields&OFPFW_DL_TYPE != 0 {
+		self.EthType = from.EthType
+	}
+	if fields&OFPFW_NW_PROTO != 0 {
+		self.NwProto = from.NwProto
+	}
+	if fields&OFPFW_TP_SRC != 0 {
+		self.TpSrc = from.TpSrc
+	}
+	if fields&OFPFW_TP_DST != 0 {
+		self.TpDst = from.TpDst
+	}
+	if fields&OFPFW_DL_VLAN_PCP != 0 {
+		self.VlanPcp = from.VlanPcp
+	}
+	if fields&OFPFW_NW_TOS != 0 {
+		self.NwTos = from.NwTos
+	}
+}
+
+func prefixMask(prefixLen int) uint32 {
+	if prefixLen <= 0 {
+		return 0
+	}
+	return ^uint32(0) << uint(32-prefixLen)
+}
+
+// IsExact reports whether the match wildcards nothing, openflow 1.0 switches
+// give exact matches precedence over all the wildcarded flows.
+func (self *Match) IsExact() bool {
+	return self.Wildcards&OFPFW_ALL == 0
+}
+
+// Equal reports whether the matches match the same packets.
+func (self *Match) Equal(other *Match) bool {
+	return self.Normalized() == other.Normalized()
+}
+
+// SubsetOf reports whether every packet matched by the match is matched by
+// 'other'.
+func (self *Match) SubsetOf(other *Match) bool {
+	n, o := self.Normalized(), other.Normalized()
+	for _, field := range matchFields {
+		if o.Wildcards&field == 0 && n.Wildcards&field != 0 {
+			return false
+		}
+	}
+	if !sameFields(&n, &o, ^o.Wildcards) {
+		return false
+	}
+	return prefixSubset(n.NwSrcPrefixLen(), o.NwSrcPrefixLen(), n.NwSrc, o.NwSrc) &&
+		prefixSubset(n.NwDstPrefixLen(), o.NwDstPrefixLen(), n.NwDst, o.NwDst)
+}
+
+// sameFields reports whether the matches have the same values in the fields
+// whose wildcard bits are set in 'fields'.
+func sameFields(a, b *Match, fields uint32) bool {
+	var x, y Match
+	x.copyFields(a, fields)
+	y.copyFields(b, fields)
+	return x == y
+}
+
+// prefixSubset reports whether a network prefix is contained in another one.
+func prefixSubset(prefixLen, otherLen int, addr, other uint32) bool {
+	return otherLen <= prefixLen && addr&prefixMask(otherLen) == other
+}
+
+// Intersect gets the match of the packets matched by both matches, ok is
+// false if no packet is matched by both.
+func (self *Match) Intersect(other *Match) (m Match, ok bool) {
+	a, b := self.Normalized(), other.Normalized()
+	if !sameFields(&a, &b, ^a.Wildcards&^b.Wildcards) {
+		return Match{}, false
+	}
+	src, srcLen, ok := prefixIntersect(a.NwSrc, a.NwSrcPrefixLen(), b.NwSrc, b.NwSrcPrefixLen())
+	if !ok {
+		return Match{}, false
+	}
+	dst, dstLen, ok := prefixIntersect(a.NwDst, a.NwDstPrefixLen(), b.NwDst, b.NwDstPrefixLen())
+	if !ok {
+		return Match{}, false
+	}
+	// A field is wildcarded if it's wildcarded in both matches, else it
+	// takes the value of the match where it's exact.
+	m.Wildcards = a.Wildcards & b.Wildcards &^ (OFPFW_NW_SRC_MASK | OFPFW_NW_DST_MASK)
+	m.copyFields(&a, ^a.Wildcards)
+	m.copyFields(&b, ^b.Wildcards)
+	m.NwSrc, m.NwDst = src, dst
+	m.SetNwSrcPrefixLen(srcLen)
+	m.SetNwDstPrefixLen(dstLen)
+	return m, true
+}
+
+// prefixIntersect gets the intersection of two network prefixes, which is
+// the longest one if they don't differ on the bits of the shortest one.
+func prefixIntersect(a uint32, aLen int, b uint32, bLen int) (uint32, int, bool) {
+	if aLen < bLen {
+		a, aLen, b, bLen = b, bLen, a, aLen
+	}
+	if a&prefixMask(bLen) != b {
+		return 0, 0, false
+	}
+	return a, aLen, true
+}
+
+// Overlaps reports whether some packets are matched by both matches.
+func (self *Match) Overlaps(other *Match) bool {
+	_, ok := self.Intersect(other)
+	return ok
+}
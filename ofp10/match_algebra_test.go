@@ -0,0 +1,106 @@
+package ofp10
+
+import (
+	"encoding/binary"
+	"net"
+	"testing"
+)
+
+// testMatch gets a match on an input port and network prefixes, a zero port
+// or an empty prefix is wildcarded.
+func testMatch(inPort uint16, src, dst string) Match {
+	m := Match{Wildcards: OFPFW_ALL}
+	if inPort != 0 {
+		m.Wildcards &^= OFPFW_IN_PORT
+		m.InPort = inPort
+	}
+	prefix := func(cidr string) (uint32, int) {
+		ip, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+		ones, _ := network.Mask.Size()
+		return binary.BigEndian.Uint32(ip.To4()), ones
+	}
+	if src != "" {
+		addr, prefixLen := prefix(src)
+		m.NwSrc = addr
+		m.SetNwSrcPrefixLen(prefixLen)
+	}
+	if dst != "" {
+		addr, prefixLen := prefix(dst)
+		m.NwDst = addr
+		m.SetNwDstPrefixLen(prefixLen)
+	}
+	return m
+}
+
+func TestMatchAlgebra(t *testing.T) {
+	tests := []struct {
+		name     string
+		a, b     Match
+		subset   bool // a is a subset of b.
+		overlaps bool
+		equal    bool
+	}{
+		{"all", testMatch(0, "", ""), testMatch(0, "", ""), true, true, true},
+		{"port in all", testMatch(1, "", ""), testMatch(0, "", ""), true, true, false},
+		{"all in port", testMatch(0, "", ""), testMatch(1, "", ""), false, true, false},
+		{"other port", testMatch(1, "", ""), testMatch(2, "", ""), false, false, false},
+		{"host bits", testMatch(0, "10.0.0.1/8", ""), testMatch(0, "10.0.0.0/8", ""), true, true, true},
+		{"longer src prefix", testMatch(0, "10.1.0.0/16", ""), testMatch(0, "10.0.0.0/8", ""), true, true, false},
+		{"shorter src prefix", testMatch(0, "10.0.0.0/8", ""), testMatch(0, "10.1.0.0/16", ""), false, true, false},
+		{"disjoint src prefixes", testMatch(0, "10.1.0.0/16", ""), testMatch(0, "10.2.0.0/16", ""), false, false, false},
+		{"src prefix and dst prefix", testMatch(0, "10.0.0.0/8", ""), testMatch(0, "", "192.168.0.0/16"), false, true, false},
+		{"dst host in prefix", testMatch(0, "", "192.168.1.1/32"), testMatch(0, "", "192.168.0.0/16"), true, true, false},
+		{"disjoint dst prefixes", testMatch(0, "", "192.168.1.0/24"), testMatch(0, "", "192.168.2.0/24"), false, false, false},
+		{"port and prefix", testMatch(1, "10.0.0.0/8", ""), testMatch(0, "10.0.0.0/8", ""), true, true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.a.SubsetOf(&tt.b); got != tt.subset {
+				t.Errorf("SubsetOf = %v, want %v", got, tt.subset)
+			}
+			if got := tt.a.Overlaps(&tt.b); got != tt.overlaps {
+				t.Errorf("Overlaps = %v, want %v", got, tt.overlaps)
+			}
+			if got := tt.b.Overlaps(&tt.a); got != tt.overlaps {
+				t.Errorf("reversed Overlaps = %v, want %v", got, tt.overlaps)
+			}
+			if got := tt.a.Equal(&tt.b); got != tt.equal {
+				t.Errorf("Equal = %v, want %v", got, tt.equal)
+			}
+		})
+	}
+}
+
+func TestMatchIntersect(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b Match
+		want Match
+		ok   bool
+	}{
+		{"all", testMatch(0, "", ""), testMatch(0, "", ""), testMatch(0, "", ""), true},
+		{"port and all", testMatch(1, "", ""), testMatch(0, "", ""), testMatch(1, "", ""), true},
+		{"other ports", testMatch(1, "", ""), testMatch(2, "", ""), Match{}, false},
+		{"nested src prefixes", testMatch(0, "10.0.0.0/8", ""), testMatch(0, "10.1.0.0/16", ""), testMatch(0, "10.1.0.0/16", ""), true},
+		{"disjoint src prefixes", testMatch(0, "10.1.0.0/16", ""), testMatch(0, "10.2.0.0/16", ""), Match{}, false},
+		{"src and dst prefixes", testMatch(1, "10.0.0.0/8", ""), testMatch(0, "", "192.168.0.0/16"), testMatch(1, "10.0.0.0/8", "192.168.0.0/16"), true},
+		{"host bits", testMatch(0, "", "192.168.1.7/24"), testMatch(0, "", "192.168.1.9/32"), testMatch(0, "", "192.168.1.9/32"), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := tt.a.Intersect(&tt.b)
+			if ok != tt.ok {
+				t.Fatalf("Intersect ok = %v, want %v", ok, tt.ok)
+			}
+			if ok && !got.Equal(&tt.want) {
+				t.Errorf("Intersect = %+v, want %+v", got, tt.want)
+			}
+			if reversed, _ := tt.b.Intersect(&tt.a); ok && reversed != got {
+				t.Errorf("reversed Intersect = %+v, want %+v", reversed, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,90 @@
+package ofp10
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+// Ethernet types and IP protocols understood by MatchFromPacket.
+const (
+	ETH_TYPE_IPV4 = 0x0800
+	ETH_TYPE_ARP  = 0x0806
+	ETH_TYPE_VLAN = 0x8100
+	ETH_TYPE_LLDP = 0x88cc
+
+	IP_PROTO_ICMP = 1
+	IP_PROTO_TCP  = 6
+	IP_PROTO_UDP  = 17
+)
+
+// MatchFromPacket builds the exact match of an ethernet frame received on a
+// port, the fields which don't exist in the frame are wildcarded. Truncated
+// upper layer headers are wildcarded too, only a frame shorter than the
+// ethernet header is an error.
+func MatchFromPacket(data []byte, inPort uint16) (Match, error) {
+	match := Match{Wildcards: OFPFW_ALL, InPort: inPort}
+	if len(data) < 14 {
+		return match, errors.New("ethernet frame is too short")
+	}
+	match.Wildcards &^= OFPFW_IN_PORT | OFPFW_DL_DST | OFPFW_DL_SRC | OFPFW_DL_TYPE | OFPFW_DL_VLAN
+	copy(match.EthDst[:], data[0:6])
+	copy(match.EthSrc[:], data[6:12])
+	match.EthType = binary.BigEndian.Uint16(data[12:])
+	match.VlanId = OFP_VLAN_NONE
+	payload := data[14:]
+	if match.EthType == ETH_TYPE_VLAN {
+		if len(payload) < 4 {
+			return match, nil
+		}
+		tci := binary.BigEndian.Uint16(payload)
+		match.VlanId = tci & 0x0fff
+		match.VlanPcp = uint8(tci >> 13)
+		match.Wildcards &^= OFPFW_DL_VLAN_PCP
+		match.EthType = binary.BigEndian.Uint16(payload[2:])
+		payload = payload[4:]
+	}
+
+	switch match.EthType {
+	case ETH_TYPE_ARP:
+		// Hardware type, protocol type, lengths, opcode, then the sender and
+		// target addresses of ethernet/IPv4 ARP.
+		if len(payload) < 28 {
+			return match, nil
+		}
+		match.NwProto = uint8(binary.BigEndian.Uint16(payload[6:]))
+		match.NwSrc = binary.BigEndian.Uint32(payload[14:])
+		match.NwDst = binary.BigEndian.Uint32(payload[24:])
+		match.Wildcards &^= OFPFW_NW_PROTO
+		match.SetNwSrcPrefixLen(32)
+		match.SetNwDstPrefixLen(32)
+	case ETH_TYPE_IPV4:
+		if len(payload) < 20 {
+			return match, nil
+		}
+		headerLen := int(payload[0]&0x0f) * 4
+		match.NwTos = payload[1] & 0xfc
+		match.NwProto = payload[9]
+		match.NwSrc = binary.BigEndian.Uint32(payload[12:])
+		match.NwDst = binary.BigEndian.Uint32(payload[16:])
+		match.Wildcards &^= OFPFW_NW_TOS | OFPFW_NW_PROTO
+		match.SetNwSrcPrefixLen(32)
+		match.SetNwDstPrefixLen(32)
+		fragment := binary.BigEndian.Uint16(payload[6:]) & 0x1fff
+		if headerLen < 20 || len(payload) < headerLen+4 || fragment != 0 {
+			return match, nil
+		}
+		transport := payload[headerLen:]
+		switch match.NwProto {
+		case IP_PROTO_TCP, IP_PROTO_UDP:
+			match.TpSrc = binary.BigEndian.Uint16(transport)
+			match.TpDst = binary.BigEndian.Uint16(transport[2:])
+			match.Wildcards &^= OFPFW_TP_SRC | OFPFW_TP_DST
+		case IP_PROTO_ICMP:
+			// ICMP type and code are matched as the transport ports.
+			match.TpSrc = uint16(transport[0])
+			match.TpDst = uint16(transport[1])
+			match.Wildcards &^= OFPFW_TP_SRC | OFPFW_TP_DST
+		}
+	}
+	return match, nil
+}
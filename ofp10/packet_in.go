@@ -0,0 +1,80 @@
+package ofp10
+
+import (
+	"encoding/binary"
+	"errors"
+
+	"github.com/kuun/ofgo/ofp"
+)
+
+// Why is this packet being sent to the controller?
+const (
+	OFPR_NO_MATCH = iota // No matching flow.
+	OFPR_ACTION          // Action explicitly output to controller.
+)
+
+// packet in binary size without data, in byte
+const packetInSize = 18
+
+// PacketIn is the message carrying a packet received by the datapath,
+// switch -> controller.
+type PacketIn struct {
+	ofp.Header
+	BufferId uint32 // ID assigned by datapath.
+	TotalLen uint16 // Full length of frame.
+	InPort   uint16 // Port on which frame was received.
+	Reason   uint8  // Reason packet is being sent (one of OFPR_*).
+	pad      byte
+	// Ethernet frame, halfway through 32-bit word, so the IP header is 32-bit
+	// aligned. The amount of data is inferred from the length field in the
+	// header. Because of padding, offsetof(struct ofp_packet_in, data) ==
+	// sizeof(struct ofp_packet_in) - 2.
+	Data []byte
+}
+
+func (msg *PacketIn) Len() int {
+	return int(msg.Header.Length)
+}
+
+func (msg *PacketIn) Marshal(buf []byte) (n int, err error) {
+	if len(buf) < msg.Len() {
+		return 0, errors.New("buffer is too short")
+	}
+	if n, err = msg.Header.Marshal(buf); err != nil {
+		return n, err
+	}
+	binary.BigEndian.PutUint32(buf[n:], msg.BufferId)
+	n += 4
+	binary.BigEndian.PutUint16(buf[n:], msg.TotalLen)
+	n += 2
+	binary.BigEndian.PutUint16(buf[n:], msg.InPort)
+	n += 2
+	buf[n] = msg.Reason
+	n += 2 // plus one padding byte
+	copy(buf[n:msg.Len()], msg.Data)
+	return msg.Len(), nil
+}
+
+func (msg *PacketIn) Unmarshal(buf []byte) (n int, err error) {
+	if n, err = msg.Header.Unmarshal(buf); err != nil {
+		return n, err
+	}
+	if len(buf) < msg.Len() || msg.Len() < packetInSize {
+		return 0, errors.New("buffer is too short")
+	}
+	msg.BufferId = binary.BigEndian.Uint32(buf[n:])
+	n += 4
+	msg.TotalLen = binary.BigEndian.Uint16(buf[n:])
+	n += 2
+	msg.InPort = binary.BigEndian.Uint16(buf[n:])
+	n += 2
+	msg.Reason = buf[n]
+	n += 2
+	msg.Data = append([]byte(nil), buf[n:msg.Len()]...)
+	return msg.Len(), nil
+}
+
+// AppendBinary appends the message's binary form to buf.
+func (msg *PacketIn) AppendBinary(buf []byte) ([]byte, error) {
+	return ofp.AppendBinary(buf, msg)
+}
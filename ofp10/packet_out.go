@@ -0,0 +1,109 @@
+package ofp10
+
+import (
+	"encoding/binary"
+	"errors"
+
+	"github.com/kuun/ofgo/ofp"
+)
+
+// packet out binary size without actions and data, in byte
+const packetOutSize = 16
+
+// PacketOut is the message sending a packet out of the datapath,
+// controller -> switch.
+type PacketOut struct {
+	ofp.Header
+	BufferId uint32   // ID assigned by datapath (OFP_NO_BUFFER if none).
+	InPort   uint16   // Packet's input port (OFPP_NONE if none).
+	Actions  []Action // Actions to apply to the packet.
+	// Packet data, only meaningful if BufferId is OFP_NO_BUFFER. The length
+	// is inferred from the length field in the header.
+	Data []byte
+}
+
+func NewPacketOut() *PacketOut {
+	return &PacketOut{
+		Header: ofp.Header{
+			Version: ofp.OFP10_VERSION,
+			Type:    OFPT_PACKET_OUT,
+			Length:  packetOutSize,
+		},
+		BufferId: OFP_NO_BUFFER,
+		InPort:   OFPP_NONE,
+	}
+}
+
+// AddAction appends an action to the message and updates the message length.
+func (msg *PacketOut) AddAction(action Action) *PacketOut {
+	msg.Actions = append(msg.Actions, action)
+	msg.Header.Length += uint16(action.Len())
+	return msg
+}
+
+// SetData sets the packet data and updates the message length, the message
+// will own the 'data'.
+func (msg *PacketOut) SetData(data []byte) *PacketOut {
+	msg.Header.Length -= uint16(len(msg.Data))
+	msg.Data = data
+	msg.Header.Length += uint16(len(data))
+	return msg
+}
+
+func (msg *PacketOut) Len() int {
+	return int(msg.Header.Length)
+}
+
+func (msg *PacketOut) Marshal(buf []byte) (n int, err error) {
+	if msg.Len() != packetOutSize+ActionsLen(msg.Actions)+len(msg.Data) {
+		return 0, errors.New("bad packet out length")
+	}
+	if len(buf) < msg.Len() {
+		return 0, errors.New("buffer is too short")
+	}
+	if n, err = msg.Header.Marshal(buf); err != nil {
+		return n, err
+	}
+	binary.BigEndian.PutUint32(buf[n:], msg.BufferId)
+	n += 4
+	binary.BigEndian.PutUint16(buf[n:], msg.InPort)
+	n += 2
+	binary.BigEndian.PutUint16(buf[n:], uint16(ActionsLen(msg.Actions)))
+	n += 2
+	var m int
+	if m, err = MarshalActions(buf[n:msg.Len()], msg.Actions); err != nil {
+		return n + m, err
+	}
+	n += m
+	copy(buf[n:msg.Len()], msg.Data)
+	return msg.Len(), nil
+}
+
+func (msg *PacketOut) Unmarshal(buf []byte) (n int, err error) {
+	if n, err = msg.Header.Unmarshal(buf); err != nil {
+		return n, err
+	}
+	if len(buf) < msg.Len() || msg.Len() < packetOutSize {
+		return 0, errors.New("buffer is too short")
+	}
+	msg.BufferId = binary.BigEndian.Uint32(buf[n:])
+	n += 4
+	msg.InPort = binary.BigEndian.Uint16(buf[n:])
+	n += 2
+	actionsLen := int(binary.BigEndian.Uint16(buf[n:]))
+	n += 2
+	if n+actionsLen > msg.Len() {
+		return n, errors.New("bad actions length")
+	}
+	if msg.Actions, err = UnmarshalActions(buf[n : n+actionsLen]); err != nil {
+		return n, err
+	}
+	n += actionsLen
+	msg.Data = append([]byte(nil), buf[n:msg.Len()]...)
+	return msg.Len(), nil
+}
+
+// AppendBinary appends the message's binary form to buf.
+func (msg *PacketOut) AppendBinary(buf []byte) ([]byte, error) {
+	return ofp.AppendBinary(buf, msg)
+}
@@ -0,0 +1,79 @@
+package ofp10
+
+import (
+	"errors"
+
+	"github.com/kuun/ofgo/ofp"
+)
+
+// What changed about the physical port.
+const (
+	OFPPR_ADD    = iota // The port was added.
+	OFPPR_DELETE        // The port was removed.
+	OFPPR_MODIFY        // Some attribute of the port has changed.
+)
+
+// port status binary size, in byte
+const portStatusSize = 64
+
+// PortStatus is the message telling a physical port was added, removed or
+// modified, switch -> controller.
+type PortStatus struct {
+	ofp.Header
+	Reason uint8   // One of OFPPR_*.
+	pad    [7]byte // Align to 64-bits.
+	Desc   Port
+}
+
+func NewPortStatus() *PortStatus {
+	return &PortStatus{
+		Header: ofp.Header{
+			Version: ofp.OFP10_VERSION,
+			Type:    OFPT_PORT_STATUS,
+			Length:  portStatusSize,
+		},
+	}
+}
+
+func (msg *PortStatus) Len() int {
+	return int(msg.Header.Length)
+}
+
+func (msg *PortStatus) Marshal(buf []byte) (n int, err error) {
+	if len(buf) < msg.Len() || msg.Len() < portStatusSize {
+		return 0, errors.New("buffer is too short")
+	}
+	if n, err = msg.Header.Marshal(buf); err != nil {
+		return n, err
+	}
+	buf[n] = msg.Reason
+	n++
+	copy(buf[n:], msg.pad[:])
+	n += 7
+	var m int
+	if m, err = msg.Desc.Marshal(buf[n:]); err != nil {
+		return n + m, err
+	}
+	return msg.Len(), nil
+}
+
+func (msg *PortStatus) Unmarshal(buf []byte) (n int, err error) {
+	if n, err = msg.Header.Unmarshal(buf); err != nil {
+		return n, err
+	}
+	if len(buf) < msg.Len() || msg.Len() < portStatusSize {
+		return 0, errors.New("buffer is too short")
+	}
+	msg.Reason = buf[n]
+	n += 8
+	var m int
+	if m, err = msg.Desc.Unmarshal(buf[n:]); err != nil {
+		return n + m, err
+	}
+	return msg.Len(), nil
+}
+
+// AppendBinary appends the message's binary form to buf.
+func (msg *PortStatus) AppendBinary(buf []byte) ([]byte, error) {
+	return ofp.AppendBinary(buf, msg)
+}
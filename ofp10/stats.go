@@ -0,0 +1,327 @@
+package ofp10
+
+import (
+	"encoding/binary"
+	"errors"
+
+	"github.com/kuun/ofgo/ofp"
+)
+
+// Statistics types.
+const (
+	OFPST_DESC      = iota // Description of this OpenFlow switch.
+	OFPST_FLOW             // Individual flow statistics.
+	OFPST_AGGREGATE        // Aggregate flow statistics.
+	OFPST_TABLE            // Flow table statistics.
+	OFPST_PORT             // Physical port statistics.
+	OFPST_QUEUE            // Queue statistics for a port.
+	OFPST_VENDOR    = 0xffff
+)
+
+// Statistics reply flags.
+const (
+	OFPSF_REPLY_MORE = 1 << 0 // More replies to follow.
+)
+
+// stats message binary size without body, in byte
+const statsSize = 12
+
+// StatsRequest is a statistics request, controller -> switch. The body
+// depends on the statistics type, it's kept as binary.
+type StatsRequest struct {
+	ofp.Header
+	StatsType uint16 // One of the OFPST_* constants.
+	Flags     uint16 // No flags defined for requests.
+	Body      []byte // Body of the request.
+}
+
+// NewStatsRequest creates a statistics request of the type, the body is
+// marshaled from 'body' if it's not nil.
+func NewStatsRequest(statsType uint16, body ofp.DataBlock) (*StatsRequest, error) {
+	msg := &StatsRequest{
+		Header: ofp.Header{
+			Version: ofp.OFP10_VERSION,
+			Type:    OFPT_STATS_REQUEST,
+			Length:  statsSize,
+		},
+		StatsType: statsType,
+	}
+	if body != nil {
+		msg.Body = make([]byte, body.Len())
+		if _, err := body.Marshal(msg.Body); err != nil {
+			return nil, err
+		}
+		msg.Header.Length += uint16(len(msg.Body))
+	}
+	return msg, nil
+}
+
+// NewFlowStatsRequest creates a request of the statistics of the flows
+// matching 'match' in all tables, with any output port.
+func NewFlowStatsRequest(match Match) *StatsRequest {
+	msg, _ := NewStatsRequest(OFPST_FLOW, &FlowStatsRequestBody{
+		Match:   match,
+		TableId: OFPTT_ALL,
+		OutPort: OFPP_NONE,
+	})
+	return msg
+}
+
+func (msg *StatsRequest) Len() int {
+	return int(msg.Header.Length)
+}
+
+func (msg *StatsRequest) Marshal(buf []byte) (n int, err error) {
+	return marshalStats(buf, &msg.Header, msg.StatsType, msg.Flags, msg.Body)
+}
+
+func (msg *StatsRequest) Unmarshal(buf []byte) (n int, err error) {
+	return unmarshalStats(buf, &msg.Header, &msg.StatsType, &msg.Flags, &msg.Body)
+}
+
+// AppendBinary appends the message's binary form to buf.
+func (msg *StatsRequest) AppendBinary(buf []byte) ([]byte, error) {
+	return ofp.AppendBinary(buf, msg)
+}
+
+// StatsReply is a statistics reply, switch -> controller. A reply can be
+// split in several messages, all but the last one have OFPSF_REPLY_MORE set.
+type StatsReply struct {
+	ofp.Header
+	StatsType uint16 // One of the OFPST_* constants.
+	Flags     uint16 // OFPSF_REPLY_* flags.
+	Body      []byte // Body of the reply.
+}
+
+func (msg *StatsReply) Len() int {
+	return int(msg.Header.Length)
+}
+
+func (msg *StatsReply) Marshal(buf []byte) (n int, err error) {
+	return marshalStats(buf, &msg.Header, msg.StatsType, msg.Flags, msg.Body)
+}
+
+func (msg *StatsReply) Unmarshal(buf []byte) (n int, err error) {
+	return unmarshalStats(buf, &msg.Header, &msg.StatsType, &msg.Flags, &msg.Body)
+}
+
+// AppendBinary appends the message's binary form to buf.
+func (msg *StatsReply) AppendBinary(buf []byte) ([]byte, error) {
+	return ofp.AppendBinary(buf, msg)
+}
+
+// More reports whether more parts of the reply follow.
+func (msg *StatsReply) More() bool {
+	return msg.Flags&OFPSF_REPLY_MORE != 0
+}
+
+// FlowStats parses the body of an OFPST_FLOW reply.
+func (msg *StatsReply) FlowStats() ([]FlowStats, error) {
+	if msg.StatsType != OFPST_FLOW {
+		return nil, errors.New("not a flow statistics reply")
+	}
+	var stats []FlowStats
+	for n := 0; n < len(msg.Body); {
+		entry := FlowStats{}
+		m, err := entry.Unmarshal(msg.Body[n:])
+		if err != nil {
+			return nil, err
+		}
+		stats = append(stats, entry)
+		n += m
+	}
+	return stats, nil
+}
+
+func marshalStats(buf []byte, header *ofp.Header, statsType, flags uint16, body []byte) (n int, err error) {
+	length := int(header.Length)
+	if len(buf) < length || length < statsSize {
+		return 0, errors.New("buffer is too short")
+	}
+	if n, err = header.Marshal(buf); err != nil {
+		return n, err
+	}
+	binary.BigEndian.PutUint16(buf[n:], statsType)
+	n += 2
+	binary.BigEndian.PutUint16(buf[n:], flags)
+	n += 2
+	copy(buf[n:length], body)
+	return length, nil
+}
+
+func unmarshalStats(buf []byte, header *ofp.Header, statsType, flags *uint16, body *[]byte) (n int, err error) {
+	if n, err = header.Unmarshal(buf); err != nil {
+		return n, err
+	}
+	length := int(header.Length)
+	if len(buf) < length || length < statsSize {
+		return 0, errors.New("buffer is too short")
+	}
+	*statsType = binary.BigEndian.Uint16(buf[n:])
+	n += 2
+	*flags = binary.BigEndian.Uint16(buf[n:])
+	n += 2
+	*body = append([]byte(nil), buf[n:length]...)
+	return length, nil
+}
+
+// Table numbers.
+const (
+	OFPTT_EMERG = 0xfe // Emergency flow table.
+	OFPTT_ALL   = 0xff // All flow tables.
+)
+
+// flow stats request body binary size, in byte
+const flowStatsRequestSize = 44
+
+// FlowStatsRequestBody is the body of an OFPST_FLOW or OFPST_AGGREGATE
+// request.
+type FlowStatsRequestBody struct {
+	Match   Match  // Fields to match.
+	TableId uint8  // ID of table to read (from ofp_table_stats), OFPTT_ALL for all tables or OFPTT_EMERG for the emergency table.
+	OutPort uint16 // Require matching entries to include this as an output port. A value of OFPP_NONE indicates no restriction.
+}
+
+func (body *FlowStatsRequestBody) Len() int {
+	return flowStatsRequestSize
+}
+
+func (body *FlowStatsRequestBody) Marshal(buf []byte) (n int, err error) {
+	if len(buf) < body.Len() {
+		return 0, ofp.NewNoBuffError()
+	}
+	if n, err = body.Match.Marshal(buf); err != nil {
+		return n, err
+	}
+	n = body.Match.Len()
+	buf[n] = body.TableId
+	n += 2 // plus one padding byte
+	binary.BigEndian.PutUint16(buf[n:], body.OutPort)
+	n += 2
+	return n, nil
+}
+
+func (body *FlowStatsRequestBody) Unmarshal(buf []byte) (n int, err error) {
+	if len(buf) < body.Len() {
+		return 0, ofp.NewNoBuffError()
+	}
+	if n, err = body.Match.Unmarshal(buf); err != nil {
+		return n, err
+	}
+	n = body.Match.Len()
+	body.TableId = buf[n]
+	n += 2
+	body.OutPort = binary.BigEndian.Uint16(buf[n:])
+	n += 2
+	return n, nil
+}
+
+// flow stats binary size without actions, in byte
+const flowStatsSize = 88
+
+// FlowStats is an entry of an OFPST_FLOW reply body.
+type FlowStats struct {
+	Length       uint16 // Length of this entry.
+	TableId      uint8  // ID of table flow came from.
+	Match        Match  // Description of fields.
+	DurationSec  uint32 // Time flow has been alive in seconds.
+	DurationNsec uint32 // Time flow has been alive in nanoseconds beyond duration_sec.
+	Priority     uint16 // Priority of the entry. Only meaningful when this is not an exact-match entry.
+	IdleTimeout  uint16 // Number of seconds idle before expiration.
+	HardTimeout  uint16 // Number of seconds before expiration.
+	Cookie       uint64 // Opaque controller-issued identifier.
+	PacketCount  uint64 // Number of packets in flow.
+	ByteCount    uint64 // Number of bytes in flow.
+	Actions      []Action
+}
+
+func (stats *FlowStats) Len() int {
+	return int(stats.Length)
+}
+
+func (stats *FlowStats) Marshal(buf []byte) (n int, err error) {
+	if stats.Len() != flowStatsSize+ActionsLen(stats.Actions) {
+		return 0, errors.New("bad flow stats length")
+	}
+	if len(buf) < stats.Len() {
+		return 0, ofp.NewNoBuffError()
+	}
+	binary.BigEndian.PutUint16(buf, stats.Length)
+	n += 2
+	buf[n] = stats.TableId
+	n += 2 // plus one padding byte
+	var m int
+	if m, err = stats.Match.Marshal(buf[n:]); err != nil {
+		return n + m, err
+	}
+	n += stats.Match.Len()
+	binary.BigEndian.PutUint32(buf[n:], stats.DurationSec)
+	n += 4
+	binary.BigEndian.PutUint32(buf[n:], stats.DurationNsec)
+	n += 4
+	binary.BigEndian.PutUint16(buf[n:], stats.Priority)
+	n += 2
+	binary.BigEndian.PutUint16(buf[n:], stats.IdleTimeout)
+	n += 2
+	binary.BigEndian.PutUint16(buf[n:], stats.HardTimeout)
+	n += 8 // plus 6 padding bytes
+	binary.BigEndian.PutUint64(buf[n:], stats.Cookie)
+	n += 8
+	binary.BigEndian.PutUint64(buf[n:], stats.PacketCount)
+	n += 8
+	binary.BigEndian.PutUint64(buf[n:], stats.ByteCount)
+	n += 8
+	if m, err = MarshalActions(buf[n:stats.Len()], stats.Actions); err != nil {
+		return n + m, err
+	}
+	return stats.Len(), nil
+}
+
+func (stats *FlowStats) Unmarshal(buf []byte) (n int, err error) {
+	if len(buf) < flowStatsSize {
+		return 0, ofp.NewNoBuffError()
+	}
+	stats.Length = binary.BigEndian.Uint16(buf)
+	if stats.Len() < flowStatsSize || len(buf) < stats.Len() {
+		return 0, errors.New("bad flow stats length")
+	}
+	n += 2
+	stats.TableId = buf[n]
+	n += 2
+	var m int
+	if m, err = stats.Match.Unmarshal(buf[n:]); err != nil {
+		return n + m, err
+	}
+	n += stats.Match.Len()
+	stats.DurationSec = binary.BigEndian.Uint32(buf[n:])
+	n += 4
+	stats.DurationNsec = binary.BigEndian.Uint32(buf[n:])
+	n += 4
+	stats.Priority = binary.BigEndian.Uint16(buf[n:])
+	n += 2
+	stats.IdleTimeout = binary.BigEndian.Uint16(buf[n:])
+	n += 2
+	stats.HardTimeout = binary.BigEndian.Uint16(buf[n:])
+	n += 8
+	stats.Cookie = binary.BigEndian.Uint64(buf[n:])
+	n += 8
+	stats.PacketCount = binary.BigEndian.Uint64(buf[n:])
+	n += 8
+	stats.ByteCount = binary.BigEndian.Uint64(buf[n:])
+	n += 8
+	if stats.Actions, err = UnmarshalActions(buf[n:stats.Len()]); err != nil {
+		return n, err
+	}
+	return stats.Len(), nil
+}
+
+func NewFlowStats() *FlowStats {
+	return &FlowStats{Length: flowStatsSize}
+}
+
+// AddAction appends an action to the entry and updates the entry length.
+func (stats *FlowStats) AddAction(action Action) *FlowStats {
+	stats.Actions = append(stats.Actions, action)
+	stats.Length += uint16(action.Len())
+	return stats
+}
@@ -63,3 +63,25 @@ func NewEchoRequest() *ofp.EchoRequest {
 	}
 }
 
+
+// NewFeaturesRequest creates a features request, the message has no body.
+func NewFeaturesRequest() *ofp.RawMessage {
+	return &ofp.RawMessage{
+		Header: ofp.Header{
+			Version: ofp.OFP10_VERSION,
+			Type:    OFPT_FEATURES_REQUEST,
+			Length:  ofp.HeaderLength,
+		},
+	}
+}
+
+// NewBarrierRequest creates a barrier request, the message has no body.
+func NewBarrierRequest() *ofp.RawMessage {
+	return &ofp.RawMessage{
+		Header: ofp.Header{
+			Version: ofp.OFP10_VERSION,
+			Type:    OFPT_BARRIER_REQUEST,
+			Length:  ofp.HeaderLength,
+		},
+	}
+}
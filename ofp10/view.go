@@ -0,0 +1,201 @@
+package ofp10
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+
+	"github.com/kuun/ofgo/ofp"
+)
+
+// The views below are read-only views over received bytes, fields are read
+// from the bytes when accessed instead of being copied into a struct. The
+// bounds are checked once when a view is created, a view must not be used
+// after its bytes are reused.
+
+// PacketInView is a view of a packet in message.
+type PacketInView []byte
+
+// NewPacketInView creates the view of a whole packet in message.
+func NewPacketInView(buf []byte) (PacketInView, error) {
+	header, err := ofp.NewHeaderView(buf)
+	if err != nil {
+		return nil, err
+	}
+	length := int(header.Length())
+	if length < packetInSize || len(buf) < length {
+		return nil, errors.New("buffer is too short")
+	}
+	if header.Type() != OFPT_PACKET_IN {
+		return nil, errors.New("not a packet in message")
+	}
+	return PacketInView(buf[:length]), nil
+}
+
+func (v PacketInView) Header() ofp.HeaderView {
+	return ofp.HeaderView(v[:ofp.HeaderLength])
+}
+
+func (v PacketInView) BufferId() uint32 {
+	return binary.BigEndian.Uint32(v[8:])
+}
+
+func (v PacketInView) TotalLen() uint16 {
+	return binary.BigEndian.Uint16(v[12:])
+}
+
+func (v PacketInView) InPort() uint16 {
+	return binary.BigEndian.Uint16(v[14:])
+}
+
+func (v PacketInView) Reason() uint8 {
+	return v[16]
+}
+
+// Data gets the ethernet frame, it aliases the viewed bytes.
+func (v PacketInView) Data() []byte {
+	return v[packetInSize:]
+}
+
+// PacketIn copies the viewed message into an owned PacketIn.
+func (v PacketInView) PacketIn() *PacketIn {
+	msg := &PacketIn{}
+	msg.Unmarshal(v)
+	return msg
+}
+
+// matchSize is the match binary size, in byte.
+const matchSize = 40
+
+// MatchView is a view of a match.
+type MatchView []byte
+
+// NewMatchView creates the view of the match at the beginning of 'buf'.
+func NewMatchView(buf []byte) (MatchView, error) {
+	if len(buf) < matchSize {
+		return nil, ofp.NewNoBuffError()
+	}
+	return MatchView(buf[:matchSize]), nil
+}
+
+func (v MatchView) Wildcards() uint32 {
+	return binary.BigEndian.Uint32(v)
+}
+
+func (v MatchView) InPort() uint16 {
+	return binary.BigEndian.Uint16(v[4:])
+}
+
+func (v MatchView) EthSrc() (addr [OFP_ETH_ALAN]byte) {
+	copy(addr[:], v[6:])
+	return addr
+}
+
+func (v MatchView) EthDst() (addr [OFP_ETH_ALAN]byte) {
+	copy(addr[:], v[12:])
+	return addr
+}
+
+func (v MatchView) VlanId() uint16 {
+	return binary.BigEndian.Uint16(v[18:])
+}
+
+func (v MatchView) VlanPcp() uint8 {
+	return v[20]
+}
+
+func (v MatchView) EthType() uint16 {
+	return binary.BigEndian.Uint16(v[22:])
+}
+
+func (v MatchView) NwTos() uint8 {
+	return v[24]
+}
+
+func (v MatchView) NwProto() uint8 {
+	return v[25]
+}
+
+func (v MatchView) NwSrc() uint32 {
+	return binary.BigEndian.Uint32(v[28:])
+}
+
+func (v MatchView) NwDst() uint32 {
+	return binary.BigEndian.Uint32(v[32:])
+}
+
+func (v MatchView) TpSrc() uint16 {
+	return binary.BigEndian.Uint16(v[36:])
+}
+
+func (v MatchView) TpDst() uint16 {
+	return binary.BigEndian.Uint16(v[38:])
+}
+
+// Match copies the viewed match into an owned Match.
+func (v MatchView) Match() Match {
+	match := Match{}
+	match.Unmarshal(v)
+	return match
+}
+
+// PortView is a view of a physical port.
+type PortView []byte
+
+// NewPortView creates the view of the port at the beginning of 'buf'.
+func NewPortView(buf []byte) (PortView, error) {
+	if len(buf) < portSize {
+		return nil, errors.New("buffer is too short")
+	}
+	return PortView(buf[:portSize]), nil
+}
+
+func (v PortView) PortNo() uint16 {
+	return binary.BigEndian.Uint16(v)
+}
+
+func (v PortView) HwAddr() (addr [6]byte) {
+	copy(addr[:], v[2:])
+	return addr
+}
+
+// Name gets the port name without the null terminator, it aliases the viewed
+// bytes.
+func (v PortView) Name() []byte {
+	name := v[8 : 8+OFP_MAX_PORT_NAME_LEN]
+	if i := bytes.IndexByte(name, 0); i >= 0 {
+		name = name[:i]
+	}
+	return name
+}
+
+func (v PortView) Config() uint32 {
+	return binary.BigEndian.Uint32(v[24:])
+}
+
+func (v PortView) State() uint32 {
+	return binary.BigEndian.Uint32(v[28:])
+}
+
+func (v PortView) CurrFeatures() uint32 {
+	return binary.BigEndian.Uint32(v[32:])
+}
+
+func (v PortView) AdvertisedFeatures() uint32 {
+	return binary.BigEndian.Uint32(v[36:])
+}
+
+func (v PortView) SupportedFeatures() uint32 {
+	return binary.BigEndian.Uint32(v[40:])
+}
+
+func (v PortView) PeerFeatures() uint32 {
+	return binary.BigEndian.Uint32(v[44:])
+}
+
+// Port copies the viewed port into an owned Port.
+func (v PortView) Port() Port {
+	port := Port{}
+	port.Unmarshal(v)
+	return port
+}
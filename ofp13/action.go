@@ -0,0 +1,351 @@
+package ofp13
+
+import (
+	"encoding/binary"
+	"errors"
+
+	"github.com/kuun/ofgo/ofp"
+)
+
+// Defines openflow 1.3 action type.
+const (
+	OFPAT_OUTPUT       = 0      // Output to switch port.
+	OFPAT_COPY_TTL_OUT = 11     // Copy TTL "outwards" -- from next-to-outermost to outermost.
+	OFPAT_COPY_TTL_IN  = 12     // Copy TTL "inwards" -- from outermost to next-to-outermost.
+	OFPAT_SET_MPLS_TTL = 15     // MPLS TTL.
+	OFPAT_DEC_MPLS_TTL = 16     // Decrement MPLS TTL.
+	OFPAT_PUSH_VLAN    = 17     // Push a new VLAN tag.
+	OFPAT_POP_VLAN     = 18     // Pop the outer VLAN tag.
+	OFPAT_PUSH_MPLS    = 19     // Push a new MPLS tag.
+	OFPAT_POP_MPLS     = 20     // Pop the outer MPLS tag.
+	OFPAT_SET_QUEUE    = 21     // Set queue id when outputting to a port.
+	OFPAT_GROUP        = 22     // Apply group.
+	OFPAT_SET_NW_TTL   = 23     // IP TTL.
+	OFPAT_DEC_NW_TTL   = 24     // Decrement IP TTL.
+	OFPAT_SET_FIELD    = 25     // Set a header field using OXM TLV format.
+	OFPAT_PUSH_PBB     = 26     // Push a new PBB service tag (I-TAG).
+	OFPAT_POP_PBB      = 27     // Pop the outer PBB service tag (I-TAG).
+	OFPAT_EXPERIMENTER = 0xffff // Experimenter action.
+)
+
+const (
+	OFPCML_MAX       = 0xffe5 // Maximum max_len value which can be used to request a specific byte length.
+	OFPCML_NO_BUFFER = 0xffff // Indicates that no buffering should be applied and the whole packet is to be sent to the controller.
+)
+
+type ActionType uint16
+
+type Action interface {
+	ofp.DataBlock
+	Type() ActionType
+}
+
+// ActionHeader is common to all actions. The length includes the
+// header and any padding used to make the action 64-bit aligned.
+// NB: The length of an action *must* always be a multiple of eight.
+type ActionHeader struct {
+	Type   ActionType // One of OFPAT_*.
+	Length uint16     // Length of action, including this header and padding.
+}
+
+func (self *ActionHeader) Len() int {
+	return 4
+}
+
+func (self *ActionHeader) Marshal(buff []byte) (n int, err error) {
+	if len(buff) < self.Len() {
+		return 0, ofp.NewNoBuffError()
+	}
+	binary.BigEndian.PutUint16(buff, uint16(self.Type))
+	binary.BigEndian.PutUint16(buff[2:], self.Length)
+	return 4, nil
+}
+
+func (self *ActionHeader) Unmarshal(buff []byte) (n int, err error) {
+	if len(buff) < self.Len() {
+		return 0, ofp.NewNoBuffError()
+	}
+	self.Type = ActionType(binary.BigEndian.Uint16(buff))
+	self.Length = binary.BigEndian.Uint16(buff[2:])
+	return 4, nil
+}
+
+// ActionOutput is action for OFPAT_OUTPUT, which sends packets out 'Port'.
+// When the 'Port' is the OFPP_CONTROLLER, 'MaxLen' indicates the max number
+// of bytes to send. A 'MaxLen' of zero means no bytes of the packet should be
+// sent. A 'MaxLen' of OFPCML_NO_BUFFER means that the packet is not buffered
+// and the complete packet is to be sent to the controller.
+type ActionOutput struct {
+	ActionHeader
+	Port   uint32 // Output port.
+	MaxLen uint16 // Max length to send to controller.
+	Pad    [6]byte
+}
+
+func NewActionOutput(port uint32) *ActionOutput {
+	return &ActionOutput{
+		ActionHeader: ActionHeader{Type: OFPAT_OUTPUT, Length: 16},
+		Port:         port,
+	}
+}
+
+func (self *ActionOutput) Type() ActionType {
+	return OFPAT_OUTPUT
+}
+
+func (self *ActionOutput) Len() int {
+	return int(self.Length)
+}
+
+func (self *ActionOutput) Marshal(buff []byte) (n int, err error) {
+	if len(buff) < self.Len() {
+		return 0, ofp.NewNoBuffError()
+	}
+	if n, err = self.ActionHeader.Marshal(buff); err != nil {
+		return n, err
+	}
+	binary.BigEndian.PutUint32(buff[n:], self.Port)
+	n += 4
+	binary.BigEndian.PutUint16(buff[n:], self.MaxLen)
+	n += 2
+	n += copy(buff[n:], self.Pad[:])
+	return n, nil
+}
+
+func (self *ActionOutput) Unmarshal(buff []byte) (n int, err error) {
+	if len(buff) < 16 {
+		return 0, ofp.NewNoBuffError()
+	}
+	if n, err = self.ActionHeader.Unmarshal(buff); err != nil {
+		return n, err
+	}
+	self.Port = binary.BigEndian.Uint32(buff[n:])
+	n += 4
+	self.MaxLen = binary.BigEndian.Uint16(buff[n:])
+	n += 8
+	return n, nil
+}
+
+// ActionGeneric is an action with a 32 bits argument, or without argument,
+// e.g. OFPAT_GROUP, OFPAT_SET_QUEUE, OFPAT_POP_VLAN and OFPAT_DEC_NW_TTL.
+// For OFPAT_PUSH_* actions, the upper 16 bits of 'Arg' is the ethertype.
+type ActionGeneric struct {
+	ActionHeader
+	Arg uint32
+}
+
+func newActionGeneric(actionType ActionType, arg uint32) *ActionGeneric {
+	return &ActionGeneric{
+		ActionHeader: ActionHeader{Type: actionType, Length: 8},
+		Arg:          arg,
+	}
+}
+
+// NewActionGroup creates an OFPAT_GROUP action.
+func NewActionGroup(groupId uint32) *ActionGeneric {
+	return newActionGeneric(OFPAT_GROUP, groupId)
+}
+
+// NewActionSetQueue creates an OFPAT_SET_QUEUE action.
+func NewActionSetQueue(queueId uint32) *ActionGeneric {
+	return newActionGeneric(OFPAT_SET_QUEUE, queueId)
+}
+
+// NewActionPushVlan creates an OFPAT_PUSH_VLAN action.
+func NewActionPushVlan(ethertype uint16) *ActionGeneric {
+	return newActionGeneric(OFPAT_PUSH_VLAN, uint32(ethertype)<<16)
+}
+
+// NewActionPopVlan creates an OFPAT_POP_VLAN action.
+func NewActionPopVlan() *ActionGeneric {
+	return newActionGeneric(OFPAT_POP_VLAN, 0)
+}
+
+func (self *ActionGeneric) Type() ActionType {
+	return self.ActionHeader.Type
+}
+
+func (self *ActionGeneric) Len() int {
+	return int(self.Length)
+}
+
+func (self *ActionGeneric) Marshal(buff []byte) (n int, err error) {
+	if len(buff) < self.Len() {
+		return 0, ofp.NewNoBuffError()
+	}
+	if n, err = self.ActionHeader.Marshal(buff); err != nil {
+		return n, err
+	}
+	binary.BigEndian.PutUint32(buff[n:], self.Arg)
+	n += 4
+	return n, nil
+}
+
+func (self *ActionGeneric) Unmarshal(buff []byte) (n int, err error) {
+	if len(buff) < 8 {
+		return 0, ofp.NewNoBuffError()
+	}
+	if n, err = self.ActionHeader.Unmarshal(buff); err != nil {
+		return n, err
+	}
+	self.Arg = binary.BigEndian.Uint32(buff[n:])
+	n += 4
+	return n, nil
+}
+
+// ActionSetField is action for OFPAT_SET_FIELD, the field is padded to make
+// the action 64-bit aligned.
+type ActionSetField struct {
+	ActionHeader
+	Field OxmField
+}
+
+func NewActionSetField(field *OxmField) *ActionSetField {
+	return &ActionSetField{
+		ActionHeader: ActionHeader{Type: OFPAT_SET_FIELD, Length: uint16((4 + field.Len() + 7) / 8 * 8)},
+		Field:        *field,
+	}
+}
+
+func (self *ActionSetField) Type() ActionType {
+	return OFPAT_SET_FIELD
+}
+
+func (self *ActionSetField) Len() int {
+	return int(self.Length)
+}
+
+func (self *ActionSetField) Marshal(buff []byte) (n int, err error) {
+	if len(buff) < self.Len() {
+		return 0, ofp.NewNoBuffError()
+	}
+	if n, err = self.ActionHeader.Marshal(buff); err != nil {
+		return n, err
+	}
+	var m int
+	if m, err = self.Field.Marshal(buff[n:]); err != nil {
+		return n + m, err
+	}
+	for n += m; n < self.Len(); n++ {
+		buff[n] = 0
+	}
+	return n, nil
+}
+
+func (self *ActionSetField) Unmarshal(buff []byte) (n int, err error) {
+	if n, err = self.ActionHeader.Unmarshal(buff); err != nil {
+		return n, err
+	}
+	if len(buff) < self.Len() {
+		return 0, ofp.NewNoBuffError()
+	}
+	if _, err = self.Field.Unmarshal(buff[n:self.Len()]); err != nil {
+		return n, err
+	}
+	return self.Len(), nil
+}
+
+// ActionExperimenter is an experimenter action, the body is kept as is.
+type ActionExperimenter struct {
+	ActionHeader
+	Experimenter uint32 // Experimenter ID.
+	Data         []byte // Experimenter defined data, including padding.
+}
+
+func (self *ActionExperimenter) Type() ActionType {
+	return OFPAT_EXPERIMENTER
+}
+
+func (self *ActionExperimenter) Len() int {
+	return int(self.Length)
+}
+
+func (self *ActionExperimenter) Marshal(buff []byte) (n int, err error) {
+	if len(buff) < self.Len() {
+		return 0, ofp.NewNoBuffError()
+	}
+	if n, err = self.ActionHeader.Marshal(buff); err != nil {
+		return n, err
+	}
+	binary.BigEndian.PutUint32(buff[n:], self.Experimenter)
+	n += 4
+	n += copy(buff[n:self.Len()], self.Data)
+	return n, nil
+}
+
+func (self *ActionExperimenter) Unmarshal(buff []byte) (n int, err error) {
+	if n, err = self.ActionHeader.Unmarshal(buff); err != nil {
+		return n, err
+	}
+	if len(buff) < self.Len() || self.Len() < 8 {
+		return 0, ofp.NewNoBuffError()
+	}
+	self.Experimenter = binary.BigEndian.Uint32(buff[n:])
+	n += 4
+	self.Data = append([]byte(nil), buff[n:self.Len()]...)
+	return self.Len(), nil
+}
+
+// newAction creates an empty action of the action type, returns nil if the
+// type is unknown.
+func newAction(actionType ActionType) Action {
+	switch actionType {
+	case OFPAT_OUTPUT:
+		return &ActionOutput{}
+	case OFPAT_SET_FIELD:
+		return &ActionSetField{}
+	case OFPAT_EXPERIMENTER:
+		return &ActionExperimenter{}
+	case OFPAT_COPY_TTL_OUT, OFPAT_COPY_TTL_IN, OFPAT_SET_MPLS_TTL,
+		OFPAT_DEC_MPLS_TTL, OFPAT_PUSH_VLAN, OFPAT_POP_VLAN, OFPAT_PUSH_MPLS,
+		OFPAT_POP_MPLS, OFPAT_SET_QUEUE, OFPAT_GROUP, OFPAT_SET_NW_TTL,
+		OFPAT_DEC_NW_TTL, OFPAT_PUSH_PBB, OFPAT_POP_PBB:
+		return &ActionGeneric{}
+	}
+	return nil
+}
+
+// UnmarshalActions unmarshals an action list which fills the whole buffer.
+func UnmarshalActions(buff []byte) (actions []Action, err error) {
+	n := 0
+	for n < len(buff) {
+		header := ActionHeader{}
+		if _, err = header.Unmarshal(buff[n:]); err != nil {
+			return nil, err
+		}
+		length := int(header.Length)
+		if length < 8 || length%8 != 0 || n+length > len(buff) {
+			return nil, errors.New("bad action length")
+		}
+		action := newAction(header.Type)
+		if action == nil {
+			return nil, errors.New("unknown action type")
+		}
+		if _, err = action.Unmarshal(buff[n : n+length]); err != nil {
+			return nil, err
+		}
+		actions = append(actions, action)
+		n += length
+	}
+	return actions, nil
+}
+
+// ActionsLen gets the binary length of an action list.
+func ActionsLen(actions []Action) int {
+	length := 0
+	for _, action := range actions {
+		length += action.Len()
+	}
+	return length
+}
+
+// MarshalActions marshals an action list to buffer.
+func MarshalActions(buff []byte, actions []Action) (n int, err error) {
+	for _, action := range actions {
+		if _, err = action.Marshal(buff[n:]); err != nil {
+			return n, err
+		}
+		n += action.Len()
+	}
+	return n, nil
+}
@@ -0,0 +1,50 @@
+package ofp13
+
+import (
+	"github.com/kuun/ofgo/ofp"
+)
+
+func init() {
+	ofp.RegisterDecoder(ofp.OFP13_VERSION, Decode)
+}
+
+// newMessage creates an empty message of the message type, message types
+// without a typed form are decoded as *ofp.RawMessage.
+func newMessage(msgType uint8) ofp.DataBlock {
+	switch msgType {
+	case OFPT_HELLO:
+		return &ofp.Hello{}
+	case OFPT_ERROR:
+		return &ofp.Error{}
+	case OFPT_ECHO_REQUEST:
+		return &ofp.EchoRequest{}
+	case OFPT_ECHO_REPLY:
+		return &ofp.EchoResponse{}
+	case OFPT_FEATURES_REPLY:
+		return &FeaturesReply{}
+	case OFPT_PACKET_IN:
+		return &PacketIn{}
+	case OFPT_PORT_STATUS:
+		return &PortStatus{}
+	case OFPT_FLOW_MOD:
+		return &FlowMod{}
+	case OFPT_MULTIPART_REQUEST:
+		return &MultipartRequest{}
+	case OFPT_MULTIPART_REPLY:
+		return &MultipartReply{}
+	}
+	return &ofp.RawMessage{}
+}
+
+// Decode decodes a whole openflow 1.3 message.
+func Decode(buf []byte) (ofp.DataBlock, error) {
+	header := ofp.Header{}
+	if _, err := header.Unmarshal(buf); err != nil {
+		return nil, err
+	}
+	msg := newMessage(header.Type)
+	if _, err := msg.Unmarshal(buf); err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
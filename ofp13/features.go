@@ -0,0 +1,111 @@
+package ofp13
+
+import (
+	"encoding/binary"
+	"errors"
+
+	"github.com/kuun/ofgo/ofp"
+)
+
+// Capabilities supported by the datapath.
+const (
+	OFPC_FLOW_STATS   = 1 << 0 // Flow statistics.
+	OFPC_TABLE_STATS  = 1 << 1 // Table statistics.
+	OFPC_PORT_STATS   = 1 << 2 // Port statistics.
+	OFPC_GROUP_STATS  = 1 << 3 // Group statistics.
+	OFPC_IP_REASM     = 1 << 5 // Can reassemble IP fragments.
+	OFPC_QUEUE_STATS  = 1 << 6 // Queue statistics.
+	OFPC_PORT_BLOCKED = 1 << 8 // Switch will block looping ports.
+)
+
+// features reply binary size, in byte
+const featuresReplySize = 32
+
+// NewFeaturesRequest creates a features request, the message has no body.
+func NewFeaturesRequest() *ofp.RawMessage {
+	return &ofp.RawMessage{
+		Header: ofp.Header{
+			Version: ofp.OFP13_VERSION,
+			Type:    OFPT_FEATURES_REQUEST,
+			Length:  ofp.HeaderLength,
+		},
+	}
+}
+
+// FeaturesReply is the switch features message, switch -> controller. Unlike
+// openflow 1.0 it doesn't carry the ports.
+type FeaturesReply struct {
+	ofp.Header
+	Dpid         uint64 // Datapath unique id, the lower 48-bits are for a MAC address, while the upper 16-bits are implementer-defined.
+	NBuffers     uint32 // Max packets buffered at once.
+	NTables      uint8  // Number of tables supported by datapath.
+	AuxiliaryId  uint8  // Identify auxiliary connections, 0 for the main connection.
+	pad          [2]byte
+	Capabilities uint32 // Bitmap of OFPC_*.
+	Reserved     uint32
+}
+
+func NewFeaturesReply() *FeaturesReply {
+	return &FeaturesReply{
+		Header: ofp.Header{
+			Version: ofp.OFP13_VERSION,
+			Type:    OFPT_FEATURES_REPLY,
+			Length:  featuresReplySize,
+		},
+	}
+}
+
+func (msg *FeaturesReply) Len() int {
+	return int(msg.Header.Length)
+}
+
+func (msg *FeaturesReply) Marshal(buf []byte) (n int, err error) {
+	if len(buf) < msg.Len() || msg.Len() < featuresReplySize {
+		return 0, errors.New("buffer is too short")
+	}
+	if n, err = msg.Header.Marshal(buf); err != nil {
+		return n, err
+	}
+	binary.BigEndian.PutUint64(buf[n:], msg.Dpid)
+	n += 8
+	binary.BigEndian.PutUint32(buf[n:], msg.NBuffers)
+	n += 4
+	buf[n] = msg.NTables
+	n++
+	buf[n] = msg.AuxiliaryId
+	n++
+	copy(buf[n:], msg.pad[:])
+	n += 2
+	binary.BigEndian.PutUint32(buf[n:], msg.Capabilities)
+	n += 4
+	binary.BigEndian.PutUint32(buf[n:], msg.Reserved)
+	n += 4
+	return msg.Len(), nil
+}
+
+func (msg *FeaturesReply) Unmarshal(buf []byte) (n int, err error) {
+	if n, err = msg.Header.Unmarshal(buf); err != nil {
+		return n, err
+	}
+	if len(buf) < msg.Len() || msg.Len() < featuresReplySize {
+		return 0, errors.New("buffer is too short")
+	}
+	msg.Dpid = binary.BigEndian.Uint64(buf[n:])
+	n += 8
+	msg.NBuffers = binary.BigEndian.Uint32(buf[n:])
+	n += 4
+	msg.NTables = buf[n]
+	n++
+	msg.AuxiliaryId = buf[n]
+	n += 3
+	msg.Capabilities = binary.BigEndian.Uint32(buf[n:])
+	n += 4
+	msg.Reserved = binary.BigEndian.Uint32(buf[n:])
+	n += 4
+	return msg.Len(), nil
+}
+
+// AppendBinary appends the message's binary form to buf.
+func (msg *FeaturesReply) AppendBinary(buf []byte) ([]byte, error) {
+	return ofp.AppendBinary(buf, msg)
+}
@@ -0,0 +1,193 @@
+package ofp13
+
+import (
+	"encoding/binary"
+	"errors"
+
+	"github.com/kuun/ofgo/ofp"
+)
+
+// Flow mod commands.
+const (
+	OFPFC_ADD           = iota // New flow.
+	OFPFC_MODIFY               // Modify all matching flows.
+	OFPFC_MODIFY_STRICT        // Modify entry strictly matching wildcards and priority.
+	OFPFC_DELETE               // Delete all matching flows.
+	OFPFC_DELETE_STRICT        // Delete entry strictly matching wildcards and priority.
+)
+
+// Flow mod flags.
+const (
+	OFPFF_SEND_FLOW_REM = 1 << iota // Send flow removed message when flow expires or is deleted.
+	OFPFF_CHECK_OVERLAP             // Check for overlapping entries first.
+	OFPFF_RESET_COUNTS              // Reset flow packet and byte counts.
+	OFPFF_NO_PKT_COUNTS             // Don't keep track of packet count.
+	OFPFF_NO_BYT_COUNTS             // Don't keep track of byte count.
+)
+
+const (
+	OFP_DEFAULT_PRIORITY = 0x8000     // By default, choose a priority in the middle.
+	OFP_NO_BUFFER        = 0xffffffff // The packet isn't buffered on the switch.
+	OFP_FLOW_PERMANENT   = 0          // Value used in idle_timeout and hard_timeout to indicate that the entry is permanent.
+
+	OFPTT_ALL = 0xff       // Wildcard table used for table config, flow stats and flow deletes.
+	OFPG_ANY  = 0xffffffff // Wildcard group used only for flow stats requests.
+)
+
+// flow mod binary size without match and instructions, in byte
+const flowModSize = 48
+
+// FlowMod is flow setup and teardown message, controller -> switch.
+type FlowMod struct {
+	ofp.Header
+	Cookie     uint64 // Opaque controller-issued identifier.
+	CookieMask uint64 // Mask used to restrict the cookie bits that must match when the command is OFPFC_MODIFY* or OFPFC_DELETE*.
+
+	TableId     uint8  // ID of the table to put the flow in.
+	Command     uint8  // One of OFPFC_*.
+	IdleTimeout uint16 // Idle time before discarding (seconds).
+	HardTimeout uint16 // Max time before discarding (seconds).
+	Priority    uint16 // Priority level of flow entry.
+	// Buffered packet to apply to, or OFP_NO_BUFFER. Not meaningful for
+	// OFPFC_DELETE*.
+	BufferId uint32
+	// For OFPFC_DELETE* commands, require matching entries to include this as
+	// an output port. A value of OFPP_ANY indicates no restriction.
+	OutPort uint32
+	// For OFPFC_DELETE* commands, require matching entries to include this as
+	// an output group. A value of OFPG_ANY indicates no restriction.
+	OutGroup     uint32
+	Flags        uint16        // Bitmap of OFPFF_* flags.
+	Match        Match         // Fields to match. Variable size.
+	Instructions []Instruction // The instruction length is inferred from the length field in the header.
+}
+
+func NewFlowMod() *FlowMod {
+	match := NewMatch()
+	return &FlowMod{
+		Header: ofp.Header{
+			Version: ofp.OFP13_VERSION,
+			Type:    OFPT_FLOW_MOD,
+			Length:  uint16(flowModSize + match.Len()),
+		},
+		Priority: OFP_DEFAULT_PRIORITY,
+		BufferId: OFP_NO_BUFFER,
+		OutPort:  OFPP_ANY,
+		OutGroup: OFPG_ANY,
+		Match:    *match,
+	}
+}
+
+// SetMatch replaces the message's match and updates the message length.
+func (msg *FlowMod) SetMatch(match *Match) *FlowMod {
+	msg.Header.Length -= uint16(msg.Match.Len())
+	msg.Match = *match
+	msg.Header.Length += uint16(msg.Match.Len())
+	return msg
+}
+
+// AddInstruction appends an instruction to the message and updates the
+// message length, actions must be added to the instruction beforehand.
+func (msg *FlowMod) AddInstruction(inst Instruction) *FlowMod {
+	msg.Instructions = append(msg.Instructions, inst)
+	msg.Header.Length += uint16(inst.Len())
+	return msg
+}
+
+func (msg *FlowMod) Len() int {
+	return int(msg.Header.Length)
+}
+
+func (msg *FlowMod) Marshal(buf []byte) (n int, err error) {
+	length := flowModSize + msg.Match.Len()
+	for _, inst := range msg.Instructions {
+		length += inst.Len()
+	}
+	if msg.Len() != length {
+		return 0, errors.New("bad flow mod length")
+	}
+	if len(buf) < msg.Len() {
+		return 0, errors.New("buffer is too short")
+	}
+	if n, err = msg.Header.Marshal(buf); err != nil {
+		return n, err
+	}
+	binary.BigEndian.PutUint64(buf[n:], msg.Cookie)
+	n += 8
+	binary.BigEndian.PutUint64(buf[n:], msg.CookieMask)
+	n += 8
+	buf[n] = msg.TableId
+	n++
+	buf[n] = msg.Command
+	n++
+	binary.BigEndian.PutUint16(buf[n:], msg.IdleTimeout)
+	n += 2
+	binary.BigEndian.PutUint16(buf[n:], msg.HardTimeout)
+	n += 2
+	binary.BigEndian.PutUint16(buf[n:], msg.Priority)
+	n += 2
+	binary.BigEndian.PutUint32(buf[n:], msg.BufferId)
+	n += 4
+	binary.BigEndian.PutUint32(buf[n:], msg.OutPort)
+	n += 4
+	binary.BigEndian.PutUint32(buf[n:], msg.OutGroup)
+	n += 4
+	binary.BigEndian.PutUint16(buf[n:], msg.Flags)
+	n += 2
+	buf[n], buf[n+1] = 0, 0
+	n += 2
+	var m int
+	if m, err = msg.Match.Marshal(buf[n:]); err != nil {
+		return n + m, err
+	}
+	n += m
+	if m, err = MarshalInstructions(buf[n:msg.Len()], msg.Instructions); err != nil {
+		return n + m, err
+	}
+	return msg.Len(), nil
+}
+
+func (msg *FlowMod) Unmarshal(buf []byte) (n int, err error) {
+	if n, err = msg.Header.Unmarshal(buf); err != nil {
+		return n, err
+	}
+	if len(buf) < msg.Len() || msg.Len() < flowModSize+matchHeaderLen {
+		return 0, errors.New("buffer is too short")
+	}
+	msg.Cookie = binary.BigEndian.Uint64(buf[n:])
+	n += 8
+	msg.CookieMask = binary.BigEndian.Uint64(buf[n:])
+	n += 8
+	msg.TableId = buf[n]
+	n++
+	msg.Command = buf[n]
+	n++
+	msg.IdleTimeout = binary.BigEndian.Uint16(buf[n:])
+	n += 2
+	msg.HardTimeout = binary.BigEndian.Uint16(buf[n:])
+	n += 2
+	msg.Priority = binary.BigEndian.Uint16(buf[n:])
+	n += 2
+	msg.BufferId = binary.BigEndian.Uint32(buf[n:])
+	n += 4
+	msg.OutPort = binary.BigEndian.Uint32(buf[n:])
+	n += 4
+	msg.OutGroup = binary.BigEndian.Uint32(buf[n:])
+	n += 4
+	msg.Flags = binary.BigEndian.Uint16(buf[n:])
+	n += 4 // plus 2 padding bytes
+	var m int
+	if m, err = msg.Match.Unmarshal(buf[n:msg.Len()]); err != nil {
+		return n + m, err
+	}
+	n += m
+	if msg.Instructions, err = UnmarshalInstructions(buf[n:msg.Len()]); err != nil {
+		return n, err
+	}
+	return msg.Len(), nil
+}
+
+// AppendBinary appends the message's binary form to buf.
+func (msg *FlowMod) AppendBinary(buf []byte) ([]byte, error) {
+	return ofp.AppendBinary(buf, msg)
+}
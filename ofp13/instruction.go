@@ -0,0 +1,247 @@
+package ofp13
+
+import (
+	"encoding/binary"
+	"errors"
+
+	"github.com/kuun/ofgo/ofp"
+)
+
+// Instruction types.
+const (
+	OFPIT_GOTO_TABLE     = 1      // Setup the next table in the lookup pipeline.
+	OFPIT_WRITE_METADATA = 2      // Setup the metadata field for use later in pipeline.
+	OFPIT_WRITE_ACTIONS  = 3      // Write the action(s) onto the datapath action set.
+	OFPIT_APPLY_ACTIONS  = 4      // Applies the action(s) immediately.
+	OFPIT_CLEAR_ACTIONS  = 5      // Clears all actions from the datapath action set.
+	OFPIT_METER          = 6      // Apply meter (rate limiter).
+	OFPIT_EXPERIMENTER   = 0xffff // Experimenter instruction.
+)
+
+type Instruction interface {
+	ofp.DataBlock
+	Type() uint16
+}
+
+// InstructionHeader is common to all instructions. The length includes the
+// header and any padding used to make the instruction 64-bit aligned.
+type InstructionHeader struct {
+	Type   uint16 // One of OFPIT_*.
+	Length uint16 // Length of this struct in bytes.
+}
+
+func (self *InstructionHeader) Len() int {
+	return 4
+}
+
+func (self *InstructionHeader) Marshal(buff []byte) (n int, err error) {
+	if len(buff) < self.Len() {
+		return 0, ofp.NewNoBuffError()
+	}
+	binary.BigEndian.PutUint16(buff, self.Type)
+	binary.BigEndian.PutUint16(buff[2:], self.Length)
+	return 4, nil
+}
+
+func (self *InstructionHeader) Unmarshal(buff []byte) (n int, err error) {
+	if len(buff) < self.Len() {
+		return 0, ofp.NewNoBuffError()
+	}
+	self.Type = binary.BigEndian.Uint16(buff)
+	self.Length = binary.BigEndian.Uint16(buff[2:])
+	return 4, nil
+}
+
+// InstructionGotoTable is instruction for OFPIT_GOTO_TABLE.
+type InstructionGotoTable struct {
+	InstructionHeader
+	TableId uint8 // Set next table in the lookup pipeline.
+	Pad     [3]byte
+}
+
+func NewInstructionGotoTable(tableId uint8) *InstructionGotoTable {
+	return &InstructionGotoTable{
+		InstructionHeader: InstructionHeader{Type: OFPIT_GOTO_TABLE, Length: 8},
+		TableId:           tableId,
+	}
+}
+
+func (self *InstructionGotoTable) Type() uint16 {
+	return OFPIT_GOTO_TABLE
+}
+
+func (self *InstructionGotoTable) Len() int {
+	return int(self.Length)
+}
+
+func (self *InstructionGotoTable) Marshal(buff []byte) (n int, err error) {
+	if len(buff) < self.Len() {
+		return 0, ofp.NewNoBuffError()
+	}
+	if n, err = self.InstructionHeader.Marshal(buff); err != nil {
+		return n, err
+	}
+	buff[n] = self.TableId
+	n++
+	n += copy(buff[n:], self.Pad[:])
+	return n, nil
+}
+
+func (self *InstructionGotoTable) Unmarshal(buff []byte) (n int, err error) {
+	if len(buff) < 8 {
+		return 0, ofp.NewNoBuffError()
+	}
+	if n, err = self.InstructionHeader.Unmarshal(buff); err != nil {
+		return n, err
+	}
+	self.TableId = buff[n]
+	n += 4
+	return n, nil
+}
+
+// InstructionActions is instruction for OFPIT_WRITE/APPLY/CLEAR_ACTIONS.
+type InstructionActions struct {
+	InstructionHeader
+	Pad     [4]byte
+	Actions []Action // Actions associated with OFPIT_WRITE_ACTIONS and OFPIT_APPLY_ACTIONS.
+}
+
+func newInstructionActions(instType uint16) *InstructionActions {
+	return &InstructionActions{
+		InstructionHeader: InstructionHeader{Type: instType, Length: 8},
+	}
+}
+
+func NewInstructionApplyActions() *InstructionActions {
+	return newInstructionActions(OFPIT_APPLY_ACTIONS)
+}
+
+func NewInstructionWriteActions() *InstructionActions {
+	return newInstructionActions(OFPIT_WRITE_ACTIONS)
+}
+
+func NewInstructionClearActions() *InstructionActions {
+	return newInstructionActions(OFPIT_CLEAR_ACTIONS)
+}
+
+// AddAction appends an action to the instruction and updates the instruction length.
+func (self *InstructionActions) AddAction(action Action) *InstructionActions {
+	self.Actions = append(self.Actions, action)
+	self.Length += uint16(action.Len())
+	return self
+}
+
+func (self *InstructionActions) Type() uint16 {
+	return self.InstructionHeader.Type
+}
+
+func (self *InstructionActions) Len() int {
+	return int(self.Length)
+}
+
+func (self *InstructionActions) Marshal(buff []byte) (n int, err error) {
+	if len(buff) < self.Len() {
+		return 0, ofp.NewNoBuffError()
+	}
+	if n, err = self.InstructionHeader.Marshal(buff); err != nil {
+		return n, err
+	}
+	n += copy(buff[n:], self.Pad[:])
+	var m int
+	if m, err = MarshalActions(buff[n:self.Len()], self.Actions); err != nil {
+		return n + m, err
+	}
+	return n + m, nil
+}
+
+func (self *InstructionActions) Unmarshal(buff []byte) (n int, err error) {
+	if n, err = self.InstructionHeader.Unmarshal(buff); err != nil {
+		return n, err
+	}
+	if len(buff) < self.Len() || self.Len() < 8 {
+		return 0, ofp.NewNoBuffError()
+	}
+	n += 4
+	if self.Actions, err = UnmarshalActions(buff[n:self.Len()]); err != nil {
+		return n, err
+	}
+	return self.Len(), nil
+}
+
+// InstructionRaw keeps the body of an instruction which isn't decoded, e.g.
+// OFPIT_WRITE_METADATA, OFPIT_METER and experimenter instructions.
+type InstructionRaw struct {
+	InstructionHeader
+	Data []byte // Instruction body, including padding.
+}
+
+func (self *InstructionRaw) Type() uint16 {
+	return self.InstructionHeader.Type
+}
+
+func (self *InstructionRaw) Len() int {
+	return int(self.Length)
+}
+
+func (self *InstructionRaw) Marshal(buff []byte) (n int, err error) {
+	if len(buff) < self.Len() {
+		return 0, ofp.NewNoBuffError()
+	}
+	if n, err = self.InstructionHeader.Marshal(buff); err != nil {
+		return n, err
+	}
+	n += copy(buff[n:self.Len()], self.Data)
+	return n, nil
+}
+
+func (self *InstructionRaw) Unmarshal(buff []byte) (n int, err error) {
+	if n, err = self.InstructionHeader.Unmarshal(buff); err != nil {
+		return n, err
+	}
+	if len(buff) < self.Len() || self.Len() < n {
+		return 0, ofp.NewNoBuffError()
+	}
+	self.Data = append([]byte(nil), buff[n:self.Len()]...)
+	return self.Len(), nil
+}
+
+// UnmarshalInstructions unmarshals an instruction list which fills the whole buffer.
+func UnmarshalInstructions(buff []byte) (insts []Instruction, err error) {
+	n := 0
+	for n < len(buff) {
+		header := InstructionHeader{}
+		if _, err = header.Unmarshal(buff[n:]); err != nil {
+			return nil, err
+		}
+		length := int(header.Length)
+		if length < 8 || length%8 != 0 || n+length > len(buff) {
+			return nil, errors.New("bad instruction length")
+		}
+		var inst Instruction
+		switch header.Type {
+		case OFPIT_GOTO_TABLE:
+			inst = &InstructionGotoTable{}
+		case OFPIT_WRITE_ACTIONS, OFPIT_APPLY_ACTIONS, OFPIT_CLEAR_ACTIONS:
+			inst = &InstructionActions{}
+		default:
+			inst = &InstructionRaw{}
+		}
+		if _, err = inst.Unmarshal(buff[n : n+length]); err != nil {
+			return nil, err
+		}
+		insts = append(insts, inst)
+		n += length
+	}
+	return insts, nil
+}
+
+// MarshalInstructions marshals an instruction list to buffer.
+func MarshalInstructions(buff []byte, insts []Instruction) (n int, err error) {
+	for _, inst := range insts {
+		if _, err = inst.Marshal(buff[n:]); err != nil {
+			return n, err
+		}
+		n += inst.Len()
+	}
+	return n, nil
+}
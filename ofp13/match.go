@@ -0,0 +1,210 @@
+package ofp13
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// The match type indicates the match structure (set of fields that compose the
+// match) in use.
+const (
+	OFPMT_STANDARD = 0 // Deprecated.
+	OFPMT_OXM      = 1 // OpenFlow Extensible Match.
+)
+
+// Ethernet types and IP protocols referenced by match field prerequisites.
+const (
+	ethTypeIPv4  = 0x0800
+	ethTypeARP   = 0x0806
+	ethTypeIPv6  = 0x86dd
+	ethTypeMPLS  = 0x8847
+	ethTypeMPLSM = 0x8848
+	ethTypePBB   = 0x88e7
+
+	ipProtoICMP   = 1
+	ipProtoTCP    = 6
+	ipProtoUDP    = 17
+	ipProtoICMPv6 = 58
+	ipProtoSCTP   = 132
+)
+
+// matchHeaderLen is the binary length of the match type and length fields.
+const matchHeaderLen = 4
+
+// Match is used to describe a flow entry, fields to match against flows. Fields
+// are a list of OXM TLVs, the match is padded to be 64-bit aligned.
+type Match struct {
+	Type   uint16     // One of OFPMT_*.
+	Length uint16     // Length of match, excluding padding.
+	Fields []OxmField // OXM fields to match.
+}
+
+// NewMatch creates an empty OXM match, which matches all packets.
+func NewMatch() *Match {
+	return &Match{Type: OFPMT_OXM, Length: matchHeaderLen}
+}
+
+// AddField appends an OXM field to the match and updates the match length.
+func (m *Match) AddField(f *OxmField) *Match {
+	m.Fields = append(m.Fields, *f)
+	m.Length += uint16(f.Len())
+	return m
+}
+
+// Field gets the OpenFlow basic class field, returns nil if the match doesn't
+// contain it.
+func (m *Match) Field(field uint8) *OxmField {
+	for i := range m.Fields {
+		if m.Fields[i].Is(field) {
+			return &m.Fields[i]
+		}
+	}
+	return nil
+}
+
+// Len gets the match's binary length, including the trailing padding.
+func (m *Match) Len() int {
+	return (int(m.Length) + 7) / 8 * 8
+}
+
+func (m *Match) Marshal(buf []byte) (n int, err error) {
+	if len(buf) < m.Len() {
+		return 0, errors.New("buffer is too short")
+	}
+	binary.BigEndian.PutUint16(buf, m.Type)
+	binary.BigEndian.PutUint16(buf[2:], m.Length)
+	n = matchHeaderLen
+	for i := range m.Fields {
+		var l int
+		if l, err = m.Fields[i].Marshal(buf[n:]); err != nil {
+			return n + l, err
+		}
+		n += l
+	}
+	for ; n < m.Len(); n++ {
+		buf[n] = 0
+	}
+	return n, nil
+}
+
+func (m *Match) Unmarshal(buf []byte) (n int, err error) {
+	if len(buf) < matchHeaderLen {
+		return 0, errors.New("buffer is too short")
+	}
+	m.Type = binary.BigEndian.Uint16(buf)
+	m.Length = binary.BigEndian.Uint16(buf[2:])
+	if m.Length < matchHeaderLen || len(buf) < m.Len() {
+		return 0, errors.New("bad match length")
+	}
+	n = matchHeaderLen
+	m.Fields = nil
+	for n < int(m.Length) {
+		f := OxmField{}
+		var l int
+		if l, err = f.Unmarshal(buf[n:m.Length]); err != nil {
+			return n + l, err
+		}
+		m.Fields = append(m.Fields, f)
+		n += l
+	}
+	return m.Len(), nil
+}
+
+// prerequisite checks one match field's prerequisite against the match.
+type prerequisite func(m *Match) bool
+
+func fieldIs(field uint8, values ...uint64) prerequisite {
+	return func(m *Match) bool {
+		f := m.Field(field)
+		if f == nil || f.HasMask() {
+			return false
+		}
+		v := fieldUint(f.Value)
+		for _, value := range values {
+			if v == value {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+func fieldExists(field uint8) prerequisite {
+	return func(m *Match) bool {
+		return m.Field(field) != nil
+	}
+}
+
+func vlanPresent(m *Match) bool {
+	f := m.Field(OFPXMT_OFB_VLAN_VID)
+	if f == nil {
+		return false
+	}
+	value := fieldUint(f.Value)
+	if f.HasMask() {
+		value &= fieldUint(f.Mask)
+	}
+	return value&OFPVID_PRESENT != 0
+}
+
+// prerequisites of OpenFlow basic class match fields.
+var prerequisites = map[uint8]prerequisite{
+	OFPXMT_OFB_IN_PHY_PORT:    fieldExists(OFPXMT_OFB_IN_PORT),
+	OFPXMT_OFB_VLAN_PCP:       vlanPresent,
+	OFPXMT_OFB_IP_DSCP:        fieldIs(OFPXMT_OFB_ETH_TYPE, ethTypeIPv4, ethTypeIPv6),
+	OFPXMT_OFB_IP_ECN:         fieldIs(OFPXMT_OFB_ETH_TYPE, ethTypeIPv4, ethTypeIPv6),
+	OFPXMT_OFB_IP_PROTO:       fieldIs(OFPXMT_OFB_ETH_TYPE, ethTypeIPv4, ethTypeIPv6),
+	OFPXMT_OFB_IPV4_SRC:       fieldIs(OFPXMT_OFB_ETH_TYPE, ethTypeIPv4),
+	OFPXMT_OFB_IPV4_DST:       fieldIs(OFPXMT_OFB_ETH_TYPE, ethTypeIPv4),
+	OFPXMT_OFB_TCP_SRC:        fieldIs(OFPXMT_OFB_IP_PROTO, ipProtoTCP),
+	OFPXMT_OFB_TCP_DST:        fieldIs(OFPXMT_OFB_IP_PROTO, ipProtoTCP),
+	OFPXMT_OFB_UDP_SRC:        fieldIs(OFPXMT_OFB_IP_PROTO, ipProtoUDP),
+	OFPXMT_OFB_UDP_DST:        fieldIs(OFPXMT_OFB_IP_PROTO, ipProtoUDP),
+	OFPXMT_OFB_SCTP_SRC:       fieldIs(OFPXMT_OFB_IP_PROTO, ipProtoSCTP),
+	OFPXMT_OFB_SCTP_DST:       fieldIs(OFPXMT_OFB_IP_PROTO, ipProtoSCTP),
+	OFPXMT_OFB_ICMPV4_TYPE:    fieldIs(OFPXMT_OFB_IP_PROTO, ipProtoICMP),
+	OFPXMT_OFB_ICMPV4_CODE:    fieldIs(OFPXMT_OFB_IP_PROTO, ipProtoICMP),
+	OFPXMT_OFB_ARP_OP:         fieldIs(OFPXMT_OFB_ETH_TYPE, ethTypeARP),
+	OFPXMT_OFB_ARP_SPA:        fieldIs(OFPXMT_OFB_ETH_TYPE, ethTypeARP),
+	OFPXMT_OFB_ARP_TPA:        fieldIs(OFPXMT_OFB_ETH_TYPE, ethTypeARP),
+	OFPXMT_OFB_ARP_SHA:        fieldIs(OFPXMT_OFB_ETH_TYPE, ethTypeARP),
+	OFPXMT_OFB_ARP_THA:        fieldIs(OFPXMT_OFB_ETH_TYPE, ethTypeARP),
+	OFPXMT_OFB_IPV6_SRC:       fieldIs(OFPXMT_OFB_ETH_TYPE, ethTypeIPv6),
+	OFPXMT_OFB_IPV6_DST:       fieldIs(OFPXMT_OFB_ETH_TYPE, ethTypeIPv6),
+	OFPXMT_OFB_IPV6_FLABEL:    fieldIs(OFPXMT_OFB_ETH_TYPE, ethTypeIPv6),
+	OFPXMT_OFB_ICMPV6_TYPE:    fieldIs(OFPXMT_OFB_IP_PROTO, ipProtoICMPv6),
+	OFPXMT_OFB_ICMPV6_CODE:    fieldIs(OFPXMT_OFB_IP_PROTO, ipProtoICMPv6),
+	OFPXMT_OFB_IPV6_ND_TARGET: fieldIs(OFPXMT_OFB_ICMPV6_TYPE, 135, 136),
+	OFPXMT_OFB_IPV6_ND_SLL:    fieldIs(OFPXMT_OFB_ICMPV6_TYPE, 135),
+	OFPXMT_OFB_IPV6_ND_TLL:    fieldIs(OFPXMT_OFB_ICMPV6_TYPE, 136),
+	OFPXMT_OFB_MPLS_LABEL:     fieldIs(OFPXMT_OFB_ETH_TYPE, ethTypeMPLS, ethTypeMPLSM),
+	OFPXMT_OFB_MPLS_TC:        fieldIs(OFPXMT_OFB_ETH_TYPE, ethTypeMPLS, ethTypeMPLSM),
+	OFPXMT_OFB_MPLS_BOS:       fieldIs(OFPXMT_OFB_ETH_TYPE, ethTypeMPLS, ethTypeMPLSM),
+	OFPXMT_OFB_PBB_ISID:       fieldIs(OFPXMT_OFB_ETH_TYPE, ethTypePBB),
+	OFPXMT_OFB_IPV6_EXTHDR:    fieldIs(OFPXMT_OFB_ETH_TYPE, ethTypeIPv6),
+}
+
+// CheckPrerequisites checks that every OpenFlow basic class field's
+// prerequisites are met by the match, as a switch would do before accepting it.
+func (m *Match) CheckPrerequisites() error {
+	for i := range m.Fields {
+		f := &m.Fields[i]
+		if f.Class != OFPXMC_OPENFLOW_BASIC {
+			continue
+		}
+		if check, ok := prerequisites[f.Field]; ok && !check(m) {
+			return fmt.Errorf("prerequisite of oxm field %d is not met", f.Field)
+		}
+	}
+	return nil
+}
+
+// fieldUint gets the big endian integer value of a field of up to 8 bytes.
+func fieldUint(b []byte) uint64 {
+	var v uint64
+	for _, c := range b {
+		v = v<<8 | uint64(c)
+	}
+	return v
+}
@@ -0,0 +1,376 @@
+package ofp13
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strings"
+
+	"github.com/kuun/ofgo/ofp10"
+)
+
+// oxmFieldNames are names of the OpenFlow basic class fields, indexed by OFPXMT_*.
+var oxmFieldNames = [...]string{
+	"in_port", "in_phy_port", "metadata", "eth_dst", "eth_src", "eth_type",
+	"vlan_vid", "vlan_pcp", "ip_dscp", "ip_ecn", "ip_proto", "ipv4_src",
+	"ipv4_dst", "tcp_src", "tcp_dst", "udp_src", "udp_dst", "sctp_src",
+	"sctp_dst", "icmpv4_type", "icmpv4_code", "arp_op", "arp_spa", "arp_tpa",
+	"arp_sha", "arp_tha", "ipv6_src", "ipv6_dst", "ipv6_flabel", "icmpv6_type",
+	"icmpv6_code", "ipv6_nd_target", "ipv6_nd_sll", "ipv6_nd_tll", "mpls_label",
+	"mpls_tc", "mpls_bos", "pbb_isid", "tunnel_id", "ipv6_exthdr",
+}
+
+func oxmFieldName(f *OxmField) string {
+	if f.Class == OFPXMC_OPENFLOW_BASIC && int(f.Field) < len(oxmFieldNames) {
+		name := oxmFieldNames[f.Field]
+		if f.HasMask() {
+			name += "/mask"
+		}
+		return name
+	}
+	return fmt.Sprintf("oxm(class=%#x,field=%d)", f.Class, f.Field)
+}
+
+// UnsupportedFieldsError reports match fields which cannot be represented in
+// the target openflow version, the converted match doesn't contain them.
+type UnsupportedFieldsError struct {
+	Fields []string // Names of the fields that were left out.
+}
+
+func (e *UnsupportedFieldsError) Error() string {
+	return "match fields cannot be represented: " + strings.Join(e.Fields, ", ")
+}
+
+func uint8Value(v uint8) []byte {
+	return []byte{v}
+}
+
+func uint16Value(v uint16) []byte {
+	b := make([]byte, 2)
+	binary.BigEndian.PutUint16(b, v)
+	return b
+}
+
+func uint32Value(v uint32) []byte {
+	b := make([]byte, 4)
+	binary.BigEndian.PutUint32(b, v)
+	return b
+}
+
+// prefixMask gets the network mask of the prefix length.
+func prefixMask(prefixLen int) uint32 {
+	if prefixLen <= 0 {
+		return 0
+	}
+	return ^uint32(0) << uint(32-prefixLen)
+}
+
+// maskPrefixLen gets the prefix length of a network mask, it returns false if
+// the mask is not contiguous.
+func maskPrefixLen(mask uint32) (int, bool) {
+	for prefixLen := 32; prefixLen >= 0; prefixLen-- {
+		if prefixMask(prefixLen) == mask {
+			return prefixLen, true
+		}
+	}
+	return 0, false
+}
+
+// addNwField adds an IPv4 address field matching the address prefix.
+func addNwField(m *Match, field uint8, addr uint32, prefixLen int) {
+	var mask []byte
+	if prefixLen < 32 {
+		mask = uint32Value(prefixMask(prefixLen))
+	}
+	m.AddField(NewOxmField(field, uint32Value(addr&prefixMask(prefixLen)), mask))
+}
+
+// MatchFromOfp10 converts an openflow 1.0 match into an OXM match. Fields are
+// emitted after their prerequisites. Openflow 1.0 switches ignore fields whose
+// prerequisites are not met, such fields and the ones without an OXM
+// equivalent are left out and reported by an *UnsupportedFieldsError which is
+// returned along with the converted match.
+func MatchFromOfp10(m *ofp10.Match) (*Match, error) {
+	w := m.Wildcards
+	out := NewMatch()
+	var unsupported []string
+
+	if w&ofp10.OFPFW_IN_PORT == 0 {
+		out.AddField(NewOxmField(OFPXMT_OFB_IN_PORT, uint32Value(PortFromOfp10(m.InPort)), nil))
+	}
+	if w&ofp10.OFPFW_DL_DST == 0 {
+		out.AddField(NewOxmField(OFPXMT_OFB_ETH_DST, append([]byte(nil), m.EthDst[:]...), nil))
+	}
+	if w&ofp10.OFPFW_DL_SRC == 0 {
+		out.AddField(NewOxmField(OFPXMT_OFB_ETH_SRC, append([]byte(nil), m.EthSrc[:]...), nil))
+	}
+	if w&ofp10.OFPFW_DL_TYPE == 0 {
+		out.AddField(NewOxmField(OFPXMT_OFB_ETH_TYPE, uint16Value(m.EthType), nil))
+	}
+
+	tagged := false
+	if w&ofp10.OFPFW_DL_VLAN == 0 {
+		if m.VlanId == ofp10.OFP_VLAN_NONE {
+			out.AddField(NewOxmField(OFPXMT_OFB_VLAN_VID, uint16Value(OFPVID_NONE), nil))
+		} else {
+			out.AddField(NewOxmField(OFPXMT_OFB_VLAN_VID, uint16Value(m.VlanId&0x0fff|OFPVID_PRESENT), nil))
+			tagged = true
+		}
+	}
+	if w&ofp10.OFPFW_DL_VLAN_PCP == 0 {
+		if w&ofp10.OFPFW_DL_VLAN != 0 {
+			// Any tagged packet with the priority.
+			out.AddField(NewOxmField(OFPXMT_OFB_VLAN_VID,
+				uint16Value(OFPVID_PRESENT), uint16Value(OFPVID_PRESENT)))
+			tagged = true
+		}
+		if tagged {
+			out.AddField(NewOxmField(OFPXMT_OFB_VLAN_PCP, uint8Value(m.VlanPcp&0x07), nil))
+		} else {
+			unsupported = append(unsupported, "dl_vlan_pcp")
+		}
+	}
+
+	dlTypeExact := w&ofp10.OFPFW_DL_TYPE == 0
+	isIP := dlTypeExact && m.EthType == ethTypeIPv4
+	isARP := dlTypeExact && m.EthType == ethTypeARP
+
+	if w&ofp10.OFPFW_NW_TOS == 0 {
+		if isIP {
+			out.AddField(NewOxmField(OFPXMT_OFB_IP_DSCP, uint8Value(m.NwTos>>2), nil))
+		} else {
+			unsupported = append(unsupported, "nw_tos")
+		}
+	}
+	protoExact := w&ofp10.OFPFW_NW_PROTO == 0
+	if protoExact {
+		switch {
+		case isIP:
+			out.AddField(NewOxmField(OFPXMT_OFB_IP_PROTO, uint8Value(m.NwProto), nil))
+		case isARP:
+			out.AddField(NewOxmField(OFPXMT_OFB_ARP_OP, uint16Value(uint16(m.NwProto)), nil))
+		default:
+			unsupported = append(unsupported, "nw_proto")
+		}
+	}
+	if prefixLen := m.NwSrcPrefixLen(); prefixLen > 0 {
+		switch {
+		case isIP:
+			addNwField(out, OFPXMT_OFB_IPV4_SRC, m.NwSrc, prefixLen)
+		case isARP:
+			addNwField(out, OFPXMT_OFB_ARP_SPA, m.NwSrc, prefixLen)
+		default:
+			unsupported = append(unsupported, "nw_src")
+		}
+	}
+	if prefixLen := m.NwDstPrefixLen(); prefixLen > 0 {
+		switch {
+		case isIP:
+			addNwField(out, OFPXMT_OFB_IPV4_DST, m.NwDst, prefixLen)
+		case isARP:
+			addNwField(out, OFPXMT_OFB_ARP_TPA, m.NwDst, prefixLen)
+		default:
+			unsupported = append(unsupported, "nw_dst")
+		}
+	}
+
+	var proto uint8
+	if isIP && protoExact {
+		proto = m.NwProto
+	}
+	if w&ofp10.OFPFW_TP_SRC == 0 {
+		switch proto {
+		case ipProtoTCP:
+			out.AddField(NewOxmField(OFPXMT_OFB_TCP_SRC, uint16Value(m.TpSrc), nil))
+		case ipProtoUDP:
+			out.AddField(NewOxmField(OFPXMT_OFB_UDP_SRC, uint16Value(m.TpSrc), nil))
+		case ipProtoICMP:
+			out.AddField(NewOxmField(OFPXMT_OFB_ICMPV4_TYPE, uint8Value(uint8(m.TpSrc)), nil))
+		default:
+			unsupported = append(unsupported, "tp_src")
+		}
+	}
+	if w&ofp10.OFPFW_TP_DST == 0 {
+		switch proto {
+		case ipProtoTCP:
+			out.AddField(NewOxmField(OFPXMT_OFB_TCP_DST, uint16Value(m.TpDst), nil))
+		case ipProtoUDP:
+			out.AddField(NewOxmField(OFPXMT_OFB_UDP_DST, uint16Value(m.TpDst), nil))
+		case ipProtoICMP:
+			out.AddField(NewOxmField(OFPXMT_OFB_ICMPV4_CODE, uint8Value(uint8(m.TpDst)), nil))
+		default:
+			unsupported = append(unsupported, "tp_dst")
+		}
+	}
+
+	if len(unsupported) > 0 {
+		return out, &UnsupportedFieldsError{Fields: unsupported}
+	}
+	return out, nil
+}
+
+// isExactMask reports whether the mask is absent or all ones.
+func isExactMask(mask []byte) bool {
+	for _, b := range mask {
+		if b != 0xff {
+			return false
+		}
+	}
+	return true
+}
+
+// isZeroMask reports whether the mask wildcards the whole field.
+func isZeroMask(mask []byte) bool {
+	if mask == nil {
+		return false
+	}
+	for _, b := range mask {
+		if b != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// nwFieldToOfp10 gets the address and prefix length of an IPv4 address field.
+func nwFieldToOfp10(f *OxmField) (addr uint32, prefixLen int, ok bool) {
+	if len(f.Value) != 4 {
+		return 0, 0, false
+	}
+	addr = binary.BigEndian.Uint32(f.Value)
+	prefixLen = 32
+	if f.HasMask() {
+		if prefixLen, ok = maskPrefixLen(binary.BigEndian.Uint32(f.Mask)); !ok {
+			return 0, 0, false
+		}
+	}
+	return addr & prefixMask(prefixLen), prefixLen, true
+}
+
+// MatchToOfp10 converts an OXM match into an openflow 1.0 match. It fails if
+// the match's prerequisites are not met. Fields without an openflow 1.0
+// equivalent, such as IPv6 or MPLS fields and arbitrary bitmasks, are left out
+// and reported by an *UnsupportedFieldsError which is returned along with the
+// converted match.
+func MatchToOfp10(m *Match) (*ofp10.Match, error) {
+	if err := m.CheckPrerequisites(); err != nil {
+		return nil, err
+	}
+	out := &ofp10.Match{Wildcards: ofp10.OFPFW_ALL}
+	var unsupported []string
+	hasPcp := m.Field(OFPXMT_OFB_VLAN_PCP) != nil
+
+	for i := range m.Fields {
+		f := &m.Fields[i]
+		if f.Class != OFPXMC_OPENFLOW_BASIC {
+			unsupported = append(unsupported, oxmFieldName(f))
+			continue
+		}
+		if isZeroMask(f.Mask) {
+			continue
+		}
+		exact := isExactMask(f.Mask)
+		ok := true
+		switch f.Field {
+		case OFPXMT_OFB_IN_PORT:
+			if ok = exact && len(f.Value) == 4; ok {
+				if out.InPort, ok = PortToOfp10(binary.BigEndian.Uint32(f.Value)); ok {
+					out.Wildcards &^= ofp10.OFPFW_IN_PORT
+				}
+			}
+		case OFPXMT_OFB_ETH_DST:
+			if ok = exact && len(f.Value) == ofp10.OFP_ETH_ALAN; ok {
+				copy(out.EthDst[:], f.Value)
+				out.Wildcards &^= ofp10.OFPFW_DL_DST
+			}
+		case OFPXMT_OFB_ETH_SRC:
+			if ok = exact && len(f.Value) == ofp10.OFP_ETH_ALAN; ok {
+				copy(out.EthSrc[:], f.Value)
+				out.Wildcards &^= ofp10.OFPFW_DL_SRC
+			}
+		case OFPXMT_OFB_ETH_TYPE:
+			if ok = exact && len(f.Value) == 2; ok {
+				out.EthType = binary.BigEndian.Uint16(f.Value)
+				out.Wildcards &^= ofp10.OFPFW_DL_TYPE
+			}
+		case OFPXMT_OFB_VLAN_VID:
+			if len(f.Value) != 2 {
+				ok = false
+				break
+			}
+			vid := binary.BigEndian.Uint16(f.Value)
+			switch {
+			case exact && vid == OFPVID_NONE:
+				out.VlanId = ofp10.OFP_VLAN_NONE
+				out.Wildcards &^= ofp10.OFPFW_DL_VLAN
+			case exact && vid&OFPVID_PRESENT != 0:
+				out.VlanId = vid & 0x0fff
+				out.Wildcards &^= ofp10.OFPFW_DL_VLAN
+			default:
+				// "Any tagged packet" only exists in openflow 1.0 as
+				// a side effect of matching the VLAN priority.
+				ok = hasPcp && vid == OFPVID_PRESENT &&
+					binary.BigEndian.Uint16(f.Mask) == OFPVID_PRESENT
+			}
+		case OFPXMT_OFB_VLAN_PCP:
+			if ok = exact && len(f.Value) == 1; ok {
+				out.VlanPcp = f.Value[0]
+				out.Wildcards &^= ofp10.OFPFW_DL_VLAN_PCP
+			}
+		case OFPXMT_OFB_IP_DSCP:
+			if ok = exact && len(f.Value) == 1; ok {
+				out.NwTos = f.Value[0] << 2
+				out.Wildcards &^= ofp10.OFPFW_NW_TOS
+			}
+		case OFPXMT_OFB_IP_PROTO:
+			if ok = exact && len(f.Value) == 1; ok {
+				out.NwProto = f.Value[0]
+				out.Wildcards &^= ofp10.OFPFW_NW_PROTO
+			}
+		case OFPXMT_OFB_ARP_OP:
+			if ok = exact && len(f.Value) == 2 && binary.BigEndian.Uint16(f.Value) <= 0xff; ok {
+				out.NwProto = uint8(binary.BigEndian.Uint16(f.Value))
+				out.Wildcards &^= ofp10.OFPFW_NW_PROTO
+			}
+		case OFPXMT_OFB_IPV4_SRC, OFPXMT_OFB_ARP_SPA:
+			var prefixLen int
+			if out.NwSrc, prefixLen, ok = nwFieldToOfp10(f); ok {
+				out.SetNwSrcPrefixLen(prefixLen)
+			}
+		case OFPXMT_OFB_IPV4_DST, OFPXMT_OFB_ARP_TPA:
+			var prefixLen int
+			if out.NwDst, prefixLen, ok = nwFieldToOfp10(f); ok {
+				out.SetNwDstPrefixLen(prefixLen)
+			}
+		case OFPXMT_OFB_TCP_SRC, OFPXMT_OFB_UDP_SRC:
+			if ok = exact && len(f.Value) == 2; ok {
+				out.TpSrc = binary.BigEndian.Uint16(f.Value)
+				out.Wildcards &^= ofp10.OFPFW_TP_SRC
+			}
+		case OFPXMT_OFB_TCP_DST, OFPXMT_OFB_UDP_DST:
+			if ok = exact && len(f.Value) == 2; ok {
+				out.TpDst = binary.BigEndian.Uint16(f.Value)
+				out.Wildcards &^= ofp10.OFPFW_TP_DST
+			}
+		case OFPXMT_OFB_ICMPV4_TYPE:
+			if ok = exact && len(f.Value) == 1; ok {
+				out.TpSrc = uint16(f.Value[0])
+				out.Wildcards &^= ofp10.OFPFW_TP_SRC
+			}
+		case OFPXMT_OFB_ICMPV4_CODE:
+			if ok = exact && len(f.Value) == 1; ok {
+				out.TpDst = uint16(f.Value[0])
+				out.Wildcards &^= ofp10.OFPFW_TP_DST
+			}
+		default:
+			ok = false
+		}
+		if !ok {
+			unsupported = append(unsupported, oxmFieldName(f))
+		}
+	}
+
+	if len(unsupported) > 0 {
+		return out, &UnsupportedFieldsError{Fields: unsupported}
+	}
+	return out, nil
+}
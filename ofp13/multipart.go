@@ -0,0 +1,167 @@
+package ofp13
+
+import (
+	"encoding/binary"
+	"errors"
+
+	"github.com/kuun/ofgo/ofp"
+)
+
+// Multipart message types.
+const (
+	OFPMP_DESC           = iota // Description of this OpenFlow switch.
+	OFPMP_FLOW                  // Individual flow statistics.
+	OFPMP_AGGREGATE             // Aggregate flow statistics.
+	OFPMP_TABLE                 // Flow table statistics.
+	OFPMP_PORT_STATS            // Port statistics.
+	OFPMP_QUEUE                 // Queue statistics for a port.
+	OFPMP_GROUP                 // Group counter statistics.
+	OFPMP_GROUP_DESC            // Group description.
+	OFPMP_GROUP_FEATURES        // Group features.
+	OFPMP_METER                 // Meter statistics.
+	OFPMP_METER_CONFIG          // Meter configuration.
+	OFPMP_METER_FEATURES        // Meter features.
+	OFPMP_TABLE_FEATURES        // Table features.
+	OFPMP_PORT_DESC             // Port description.
+	OFPMP_EXPERIMENTER   = 0xffff
+)
+
+// Multipart reply flags.
+const (
+	OFPMPF_REPLY_MORE = 1 << 0 // More replies to follow.
+)
+
+// multipart message binary size without body, in byte
+const multipartSize = 16
+
+// MultipartRequest is a multipart request, controller -> switch. The body
+// depends on the multipart type, it's kept as binary.
+type MultipartRequest struct {
+	ofp.Header
+	MultipartType uint16 // One of the OFPMP_* constants.
+	Flags         uint16 // OFPMPF_REQ_* flags.
+	Body          []byte // Body of the request.
+}
+
+// NewMultipartRequest creates a multipart request of the type, the body is
+// marshaled from 'body' if it's not nil.
+func NewMultipartRequest(multipartType uint16, body ofp.DataBlock) (*MultipartRequest, error) {
+	msg := &MultipartRequest{
+		Header: ofp.Header{
+			Version: ofp.OFP13_VERSION,
+			Type:    OFPT_MULTIPART_REQUEST,
+			Length:  multipartSize,
+		},
+		MultipartType: multipartType,
+	}
+	if body != nil {
+		msg.Body = make([]byte, body.Len())
+		if _, err := body.Marshal(msg.Body); err != nil {
+			return nil, err
+		}
+		msg.Header.Length += uint16(len(msg.Body))
+	}
+	return msg, nil
+}
+
+// NewPortDescRequest creates a request of the description of all the ports.
+func NewPortDescRequest() *MultipartRequest {
+	msg, _ := NewMultipartRequest(OFPMP_PORT_DESC, nil)
+	return msg
+}
+
+func (msg *MultipartRequest) Len() int {
+	return int(msg.Header.Length)
+}
+
+func (msg *MultipartRequest) Marshal(buf []byte) (n int, err error) {
+	return marshalMultipart(buf, &msg.Header, msg.MultipartType, msg.Flags, msg.Body)
+}
+
+func (msg *MultipartRequest) Unmarshal(buf []byte) (n int, err error) {
+	return unmarshalMultipart(buf, &msg.Header, &msg.MultipartType, &msg.Flags, &msg.Body)
+}
+
+// AppendBinary appends the message's binary form to buf.
+func (msg *MultipartRequest) AppendBinary(buf []byte) ([]byte, error) {
+	return ofp.AppendBinary(buf, msg)
+}
+
+// MultipartReply is a multipart reply, switch -> controller. A reply can be
+// split in several messages, all but the last one have OFPMPF_REPLY_MORE set.
+type MultipartReply struct {
+	ofp.Header
+	MultipartType uint16 // One of the OFPMP_* constants.
+	Flags         uint16 // OFPMPF_REPLY_* flags.
+	Body          []byte // Body of the reply.
+}
+
+func (msg *MultipartReply) Len() int {
+	return int(msg.Header.Length)
+}
+
+func (msg *MultipartReply) Marshal(buf []byte) (n int, err error) {
+	return marshalMultipart(buf, &msg.Header, msg.MultipartType, msg.Flags, msg.Body)
+}
+
+func (msg *MultipartReply) Unmarshal(buf []byte) (n int, err error) {
+	return unmarshalMultipart(buf, &msg.Header, &msg.MultipartType, &msg.Flags, &msg.Body)
+}
+
+// AppendBinary appends the message's binary form to buf.
+func (msg *MultipartReply) AppendBinary(buf []byte) ([]byte, error) {
+	return ofp.AppendBinary(buf, msg)
+}
+
+// More reports whether more parts of the reply follow.
+func (msg *MultipartReply) More() bool {
+	return msg.Flags&OFPMPF_REPLY_MORE != 0
+}
+
+// PortDesc parses the body of an OFPMP_PORT_DESC reply.
+func (msg *MultipartReply) PortDesc() ([]Port, error) {
+	if msg.MultipartType != OFPMP_PORT_DESC {
+		return nil, errors.New("not a port description reply")
+	}
+	var ports []Port
+	for n := 0; n+portSize <= len(msg.Body); n += portSize {
+		port := Port{}
+		if _, err := port.Unmarshal(msg.Body[n:]); err != nil {
+			return nil, err
+		}
+		ports = append(ports, port)
+	}
+	return ports, nil
+}
+
+func marshalMultipart(buf []byte, header *ofp.Header, multipartType, flags uint16, body []byte) (n int, err error) {
+	length := int(header.Length)
+	if len(buf) < length || length < multipartSize {
+		return 0, errors.New("buffer is too short")
+	}
+	if n, err = header.Marshal(buf); err != nil {
+		return n, err
+	}
+	binary.BigEndian.PutUint16(buf[n:], multipartType)
+	n += 2
+	binary.BigEndian.PutUint16(buf[n:], flags)
+	n += 6 // plus 4 padding bytes
+	copy(buf[n:length], body)
+	return length, nil
+}
+
+func unmarshalMultipart(buf []byte, header *ofp.Header, multipartType, flags *uint16, body *[]byte) (n int, err error) {
+	if n, err = header.Unmarshal(buf); err != nil {
+		return n, err
+	}
+	length := int(header.Length)
+	if len(buf) < length || length < multipartSize {
+		return 0, errors.New("buffer is too short")
+	}
+	*multipartType = binary.BigEndian.Uint16(buf[n:])
+	n += 2
+	*flags = binary.BigEndian.Uint16(buf[n:])
+	n += 6 // plus 4 padding bytes
+	*body = append([]byte(nil), buf[n:length]...)
+	return length, nil
+}
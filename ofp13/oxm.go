@@ -0,0 +1,152 @@
+package ofp13
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+// OXM Class IDs. The high order bit differentiate reserved classes from
+// member classes. Classes 0x0000 to 0x7FFF are member classes, allocated by
+// ONF. Classes 0x8000 to 0xFFFE are reserved classes, reserved for
+// standardisation.
+const (
+	OFPXMC_NXM_0          = 0x0000 // Backward compatibility with NXM.
+	OFPXMC_NXM_1          = 0x0001 // Backward compatibility with NXM.
+	OFPXMC_OPENFLOW_BASIC = 0x8000 // Basic class for OpenFlow.
+	OFPXMC_EXPERIMENTER   = 0xFFFF // Experimenter class.
+)
+
+// OXM Flow match field types for OpenFlow basic class.
+const (
+	OFPXMT_OFB_IN_PORT        = iota // Switch input port.
+	OFPXMT_OFB_IN_PHY_PORT           // Switch physical input port.
+	OFPXMT_OFB_METADATA              // Metadata passed between tables.
+	OFPXMT_OFB_ETH_DST               // Ethernet destination address.
+	OFPXMT_OFB_ETH_SRC               // Ethernet source address.
+	OFPXMT_OFB_ETH_TYPE              // Ethernet frame type.
+	OFPXMT_OFB_VLAN_VID              // VLAN id.
+	OFPXMT_OFB_VLAN_PCP              // VLAN priority.
+	OFPXMT_OFB_IP_DSCP               // IP DSCP (6 bits in ToS field).
+	OFPXMT_OFB_IP_ECN                // IP ECN (2 bits in ToS field).
+	OFPXMT_OFB_IP_PROTO              // IP protocol.
+	OFPXMT_OFB_IPV4_SRC              // IPv4 source address.
+	OFPXMT_OFB_IPV4_DST              // IPv4 destination address.
+	OFPXMT_OFB_TCP_SRC               // TCP source port.
+	OFPXMT_OFB_TCP_DST               // TCP destination port.
+	OFPXMT_OFB_UDP_SRC               // UDP source port.
+	OFPXMT_OFB_UDP_DST               // UDP destination port.
+	OFPXMT_OFB_SCTP_SRC              // SCTP source port.
+	OFPXMT_OFB_SCTP_DST              // SCTP destination port.
+	OFPXMT_OFB_ICMPV4_TYPE           // ICMP type.
+	OFPXMT_OFB_ICMPV4_CODE           // ICMP code.
+	OFPXMT_OFB_ARP_OP                // ARP opcode.
+	OFPXMT_OFB_ARP_SPA               // ARP source IPv4 address.
+	OFPXMT_OFB_ARP_TPA               // ARP target IPv4 address.
+	OFPXMT_OFB_ARP_SHA               // ARP source hardware address.
+	OFPXMT_OFB_ARP_THA               // ARP target hardware address.
+	OFPXMT_OFB_IPV6_SRC              // IPv6 source address.
+	OFPXMT_OFB_IPV6_DST              // IPv6 destination address.
+	OFPXMT_OFB_IPV6_FLABEL           // IPv6 Flow Label.
+	OFPXMT_OFB_ICMPV6_TYPE           // ICMPv6 type.
+	OFPXMT_OFB_ICMPV6_CODE           // ICMPv6 code.
+	OFPXMT_OFB_IPV6_ND_TARGET        // Target address for ND.
+	OFPXMT_OFB_IPV6_ND_SLL           // Source link-layer for ND.
+	OFPXMT_OFB_IPV6_ND_TLL           // Target link-layer for ND.
+	OFPXMT_OFB_MPLS_LABEL            // MPLS label.
+	OFPXMT_OFB_MPLS_TC               // MPLS TC.
+	OFPXMT_OFB_MPLS_BOS              // MPLS BoS bit.
+	OFPXMT_OFB_PBB_ISID              // PBB I-SID.
+	OFPXMT_OFB_TUNNEL_ID             // Logical Port Metadata.
+	OFPXMT_OFB_IPV6_EXTHDR           // IPv6 Extension Header pseudo-field.
+)
+
+// The VLAN id is 12-bits, so we can use the entire 16 bits to indicate
+// special conditions.
+const (
+	OFPVID_PRESENT = 0x1000 // Bit that indicate that a VLAN id is set.
+	OFPVID_NONE    = 0x0000 // No VLAN id was set.
+)
+
+// oxmHeaderLen is the binary length of an OXM TLV header.
+const oxmHeaderLen = 4
+
+// OxmField is an OXM flow match field TLV. The header packs the class, the
+// field, the hasmask bit and the payload length in 32 bits, Mask is nil when
+// the hasmask bit is clear and has the same length as Value otherwise.
+type OxmField struct {
+	Class uint16 // One of OFPXMC_*.
+	Field uint8  // One of OFPXMT_*, 7 bits.
+	Value []byte // Field value, in network byte order.
+	Mask  []byte // Bitmask of the value, nil for an exact match.
+}
+
+// NewOxmField creates an OpenFlow basic class OXM field, a nil mask means an
+// exact match.
+func NewOxmField(field uint8, value, mask []byte) *OxmField {
+	return &OxmField{
+		Class: OFPXMC_OPENFLOW_BASIC,
+		Field: field,
+		Value: value,
+		Mask:  mask,
+	}
+}
+
+// HasMask reports whether the field is masked.
+func (f *OxmField) HasMask() bool {
+	return f.Mask != nil
+}
+
+// Is reports whether the field is the OpenFlow basic class field.
+func (f *OxmField) Is(field uint8) bool {
+	return f.Class == OFPXMC_OPENFLOW_BASIC && f.Field == field
+}
+
+func (f *OxmField) Len() int {
+	return oxmHeaderLen + len(f.Value) + len(f.Mask)
+}
+
+func (f *OxmField) Marshal(buf []byte) (n int, err error) {
+	if len(buf) < f.Len() {
+		return 0, errors.New("buffer is too short")
+	}
+	if f.Mask != nil && len(f.Mask) != len(f.Value) {
+		return 0, errors.New("oxm mask and value length mismatch")
+	}
+	binary.BigEndian.PutUint16(buf, f.Class)
+	buf[2] = f.Field << 1
+	if f.HasMask() {
+		buf[2] |= 1
+	}
+	buf[3] = uint8(len(f.Value) + len(f.Mask))
+	n = oxmHeaderLen
+	n += copy(buf[n:], f.Value)
+	n += copy(buf[n:], f.Mask)
+	return n, nil
+}
+
+func (f *OxmField) Unmarshal(buf []byte) (n int, err error) {
+	if len(buf) < oxmHeaderLen {
+		return 0, errors.New("buffer is too short")
+	}
+	f.Class = binary.BigEndian.Uint16(buf)
+	f.Field = buf[2] >> 1
+	hasMask := buf[2]&1 != 0
+	payloadLen := int(buf[3])
+	n = oxmHeaderLen
+	if len(buf) < n+payloadLen {
+		return 0, errors.New("buffer is too short")
+	}
+	if hasMask {
+		if payloadLen%2 != 0 {
+			return 0, errors.New("bad oxm masked field length")
+		}
+		valueLen := payloadLen / 2
+		f.Value = append([]byte(nil), buf[n:n+valueLen]...)
+		f.Mask = append([]byte(nil), buf[n+valueLen:n+payloadLen]...)
+	} else {
+		f.Value = append([]byte(nil), buf[n:n+payloadLen]...)
+		f.Mask = nil
+	}
+	n += payloadLen
+	return n, nil
+}
@@ -0,0 +1,109 @@
+package ofp13
+
+import (
+	"encoding/binary"
+	"errors"
+
+	"github.com/kuun/ofgo/ofp"
+)
+
+// Why is this packet being sent to the controller?
+const (
+	OFPR_NO_MATCH    = iota // No matching flow (table-miss flow entry).
+	OFPR_ACTION             // Action explicitly output to controller.
+	OFPR_INVALID_TTL        // Packet has invalid TTL.
+)
+
+// packet in binary size without match and data, in byte
+const packetInSize = 24
+
+// PacketIn is the message carrying a packet received by the datapath,
+// switch -> controller.
+type PacketIn struct {
+	ofp.Header
+	BufferId uint32 // ID assigned by datapath.
+	TotalLen uint16 // Full length of frame.
+	Reason   uint8  // Reason packet is being sent (one of OFPR_*).
+	TableId  uint8  // ID of the table that was looked up.
+	Cookie   uint64 // Cookie of the flow entry that was looked up.
+	Match    Match  // Packet metadata, e.g. the input port.
+	// Ethernet frame, following 2 padding bytes so the IP header is 32-bit
+	// aligned. The amount of data is inferred from the length field in the
+	// header.
+	Data []byte
+}
+
+func (msg *PacketIn) Len() int {
+	return int(msg.Header.Length)
+}
+
+func (msg *PacketIn) Marshal(buf []byte) (n int, err error) {
+	if len(buf) < msg.Len() || msg.Len() < packetInSize+msg.Match.Len()+2 {
+		return 0, errors.New("buffer is too short")
+	}
+	if n, err = msg.Header.Marshal(buf); err != nil {
+		return n, err
+	}
+	binary.BigEndian.PutUint32(buf[n:], msg.BufferId)
+	n += 4
+	binary.BigEndian.PutUint16(buf[n:], msg.TotalLen)
+	n += 2
+	buf[n] = msg.Reason
+	n++
+	buf[n] = msg.TableId
+	n++
+	binary.BigEndian.PutUint64(buf[n:], msg.Cookie)
+	n += 8
+	var m int
+	if m, err = msg.Match.Marshal(buf[n:]); err != nil {
+		return n + m, err
+	}
+	n += m
+	buf[n], buf[n+1] = 0, 0
+	n += 2
+	copy(buf[n:msg.Len()], msg.Data)
+	return msg.Len(), nil
+}
+
+func (msg *PacketIn) Unmarshal(buf []byte) (n int, err error) {
+	if n, err = msg.Header.Unmarshal(buf); err != nil {
+		return n, err
+	}
+	if len(buf) < msg.Len() || msg.Len() < packetInSize+matchHeaderLen {
+		return 0, errors.New("buffer is too short")
+	}
+	msg.BufferId = binary.BigEndian.Uint32(buf[n:])
+	n += 4
+	msg.TotalLen = binary.BigEndian.Uint16(buf[n:])
+	n += 2
+	msg.Reason = buf[n]
+	n++
+	msg.TableId = buf[n]
+	n++
+	msg.Cookie = binary.BigEndian.Uint64(buf[n:])
+	n += 8
+	var m int
+	if m, err = msg.Match.Unmarshal(buf[n:msg.Len()]); err != nil {
+		return n + m, err
+	}
+	n += m
+	if n+2 > msg.Len() {
+		return n, errors.New("bad match length")
+	}
+	n += 2 // 2 padding bytes
+	msg.Data = append([]byte(nil), buf[n:msg.Len()]...)
+	return msg.Len(), nil
+}
+
+// AppendBinary appends the message's binary form to buf.
+func (msg *PacketIn) AppendBinary(buf []byte) ([]byte, error) {
+	return ofp.AppendBinary(buf, msg)
+}
+
+// InPort gets the port on which the packet was received, from the match.
+func (msg *PacketIn) InPort() uint32 {
+	if f := msg.Match.Field(OFPXMT_OFB_IN_PORT); f != nil {
+		return uint32(fieldUint(f.Value))
+	}
+	return 0
+}
@@ -0,0 +1,217 @@
+package ofp13
+
+import (
+	"encoding/binary"
+	"errors"
+
+	"github.com/kuun/ofgo/ofp10"
+)
+
+// Port numbering. Ports are numbered starting from 1.
+const (
+	OFPP_MAX = 0xffffff00 // Maximum number of physical and logical switch ports.
+
+	// Reserved OpenFlow Port (fake output "ports").
+	OFPP_IN_PORT    = 0xfffffff8 // Send the packet out the input port.
+	OFPP_TABLE      = 0xfffffff9 // Submit the packet to the first flow table.
+	OFPP_NORMAL     = 0xfffffffa // Process with normal L2/L3 switching.
+	OFPP_FLOOD      = 0xfffffffb // All physical ports in VLAN, except input port and those blocked or link down.
+	OFPP_ALL        = 0xfffffffc // All physical ports except input port.
+	OFPP_CONTROLLER = 0xfffffffd // Send to controller.
+	OFPP_LOCAL      = 0xfffffffe // Local openflow "port".
+	OFPP_ANY        = 0xffffffff // Wildcard port used only for flow mod (delete) and flow stats requests.
+)
+
+// PortFromOfp10 converts an openflow 1.0 port number, reserved ports are
+// mapped to their openflow 1.3 counterparts.
+func PortFromOfp10(port uint16) uint32 {
+	if port >= 0xff00 {
+		return 0xffff0000 | uint32(port)
+	}
+	return uint32(port)
+}
+
+// PortToOfp10 converts a port number to openflow 1.0, it returns false if the
+// port number cannot be represented in 16 bits.
+func PortToOfp10(port uint32) (uint16, bool) {
+	if port < 0xff00 || port >= OFPP_MAX {
+		return uint16(port), true
+	}
+	return 0, false
+}
+
+const OFP_MAX_PORT_NAME_LEN = 16
+
+// Port config flags, the bits have the positions of their openflow 1.0
+// counterparts.
+const (
+	OFPPC_PORT_DOWN    = 1 << 0 // Port is administratively down.
+	OFPPC_NO_RECV      = 1 << 2 // Drop all packets received by port.
+	OFPPC_NO_FWD       = 1 << 5 // Drop packets forwarded to port.
+	OFPPC_NO_PACKET_IN = 1 << 6 // Do not send packet-in msgs for port.
+)
+
+// Port state, these are not configurable from the controller.
+const (
+	OFPPS_LINK_DOWN = 1 << 0 // No physical link present.
+	OFPPS_BLOCKED   = 1 << 1 // Port is blocked.
+	OFPPS_LIVE      = 1 << 2 // Live for Fast Failover Group.
+)
+
+// Features of ports available in a datapath.
+const (
+	OFPPF_10MB_HD    = 1 << iota // 10 Mb half-duplex rate support.
+	OFPPF_10MB_FD                // 10 Mb full-duplex rate support.
+	OFPPF_100MB_HD               // 100 Mb half-duplex rate support.
+	OFPPF_100MB_FD               // 100 Mb full-duplex rate support.
+	OFPPF_1GB_HD                 // 1 Gb half-duplex rate support.
+	OFPPF_1GB_FD                 // 1 Gb full-duplex rate support.
+	OFPPF_10GB_FD                // 10 Gb full-duplex rate support.
+	OFPPF_40GB_FD                // 40 Gb full-duplex rate support.
+	OFPPF_100GB_FD               // 100 Gb full-duplex rate support.
+	OFPPF_1TB_FD                 // 1 Tb full-duplex rate support.
+	OFPPF_OTHER                  // Other rate, not in the list.
+	OFPPF_COPPER                 // Copper medium.
+	OFPPF_FIBER                  // Fiber medium.
+	OFPPF_AUTONEG                // Auto-negotiation.
+	OFPPF_PAUSE                  // Pause.
+	OFPPF_PAUSE_ASYM             // Asymmetric pause.
+)
+
+// port binary size, in byte
+const portSize = 64
+
+// Port is the description of an openflow port.
+type Port struct {
+	PortNo uint32
+	pad    [4]byte
+	HwAddr [6]byte
+	pad2   [2]byte                     // Align to 64 bits.
+	Name   [OFP_MAX_PORT_NAME_LEN]byte // Null-terminated
+
+	Config uint32 // Bitmap of OFPPC_* flags.
+	State  uint32 // Bitmap of OFPPS_* flags.
+
+	// Bitmaps of OFPPF_* that describe features.  All bits zeroed if unsupported or unavailable.
+	CurrFeatures       uint32 // Current features.
+	AdvertisedFeatures uint32 // Features being advertised by the port.
+	SupportedFeatures  uint32 // Features supported by the port.
+	PeerFeatures       uint32 // Features advertised by peer.
+
+	CurrSpeed uint32 // Current port bitrate in kbps.
+	MaxSpeed  uint32 // Max port bitrate in kbps.
+}
+
+func (p *Port) Unmarshal(buf []byte) (n int, err error) {
+	if len(buf) < p.Len() {
+		return 0, errors.New("buffer is too short")
+	}
+	p.PortNo = binary.BigEndian.Uint32(buf)
+	n += 8 // plus 4 padding bytes
+	copy(p.HwAddr[:], buf[n:])
+	n += 8 // plus 2 padding bytes
+	copy(p.Name[:], buf[n:])
+	n += OFP_MAX_PORT_NAME_LEN
+	p.Config = binary.BigEndian.Uint32(buf[n:])
+	n += 4
+	p.State = binary.BigEndian.Uint32(buf[n:])
+	n += 4
+	p.CurrFeatures = binary.BigEndian.Uint32(buf[n:])
+	n += 4
+	p.AdvertisedFeatures = binary.BigEndian.Uint32(buf[n:])
+	n += 4
+	p.SupportedFeatures = binary.BigEndian.Uint32(buf[n:])
+	n += 4
+	p.PeerFeatures = binary.BigEndian.Uint32(buf[n:])
+	n += 4
+	p.CurrSpeed = binary.BigEndian.Uint32(buf[n:])
+	n += 4
+	p.MaxSpeed = binary.BigEndian.Uint32(buf[n:])
+	n += 4
+	return n, nil
+}
+
+func (p *Port) Marshal(buf []byte) (n int, err error) {
+	if len(buf) < p.Len() {
+		return 0, errors.New("buffer is too short")
+	}
+	binary.BigEndian.PutUint32(buf, p.PortNo)
+	n += 4
+	copy(buf[n:], p.pad[:])
+	n += 4
+	copy(buf[n:], p.HwAddr[:])
+	n += 6
+	copy(buf[n:], p.pad2[:])
+	n += 2
+	copy(buf[n:], p.Name[:])
+	n += OFP_MAX_PORT_NAME_LEN
+	binary.BigEndian.PutUint32(buf[n:], p.Config)
+	n += 4
+	binary.BigEndian.PutUint32(buf[n:], p.State)
+	n += 4
+	binary.BigEndian.PutUint32(buf[n:], p.CurrFeatures)
+	n += 4
+	binary.BigEndian.PutUint32(buf[n:], p.AdvertisedFeatures)
+	n += 4
+	binary.BigEndian.PutUint32(buf[n:], p.SupportedFeatures)
+	n += 4
+	binary.BigEndian.PutUint32(buf[n:], p.PeerFeatures)
+	n += 4
+	binary.BigEndian.PutUint32(buf[n:], p.CurrSpeed)
+	n += 4
+	binary.BigEndian.PutUint32(buf[n:], p.MaxSpeed)
+	n += 4
+	return n, nil
+}
+
+func (p *Port) Len() int {
+	return portSize
+}
+
+// featuresToOfp10 maps the OFPPF_* bits to their openflow 1.0 counterparts,
+// the rates openflow 1.0 doesn't know are left out.
+var featuresToOfp10 = [...]struct{ from, to uint32 }{
+	{OFPPF_10MB_HD, ofp10.OFPPF_10MB_HD},
+	{OFPPF_10MB_FD, ofp10.OFPPF_10MB_FD},
+	{OFPPF_100MB_HD, ofp10.OFPPF_100MB_HD},
+	{OFPPF_100MB_FD, ofp10.OFPPF_100MB_FD},
+	{OFPPF_1GB_HD, ofp10.OFPPF_1GB_HD},
+	{OFPPF_1GB_FD, ofp10.OFPPF_1GB_FD},
+	{OFPPF_10GB_FD, ofp10.OFPPF_10GB_FD},
+	{OFPPF_COPPER, ofp10.OFPPF_COPPER},
+	{OFPPF_FIBER, ofp10.OFPPF_FIBER},
+	{OFPPF_AUTONEG, ofp10.OFPPF_AUTONEG},
+	{OFPPF_PAUSE, ofp10.OFPPF_PAUSE},
+	{OFPPF_PAUSE_ASYM, ofp10.OFPPF_PAUSE_ASYM},
+}
+
+func portFeaturesToOfp10(features uint32) uint32 {
+	var converted uint32
+	for _, bit := range featuresToOfp10 {
+		if features&bit.from != 0 {
+			converted |= bit.to
+		}
+	}
+	return converted
+}
+
+// ToOfp10 converts the port to its openflow 1.0 form, the speeds and the
+// blocked and live states are left out. It returns false if the port number
+// cannot be represented in 16 bits.
+func (p *Port) ToOfp10() (ofp10.Port, bool) {
+	portNo, ok := PortToOfp10(p.PortNo)
+	if !ok {
+		return ofp10.Port{}, false
+	}
+	return ofp10.Port{
+		PortNo:             portNo,
+		HwAddr:             p.HwAddr,
+		Name:               p.Name,
+		Config:             p.Config & (OFPPC_PORT_DOWN | OFPPC_NO_RECV | OFPPC_NO_FWD | OFPPC_NO_PACKET_IN),
+		State:              p.State & OFPPS_LINK_DOWN,
+		CurrFeatures:       portFeaturesToOfp10(p.CurrFeatures),
+		AdvertisedFeatures: portFeaturesToOfp10(p.AdvertisedFeatures),
+		SupportedFeatures:  portFeaturesToOfp10(p.SupportedFeatures),
+		PeerFeatures:       portFeaturesToOfp10(p.PeerFeatures),
+	}, true
+}
@@ -0,0 +1,84 @@
+package ofp13
+
+import (
+	"github.com/kuun/ofgo/ofp"
+)
+
+// openflow 1.3 message type
+const (
+	// immutable messages, symmetric messages.
+	OFPT_HELLO = iota
+	OFPT_ERROR
+	OFPT_ECHO_REQUEST
+	OFPT_ECHO_REPLY
+	OFPT_EXPERIMENTER
+
+	// switch configuration messages.
+	OFPT_FEATURES_REQUEST
+	OFPT_FEATURES_REPLY
+	OFPT_GET_CONFIG_REQUEST
+	OFPT_GET_CONFIG_REPLY
+	OFPT_SET_CONFIG
+
+	// asynchronous messages.
+	OFPT_PACKET_IN
+	OFPT_FLOW_REMOVED
+	OFPT_PORT_STATUS
+
+	// controller command messages.
+	OFPT_PACKET_OUT
+	OFPT_FLOW_MOD
+	OFPT_GROUP_MOD
+	OFPT_PORT_MOD
+	OFPT_TABLE_MOD
+
+	// multipart messages.
+	OFPT_MULTIPART_REQUEST
+	OFPT_MULTIPART_REPLY
+
+	// barrier messages.
+	OFPT_BARRIER_REQUEST
+	OFPT_BARRIER_REPLY
+
+	// queue configuration messages.
+	OFPT_QUEUE_GET_CONFIG_REQUEST
+	OFPT_QUEUE_GET_CONFIG_REPLY
+
+	// controller role change request messages.
+	OFPT_ROLE_REQUEST
+	OFPT_ROLE_REPLY
+
+	// asynchronous message configuration.
+	OFPT_GET_ASYNC_REQUEST
+	OFPT_GET_ASYNC_REPLY
+	OFPT_SET_ASYNC
+
+	// meters and rate limiters configuration messages.
+	OFPT_METER_MOD
+)
+
+// NewHello creates an openflow 1.3 hello message advertising the versions in
+// a version bitmap element.
+func NewHello(versions ...uint8) *ofp.Hello {
+	hello := &ofp.Hello{
+		Header: ofp.Header{
+			Version: ofp.OFP13_VERSION,
+			Type:    OFPT_HELLO,
+			Length:  ofp.HeaderLength,
+		},
+	}
+	if len(versions) > 0 {
+		hello.AddElement(ofp.NewHelloElemVersionBitmap(versions...))
+	}
+	return hello
+}
+
+func NewEchoRequest() *ofp.EchoRequest {
+	return &ofp.EchoRequest{
+		Header: ofp.Header{
+			Version: ofp.OFP13_VERSION,
+			Type:    OFPT_ECHO_REQUEST,
+			Length:  ofp.HeaderLength,
+		},
+	}
+}